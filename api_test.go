@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cb "github.com/na50r/wombo-combo-go-be/combiner"
+	"github.com/na50r/wombo-combo-go-be/config"
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	dto "github.com/na50r/wombo-combo-go-be/dto"
+	g "github.com/na50r/wombo-combo-go-be/game"
+	"github.com/na50r/wombo-combo-go-be/sse"
+	st "github.com/na50r/wombo-combo-go-be/storage"
+	"github.com/na50r/wombo-combo-go-be/token"
+)
+
+// testServer runs the full router on a MemoryStore with the offline combiner
+type testServer struct {
+	t      *testing.T
+	api    *APIServer
+	store  *st.MemoryStore
+	server *httptest.Server
+}
+
+// configure changes the settings before the server is created
+func newTestServer(t *testing.T, configure ...func(cfg *config.Config)) *testServer {
+	t.Helper()
+	token.Configure(token.Config{Secret: "test-secret"})
+	store := st.NewMemoryStore()
+	ctx := context.Background()
+	if err := store.AddImage(ctx, []byte("png"), "default.png"); err != nil {
+		t.Fatal(err)
+	}
+	// Base words and a target word for the modes that need one
+	words := []*st.Word{
+		{Word: "fire", Depth: 0, Reachability: 1},
+		{Word: "water", Depth: 0, Reachability: 1},
+		{Word: "earth", Depth: 0, Reachability: 1},
+		{Word: "wind", Depth: 0, Reachability: 1},
+		{Word: "steam", Depth: 3, Reachability: 0.125},
+	}
+	for _, word := range words {
+		if err := store.AddWord(ctx, word); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &config.Config{Client: "*", Events: sse.DefaultConfig(), Hint: g.HintConfig{Cost: 5}}
+	for _, fn := range configure {
+		fn(cfg)
+	}
+	api := NewAPIServer(cfg, store, cb.NewOfflineCombiner(), g.NewLocalBackplane())
+	api.RegisterRoutes()
+	server := httptest.NewServer(api.router)
+	t.Cleanup(func() {
+		server.Close()
+		api.gameService.Shutdown(context.Background())
+	})
+	return &testServer{t: t, api: api, store: store, server: server}
+}
+
+// do sends body as JSON and decodes the response into out, if given
+func (ts *testServer) do(method, path, bearer string, body, out any) int {
+	ts.t.Helper()
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			ts.t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, ts.server.URL+path, &reader)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			ts.t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// login creates an online account and returns its access token
+func (ts *testServer) login(username string) string {
+	ts.t.Helper()
+	ctx := context.Background()
+	acc, err := st.NewAccount(username, "password")
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	acc.Status = c.ONLINE
+	if err := ts.store.CreateAccount(ctx, acc); err != nil {
+		ts.t.Fatal(err)
+	}
+	accountToken, _, err := token.CreateSession(ctx, ts.store, username)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	return accountToken
+}
+
+// createLobby creates an account and a lobby it owns, it returns the lobby code and the owner's player token
+func (ts *testServer) createLobby(owner string) (string, string) {
+	ts.t.Helper()
+	return ts.createLobbyFor(owner, ts.login(owner))
+}
+
+func (ts *testServer) createLobbyFor(owner, accountToken string) (string, string) {
+	ts.t.Helper()
+	resp := new(dto.CreateLobbyResponse)
+	if status := ts.do(http.MethodPost, "/lobbies", accountToken, dto.CreateLobbyRequest{Name: owner + "'s lobby"}, resp); status != http.StatusOK {
+		ts.t.Fatalf("create lobby: status %d", status)
+	}
+	return resp.LobbyCode, resp.Token
+}
+
+// joinLobby adds a guest to the lobby and returns its player token
+func (ts *testServer) joinLobby(lobbyCode, playerName string) string {
+	ts.t.Helper()
+	resp := new(dto.JoinLobbyRespone)
+	if status := ts.do(http.MethodPut, "/lobbies", "", dto.JoinLobbyRequest{PlayerName: playerName, LobbyCode: lobbyCode}, resp); status != http.StatusOK {
+		ts.t.Fatalf("join lobby: status %d", status)
+	}
+	return resp.Token
+}
+
+func (ts *testServer) startGame(lobbyCode, owner, ownerToken string, req dto.StartGameRequest) {
+	ts.t.Helper()
+	if status := ts.do(http.MethodPost, "/games/"+lobbyCode+"/"+owner+"/game", ownerToken, req, nil); status != http.StatusOK {
+		ts.t.Fatalf("start %s: status %d", req.GameMode, status)
+	}
+}
+
+func (ts *testServer) words(lobbyCode, playerName, playerToken string) *dto.Words {
+	ts.t.Helper()
+	words := new(dto.Words)
+	if status := ts.do(http.MethodGet, "/games/"+lobbyCode+"/"+playerName+"/words", playerToken, nil, words); status != http.StatusOK {
+		ts.t.Fatalf("get words: status %d", status)
+	}
+	return words
+}
+
+func TestLobbyAndGameFlow(t *testing.T) {
+	ts := newTestServer(t)
+	lobbyCode, ownerToken := ts.createLobby("alice")
+	guestToken := ts.joinLobby(lobbyCode, "bob")
+
+	lobby := new(dto.LobbyDTO)
+	if status := ts.do(http.MethodGet, "/lobbies/"+lobbyCode+"/bob", guestToken, nil, lobby); status != http.StatusOK {
+		t.Fatalf("get lobby: status %d", status)
+	}
+	if len(lobby.Players) != 2 {
+		t.Fatalf("lobby has %d players, want 2", len(lobby.Players))
+	}
+
+	if status := ts.do(http.MethodPost, "/games/"+lobbyCode+"/bob/game", guestToken, dto.StartGameRequest{GameMode: c.VANILLA}, nil); status != http.StatusForbidden {
+		t.Fatalf("guest started the game: status %d, want %d", status, http.StatusForbidden)
+	}
+	ts.startGame(lobbyCode, "alice", ownerToken, dto.StartGameRequest{GameMode: c.VANILLA})
+
+	words := ts.words(lobbyCode, "bob", guestToken)
+	if len(words.Words) != 4 {
+		t.Fatalf("bob starts with %v, want the 4 base words", words.Words)
+	}
+	move := new(dto.WordResponse)
+	if status := ts.do(http.MethodPost, "/games/"+lobbyCode+"/bob/combinations", guestToken, dto.WordRequest{A: "Fire", B: "water"}, move); status != http.StatusOK {
+		t.Fatalf("combine: status %d", status)
+	}
+	if move.Result == "" || !move.IsNew {
+		t.Fatalf("combine returned %+v, want a new word", move)
+	}
+	if got := ts.words(lobbyCode, "bob", guestToken).Words; len(got) != 5 {
+		t.Fatalf("bob has %v after a move, want 5 words", got)
+	}
+
+	// Another player's token does not open bob's game
+	if status := ts.do(http.MethodPost, "/games/"+lobbyCode+"/bob/combinations", ownerToken, dto.WordRequest{A: "fire", B: "water"}, nil); status != http.StatusForbidden {
+		t.Fatalf("combine with the owner's token: status %d, want %d", status, http.StatusForbidden)
+	}
+}
+
+func TestUnknownLobby(t *testing.T) {
+	ts := newTestServer(t)
+	apiErr := new(dto.APIError)
+	if status := ts.do(http.MethodPut, "/lobbies", "", dto.JoinLobbyRequest{PlayerName: "bob", LobbyCode: "nope"}, apiErr); status != http.StatusNotFound {
+		t.Fatalf("join unknown lobby: status %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+func TestLogoutDeletesLobby(t *testing.T) {
+	ts := newTestServer(t)
+	accountToken := ts.login("alice")
+	lobbyCode, ownerToken := ts.createLobbyFor("alice", accountToken)
+	ts.startGame(lobbyCode, "alice", ownerToken, dto.StartGameRequest{GameMode: c.VANILLA})
+
+	if status := ts.do(http.MethodPost, "/logout", accountToken, nil, nil); status != http.StatusOK {
+		t.Fatalf("logout: status %d", status)
+	}
+	ctx := context.Background()
+	if _, err := ts.store.GetLobbyByCode(ctx, lobbyCode); err == nil {
+		t.Fatal("lobby of the logged out owner still exists")
+	}
+	acc, err := ts.store.GetAccountByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Status != c.OFFLINE || acc.IsOwner {
+		t.Fatalf("account after logout: status %s, owner %v", acc.Status, acc.IsOwner)
+	}
+	if status := ts.do(http.MethodPost, "/games/"+lobbyCode+"/alice/combinations", ownerToken, dto.WordRequest{A: "fire", B: "water"}, nil); status != http.StatusNotFound {
+		t.Fatalf("combine after logout: status %d, want %d", status, http.StatusNotFound)
+	}
+	// The session is revoked
+	if status := ts.do(http.MethodPost, "/logout", accountToken, nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("second logout: status %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func withHintCooldown(cfg *config.Config) {
+	cfg.Hint.Cooldown = time.Minute
+}
+
+func TestHint(t *testing.T) {
+	ts := newTestServer(t, withHintCooldown)
+	if err := ts.store.AddCombination(context.Background(), &st.Combination{A: "fire", B: "water", Result: "steam"}); err != nil {
+		t.Fatal(err)
+	}
+	lobbyCode, ownerToken := ts.createLobby("alice")
+	ts.startGame(lobbyCode, "alice", ownerToken, dto.StartGameRequest{GameMode: c.FUSION_FRENZY})
+	hintPath := "/games/" + lobbyCode + "/alice/hint"
+
+	hint := new(dto.HintResponse)
+	if status := ts.do(http.MethodPost, hintPath, ownerToken, nil, hint); status != http.StatusOK {
+		t.Fatalf("hint: status %d", status)
+	}
+	if hint.Result != "steam" || hint.Steps != 1 {
+		t.Fatalf("hint %+v, want fire + water = steam", hint)
+	}
+	if status := ts.do(http.MethodPost, hintPath, ownerToken, nil, nil); status != http.StatusTooManyRequests {
+		t.Fatalf("hint within the cooldown: status %d, want %d", status, http.StatusTooManyRequests)
+	}
+
+	if status := ts.do(http.MethodPost, "/games/"+lobbyCode+"/alice/combinations", ownerToken, dto.WordRequest{A: "fire", B: "water"}, nil); status != http.StatusOK {
+		t.Fatalf("combine: status %d", status)
+	}
+	if status := ts.do(http.MethodPost, hintPath, ownerToken, nil, nil); status != http.StatusConflict {
+		t.Fatalf("hint after the game was won: status %d, want %d", status, http.StatusConflict)
+	}
+}
+
+func TestHintWithoutRecipeKeepsCooldown(t *testing.T) {
+	ts := newTestServer(t, withHintCooldown)
+	lobbyCode, ownerToken := ts.createLobby("alice")
+	ts.startGame(lobbyCode, "alice", ownerToken, dto.StartGameRequest{GameMode: c.FUSION_FRENZY})
+	hintPath := "/games/" + lobbyCode + "/alice/hint"
+	for i := 0; i < 2; i++ {
+		if status := ts.do(http.MethodPost, hintPath, ownerToken, nil, nil); status != http.StatusNotFound {
+			t.Fatalf("hint %d without a recipe: status %d, want %d", i+1, status, http.StatusNotFound)
+		}
+	}
+}
+
+func TestCombineRequiresOwnedWords(t *testing.T) {
+	modes := []c.GameMode{c.VANILLA, c.WOMBO_COMBO, c.FUSION_FRENZY, c.DAILY_CHALLENGE, c.FINITE_FUSION}
+	for _, mode := range modes {
+		t.Run(string(mode), func(t *testing.T) {
+			ts := newTestServer(t)
+			lobbyCode, ownerToken := ts.createLobby("alice")
+			ts.startGame(lobbyCode, "alice", ownerToken, dto.StartGameRequest{GameMode: mode})
+			combinePath := "/games/" + lobbyCode + "/alice/combinations"
+
+			apiErr := new(dto.APIError)
+			if status := ts.do(http.MethodPost, combinePath, ownerToken, dto.WordRequest{A: "fire", B: "steam"}, apiErr); status != http.StatusForbidden {
+				t.Fatalf("combine with an unowned word: status %d, want %d", status, http.StatusForbidden)
+			}
+			if apiErr.Code != c.WORD_NOT_OWNED {
+				t.Fatalf("combine with an unowned word: code %s, want %s", apiErr.Code, c.WORD_NOT_OWNED)
+			}
+			if mode == c.FINITE_FUSION {
+				if uses := ts.words(lobbyCode, "alice", ownerToken).WordUses["fire"]; uses != 3 {
+					t.Fatalf("fire has %d uses left after a rejected move, want 3", uses)
+				}
+			}
+
+			move := new(dto.WordResponse)
+			if status := ts.do(http.MethodPost, combinePath, ownerToken, dto.WordRequest{A: "fire", B: "Earth"}, move); status != http.StatusOK {
+				t.Fatalf("combine with owned words: status %d", status)
+			}
+			if move.Result == "" {
+				t.Fatal("combine with owned words returned no result")
+			}
+		})
+	}
+}
+
+func TestGameEndsOnce(t *testing.T) {
+	ts := newTestServer(t)
+	lobbyCode, ownerToken := ts.createLobby("alice")
+	guestToken := ts.joinLobby(lobbyCode, "bob")
+	ts.startGame(lobbyCode, "alice", ownerToken, dto.StartGameRequest{GameMode: c.VANILLA})
+
+	end := "/games/" + lobbyCode + "/alice/end"
+	if status := ts.do(http.MethodPost, end, ownerToken, nil, nil); status != http.StatusOK {
+		t.Fatalf("end game: status %d", status)
+	}
+	if status := ts.do(http.MethodPost, end, ownerToken, nil, nil); status != http.StatusConflict {
+		t.Fatalf("end game twice: status %d, want %d", status, http.StatusConflict)
+	}
+	acc, err := ts.store.GetAccountByUsername(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Wins+acc.Losses != 1 {
+		t.Fatalf("alice has %d wins and %d losses, the game counts once", acc.Wins, acc.Losses)
+	}
+	if status := ts.do(http.MethodPost, "/games/"+lobbyCode+"/bob/combinations", guestToken, dto.WordRequest{A: "fire", B: "water"}, nil); status != http.StatusConflict {
+		t.Fatalf("combine after the end: status %d, want %d", status, http.StatusConflict)
+	}
+}
+
+func TestRegisterAndLoginErrors(t *testing.T) {
+	ts := newTestServer(t)
+	register := dto.RegisterRequest{Username: "alice", Password: "secret"}
+	if status := ts.do(http.MethodPost, "/accounts", "", register, nil); status != http.StatusCreated {
+		t.Fatalf("register: status %d", status)
+	}
+	apiErr := new(dto.APIError)
+	if status := ts.do(http.MethodPost, "/accounts", "", register, apiErr); status != http.StatusConflict || apiErr.Code != c.USERNAME_TAKEN {
+		t.Fatalf("register twice: status %d, code %s", status, apiErr.Code)
+	}
+
+	// An unknown username is answered like a wrong password
+	wrongPassword, unknownUser := new(dto.APIError), new(dto.APIError)
+	if status := ts.do(http.MethodPost, "/login", "", dto.LoginRequest{Username: "alice", Password: "wrong"}, wrongPassword); status != http.StatusUnauthorized {
+		t.Fatalf("login with a wrong password: status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := ts.do(http.MethodPost, "/login", "", dto.LoginRequest{Username: "nobody", Password: "wrong"}, unknownUser); status != http.StatusUnauthorized {
+		t.Fatalf("login as an unknown user: status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if *wrongPassword != *unknownUser {
+		t.Fatalf("login errors differ: %+v and %+v", wrongPassword, unknownUser)
+	}
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	ts := newTestServer(t)
+	if status := ts.do(http.MethodPost, "/accounts", "", dto.RegisterRequest{Username: "alice", Password: "secret"}, nil); status != http.StatusCreated {
+		t.Fatalf("register: status %d", status)
+	}
+	login := func() *dto.LoginResponse {
+		t.Helper()
+		resp := new(dto.LoginResponse)
+		if status := ts.do(http.MethodPost, "/login", "", dto.LoginRequest{Username: "alice", Password: "secret"}, resp); status != http.StatusOK {
+			t.Fatalf("login: status %d", status)
+		}
+		return resp
+	}
+	refresh := func(refreshToken string, want int) *dto.LoginResponse {
+		t.Helper()
+		resp := new(dto.LoginResponse)
+		if status := ts.do(http.MethodPost, "/refresh", "", dto.RefreshRequest{RefreshToken: refreshToken}, resp); status != want {
+			t.Fatalf("refresh: status %d, want %d", status, want)
+		}
+		return resp
+	}
+	phone, laptop := login(), login()
+	rotated := refresh(phone.RefreshToken, http.StatusOK)
+
+	// The refresh token of a logged out session is rejected, the other sessions stay
+	if status := ts.do(http.MethodPost, "/logout", laptop.Token, nil, nil); status != http.StatusOK {
+		t.Fatalf("logout: status %d", status)
+	}
+	refresh(laptop.RefreshToken, http.StatusUnauthorized)
+	rotated = refresh(rotated.RefreshToken, http.StatusOK)
+
+	// A rotated refresh token used again ends every session
+	refresh(phone.RefreshToken, http.StatusUnauthorized)
+	refresh(rotated.RefreshToken, http.StatusUnauthorized)
+}
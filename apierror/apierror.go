@@ -0,0 +1,145 @@
+package apierror
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	dto "github.com/na50r/wombo-combo-go-be/dto"
+)
+
+// Kind decides the HTTP status of an error, the zero value is Internal so unclassified errors never leak
+type Kind int
+
+const (
+	Internal     Kind = iota // Bug or failing dependency, details are only logged
+	NotFound                 // Lobby, game, account, ... does not exist
+	Unauthorized             // Missing or invalid credentials
+	Forbidden                // Valid credentials, but the action is not allowed
+	Conflict                 // Action clashes with the current state
+	Validation               // Request is malformed or breaks a rule
+	RateLimited              // Too many requests, try again later
+	Upstream                 // External service (combiner, random word API) failed or timed out
+	Timeout                  // Own dependency (database) did not answer in time
+)
+
+var statuses = map[Kind]int{
+	Internal:     http.StatusInternalServerError,
+	NotFound:     http.StatusNotFound,
+	Unauthorized: http.StatusUnauthorized,
+	Forbidden:    http.StatusForbidden,
+	Conflict:     http.StatusConflict,
+	Validation:   http.StatusBadRequest,
+	RateLimited:  http.StatusTooManyRequests,
+	Upstream:     http.StatusBadGateway,
+	Timeout:      http.StatusServiceUnavailable,
+}
+
+// Fallback codes for errors without a more specific one
+var codes = map[Kind]c.ErrorCode{
+	Internal:     c.INTERNAL,
+	NotFound:     c.NOT_FOUND,
+	Unauthorized: c.UNAUTHORIZED,
+	Forbidden:    c.FORBIDDEN,
+	Conflict:     c.CONFLICT,
+	Validation:   c.VALIDATION,
+	RateLimited:  c.RATE_LIMITED,
+	Upstream:     c.UPSTREAM,
+	Timeout:      c.TIMEOUT,
+}
+
+// Error is an error with a message that is safe to show to clients
+type Error struct {
+	Kind    Kind
+	Code    c.ErrorCode
+	Message string
+	Err     error // Cause, only logged
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Status() int {
+	return statuses[e.Kind]
+}
+
+func (e *Error) APIError() dto.APIError {
+	code := e.Code
+	if code == "" {
+		code = codes[e.Kind]
+	}
+	return dto.APIError{Error: e.Message, Code: code}
+}
+
+// WithCode sets a more specific code than the one of the kind
+func (e *Error) WithCode(code c.ErrorCode) *Error {
+	e.Code = code
+	return e
+}
+
+func newf(kind Kind, format string, args ...any) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+func NotFoundf(format string, args ...any) *Error {
+	return newf(NotFound, format, args...)
+}
+
+func Unauthorizedf(format string, args ...any) *Error {
+	return newf(Unauthorized, format, args...)
+}
+
+func Forbiddenf(format string, args ...any) *Error {
+	return newf(Forbidden, format, args...)
+}
+
+func Conflictf(format string, args ...any) *Error {
+	return newf(Conflict, format, args...)
+}
+
+func Validationf(format string, args ...any) *Error {
+	return newf(Validation, format, args...)
+}
+
+func RateLimitedf(format string, args ...any) *Error {
+	return newf(RateLimited, format, args...)
+}
+
+// Wrap classifies err, the message is shown to clients and err is kept for the logs
+func Wrap(kind Kind, err error, message string) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+// From returns the typed error in err's chain, anything else is Internal with a generic message.
+// Failures of external services are wrapped as Upstream where they are called, so an untyped
+// deadline comes from the storage (DB_TIMEOUT) and is a Timeout.
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return Wrap(NotFound, err, "Not found")
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Wrap(Timeout, err, "The request timed out, please try again")
+	}
+	return Wrap(Internal, err, "Something went wrong, please try again")
+}
+
+// Is reports whether err is a typed error of the given kind
+func Is(err error, kind Kind) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Kind == kind
+}
@@ -0,0 +1,32 @@
+package apierror
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestFrom(t *testing.T) {
+	combinerErr := Wrap(Upstream, context.DeadlineExceeded, "Unable to combine fire and water, please try again")
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"typed error", fmt.Errorf("join: %w", NotFoundf("lobby not found")), http.StatusNotFound},
+		{"no rows", fmt.Errorf("get account: %w", sql.ErrNoRows), http.StatusNotFound},
+		{"storage deadline", fmt.Errorf("get lobby: %w", context.DeadlineExceeded), http.StatusServiceUnavailable},
+		{"combiner deadline", combinerErr, http.StatusBadGateway},
+		{"untyped", errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := From(tt.err).Status(); got != tt.status {
+				t.Fatalf("status %d, want %d", got, tt.status)
+			}
+		})
+	}
+}
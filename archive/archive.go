@@ -0,0 +1,416 @@
+package archive
+
+// Snapshots of the stored data, to move it between backends or to debug production data locally.
+// An archive is a tar file, gzip compressed if its name ends with .gz, with these entries:
+//
+//	manifest.json              version of the format and rows per table, always the first entry
+//	<table>.jsonl              one JSON record per line, see records.go
+//	images/<name>              profile icons
+//	achievement_images/<name>  achievement icons
+//
+// Lobbies, players, running games and sessions are not archived.
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	ae "github.com/na50r/wombo-combo-go-be/apierror"
+	st "github.com/na50r/wombo-combo-go-be/storage"
+)
+
+// Version of the archive format, raise it when a record changes incompatibly
+const Version = 1
+
+const manifestFile = "manifest.json"
+
+// Tables in the order they are written and logged
+const (
+	Accounts          = "accounts"
+	Images            = "images"
+	AchievementImages = "achievement_images"
+	Combinations      = "combinations"
+	Words             = "words"
+	Achievements      = "achievements"
+	Unlocks           = "unlocks"
+	DailyWords        = "daily_words"
+	ChallengeEntries  = "challenge_entries"
+)
+
+var tables = []string{Accounts, Images, AchievementImages, Combinations, Words, Achievements, Unlocks, DailyWords, ChallengeEntries}
+
+type manifest struct {
+	Version   int            `json:"version"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Counts    map[string]int `json:"counts"`
+}
+
+// Rows per table
+type Counts map[string]int
+
+func (c Counts) Log(verb string) {
+	for _, table := range tables {
+		log.Printf("%s %6d %s", verb, c[table], table)
+	}
+}
+
+type file struct {
+	name string
+	data []byte
+}
+
+// Export writes every archived table of the store to path
+func Export(ctx context.Context, store st.Storage, path string) (Counts, error) {
+	accounts, err := store.GetAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	images, err := store.GetImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	achievementImages, err := store.GetAchievementImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	combinations, err := store.GetCombinations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	words, err := store.GetWords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	achievements, err := store.GetAchievements(ctx)
+	if err != nil {
+		return nil, err
+	}
+	unlocks, err := store.GetUnlocked(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dailyWords, err := store.GetDailyWords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := store.GetAllChallengeEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := Counts{
+		Accounts:          len(accounts),
+		Images:            len(images),
+		AchievementImages: len(achievementImages),
+		Combinations:      len(combinations),
+		Words:             len(words),
+		Achievements:      len(achievements),
+		Unlocks:           len(unlocks),
+		DailyWords:        len(dailyWords),
+		ChallengeEntries:  len(entries),
+	}
+	data, err := json.MarshalIndent(manifest{Version: Version, CreatedAt: time.Now().UTC(), Counts: counts}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	w := &files{list: []file{{manifestFile, data}}}
+	addTable(w, Accounts, accounts, fromAccount)
+	addTable(w, Combinations, combinations, fromCombination)
+	addTable(w, Words, words, fromWord)
+	addTable(w, Achievements, achievements, fromAchievement)
+	addTable(w, Unlocks, unlocks, fromUnlocked)
+	addTable(w, DailyWords, dailyWords, fromDailyWord)
+	addTable(w, ChallengeEntries, entries, fromChallenger)
+	if w.err != nil {
+		return nil, w.err
+	}
+	for _, image := range images {
+		w.list = append(w.list, file{Images + "/" + image.Name, image.Data})
+	}
+	for _, image := range achievementImages {
+		w.list = append(w.list, file{AchievementImages + "/" + image.Name, image.Data})
+	}
+	return counts, writeTar(path, w.list)
+}
+
+// files collects the entries of an archive and the first error of encoding them
+type files struct {
+	list []file
+	err  error
+}
+
+// addTable encodes the rows as one record per line
+func addTable[S, R any](w *files, table string, rows []S, record func(S) R) {
+	if w.err != nil {
+		return
+	}
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	for i, row := range rows {
+		if err := enc.Encode(record(row)); err != nil {
+			w.err = fmt.Errorf("%s row %d: %w", table, i+1, err)
+			return
+		}
+	}
+	w.list = append(w.list, file{table + ".jsonl", buf.Bytes()})
+}
+
+func writeTar(path string, files []file) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	var w io.Writer = out
+	var gz *gzip.Writer
+	if strings.HasSuffix(path, ".gz") {
+		gz = gzip.NewWriter(out)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	for _, f := range files {
+		header := &tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data)), ModTime: now, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+	return out.Close()
+}
+
+// Import loads an archive into the store, batchSize rows per transaction. Rows that exist already are overwritten,
+// so an archive can be imported again. Accounts are imported offline and keep their password hashes.
+func Import(ctx context.Context, store st.Storage, path string, batchSize int) (Counts, error) {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	buffered := bufio.NewReader(in)
+	var r io.Reader = buffered
+	// Compression is detected by the gzip magic number, not by the name
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	var expected *manifest
+	counts := Counts{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return counts, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if expected == nil {
+			if expected, err = readManifest(header.Name, tr); err != nil {
+				return counts, fmt.Errorf("%s: %w", path, err)
+			}
+			continue
+		}
+		table, count, err := restoreFile(ctx, store, header.Name, tr, batchSize)
+		counts[table] += count
+		if err != nil {
+			return counts, fmt.Errorf("%s: %s: %w", path, header.Name, err)
+		}
+	}
+	if expected == nil {
+		return counts, fmt.Errorf("%s: empty archive", path)
+	}
+	for _, table := range tables {
+		if counts[table] != expected.Counts[table] {
+			return counts, fmt.Errorf("%s: %s has %d of %d rows, the archive is incomplete", path, table, counts[table], expected.Counts[table])
+		}
+	}
+	return counts, nil
+}
+
+func readManifest(name string, r io.Reader) (*manifest, error) {
+	if name != manifestFile {
+		return nil, fmt.Errorf("expected %s as the first entry, got %s", manifestFile, name)
+	}
+	m := new(manifest)
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestFile, err)
+	}
+	if m.Version < 1 || m.Version > Version {
+		return nil, fmt.Errorf("archive version %d is not supported, this build reads up to version %d", m.Version, Version)
+	}
+	return m, nil
+}
+
+// restoreFile writes one entry of the archive and returns the table and the number of rows
+func restoreFile(ctx context.Context, store st.Storage, name string, r io.Reader, batchSize int) (string, int, error) {
+	if dir, imageName, ok := strings.Cut(name, "/"); ok {
+		if imageName == "" || strings.Contains(imageName, "/") || imageName == ".." {
+			return dir, 0, fmt.Errorf("invalid image name")
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return dir, 0, err
+		}
+		switch dir {
+		case Images:
+			return dir, 1, store.AddImage(ctx, data, imageName)
+		case AchievementImages:
+			return dir, 1, store.AddAchievementImage(ctx, data, imageName)
+		}
+	}
+	table := strings.TrimSuffix(name, ".jsonl")
+	var count int
+	var err error
+	switch table {
+	case Accounts:
+		count, err = restoreRows(ctx, store, r, batchSize, func(tx st.Storage, batch []*accountRecord) error {
+			return restoreAccounts(ctx, tx, batch)
+		})
+	case Combinations:
+		count, err = restoreRows(ctx, store, r, batchSize, func(tx st.Storage, batch []*combinationRecord) error {
+			return tx.UpdateCombinations(ctx, convert(batch, (*combinationRecord).combination))
+		})
+	case Words:
+		count, err = restoreRows(ctx, store, r, batchSize, func(tx st.Storage, batch []*wordRecord) error {
+			return tx.UpdateWords(ctx, convert(batch, (*wordRecord).word))
+		})
+	case Achievements:
+		count, err = restoreRows(ctx, store, r, batchSize, func(tx st.Storage, batch []*achievementRecord) error {
+			return tx.UpdateAchievements(ctx, convert(batch, (*achievementRecord).achievement))
+		})
+	case Unlocks:
+		count, err = restoreRows(ctx, store, r, batchSize, func(tx st.Storage, batch []*unlockRecord) error {
+			for _, record := range batch {
+				if _, err := tx.UnlockAchievement(ctx, record.Username, record.Title); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case DailyWords:
+		count, err = restoreRows(ctx, store, r, batchSize, func(tx st.Storage, batch []*dailyWordRecord) error {
+			for _, record := range batch {
+				daily, err := record.dailyWord()
+				if err != nil {
+					return err
+				}
+				if err := tx.AddDailyWord(ctx, daily); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case ChallengeEntries:
+		count, err = restoreRows(ctx, store, r, batchSize, func(tx st.Storage, batch []*challengeRecord) error {
+			for _, record := range batch {
+				entry, err := record.challenger()
+				if err != nil {
+					return err
+				}
+				if err := tx.AddChallengeEntry(ctx, entry); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	default:
+		// Written by a newer build of the same version, nothing this build knows how to restore
+		log.Printf("Skipping unknown archive entry %s", name)
+	}
+	return table, count, err
+}
+
+// restoreRows decodes one record per line and restores them in transactions of batchSize records
+func restoreRows[R any](ctx context.Context, store st.Storage, r io.Reader, batchSize int, restore func(tx st.Storage, batch []*R) error) (int, error) {
+	dec := json.NewDecoder(r)
+	batch := []*R{}
+	count := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := store.WithTx(ctx, func(tx st.Storage) error {
+			return restore(tx, batch)
+		})
+		batch = []*R{}
+		return err
+	}
+	for {
+		record := new(R)
+		err := dec.Decode(record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, fmt.Errorf("row %d: %w", count+1, err)
+		}
+		batch = append(batch, record)
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return count, err
+			}
+			count += batchSize
+		}
+	}
+	remaining := len(batch)
+	if err := flush(); err != nil {
+		return count, err
+	}
+	return count + remaining, nil
+}
+
+func convert[R, S any](records []*R, row func(*R) S) []S {
+	rows := make([]S, 0, len(records))
+	for _, record := range records {
+		rows = append(rows, row(record))
+	}
+	return rows
+}
+
+// Existing accounts are overwritten, the hash of the archive replaces the password
+func restoreAccounts(ctx context.Context, tx st.Storage, batch []*accountRecord) error {
+	for _, record := range batch {
+		acc := record.account()
+		_, err := tx.GetAccountByUsername(ctx, acc.Username)
+		if ae.Is(err, ae.NotFound) {
+			err = tx.CreateAccount(ctx, acc)
+		} else if err == nil {
+			err = tx.UpdateAccount(ctx, acc)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
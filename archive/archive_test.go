@@ -0,0 +1,206 @@
+package archive
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	st "github.com/na50r/wombo-combo-go-be/storage"
+)
+
+// tablesOf holds every archived table of a store, sorted where the store does not sort
+type tablesOf struct {
+	Accounts          []*st.Account
+	Images            []*st.Image
+	AchievementImages []*st.Image
+	Combinations      []*st.Combination
+	Words             []*st.Word
+	Achievements      []*st.AchievementEntry
+	Unlocks           []*st.Unlocked
+	DailyWords        []*st.DailyWord
+	ChallengeEntries  []*st.Challenger
+}
+
+func snapshot(t *testing.T, store st.Storage) *tablesOf {
+	t.Helper()
+	ctx := context.Background()
+	s := new(tablesOf)
+	var err error
+	check := func() {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Accounts, err = store.GetAccounts(ctx)
+	check()
+	s.Images, err = store.GetImages(ctx)
+	check()
+	slices.SortFunc(s.Images, func(a, b *st.Image) int { return cmp.Compare(a.Name, b.Name) })
+	s.AchievementImages, err = store.GetAchievementImages(ctx)
+	check()
+	s.Combinations, err = store.GetCombinations(ctx)
+	check()
+	slices.SortFunc(s.Combinations, func(a, b *st.Combination) int {
+		return cmp.Or(cmp.Compare(a.A, b.A), cmp.Compare(a.B, b.B))
+	})
+	s.Words, err = store.GetWords(ctx)
+	check()
+	slices.SortFunc(s.Words, func(a, b *st.Word) int { return cmp.Compare(a.Word, b.Word) })
+	s.Achievements, err = store.GetAchievements(ctx)
+	check()
+	slices.SortFunc(s.Achievements, func(a, b *st.AchievementEntry) int { return cmp.Compare(a.Title, b.Title) })
+	s.Unlocks, err = store.GetUnlocked(ctx)
+	check()
+	slices.SortFunc(s.Unlocks, func(a, b *st.Unlocked) int {
+		return cmp.Or(cmp.Compare(a.Username, b.Username), cmp.Compare(a.AchievmentTitle, b.AchievmentTitle))
+	})
+	s.DailyWords, err = store.GetDailyWords(ctx)
+	check()
+	s.ChallengeEntries, err = store.GetAllChallengeEntries(ctx)
+	check()
+	slices.SortFunc(s.ChallengeEntries, func(a, b *st.Challenger) int {
+		return cmp.Or(a.Timestamp.Compare(b.Timestamp), cmp.Compare(a.Username, b.Username))
+	})
+	return s
+}
+
+// seed fills every archived table with a few rows
+func seed(t *testing.T) *st.MemoryStore {
+	t.Helper()
+	ctx := context.Background()
+	store := st.NewMemoryStore()
+	must := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(store.AddImage(ctx, []byte("default"), "default.png"))
+	must(store.AddImage(ctx, []byte("cat"), "cat.png"))
+	must(store.AddAchievementImage(ctx, []byte("trophy"), "trophy.png"))
+	for _, name := range []string{"alice", "bob"} {
+		acc, err := st.NewAccount(name, "secret")
+		must(err)
+		acc.Wins, acc.Losses, acc.WordCount, acc.NewWordCount = 3, 2, 12, 4
+		must(store.CreateAccount(ctx, acc))
+	}
+	for _, word := range []*st.Word{
+		{Word: "fire", Depth: 0, Reachability: 1},
+		{Word: "water", Depth: 0, Reachability: 1},
+		{Word: "steam", Depth: 1, Reachability: 0.375},
+	} {
+		must(store.AddWord(ctx, word))
+	}
+	must(store.AddCombination(ctx, &st.Combination{A: "fire", B: "water", Result: "steam", Depth: 1}))
+	must(store.UpdateAchievements(ctx, []*st.AchievementEntry{
+		{Title: "Collector", Type: c.WordCount, Value: "10", Description: "Find 10 words", ImageName: "trophy.png"},
+		{Title: "Chemist", Type: c.TargetWord, Value: "steam", Description: "Make steam", ImageName: "trophy.png"},
+	}))
+	_, err := store.UnlockAchievement(ctx, "alice", "Collector")
+	must(err)
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	must(store.AddDailyWord(ctx, &st.DailyWord{Timestamp: day, Word: "steam"}))
+	must(store.AddChallengeEntry(ctx, &st.Challenger{Timestamp: day, WordCount: 7, Username: "bob"}))
+	return store
+}
+
+func quiet(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+}
+
+func TestRoundTrip(t *testing.T) {
+	quiet(t)
+	ctx := context.Background()
+	source := seed(t)
+	path := filepath.Join(t.TempDir(), "data.tar.gz")
+	exported, err := Export(ctx, source, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := snapshot(t, source)
+
+	// A second import overwrites the rows of the first without adding any
+	target := st.NewMemoryStore()
+	for i := 1; i <= 2; i++ {
+		imported, err := Import(ctx, target, path, 2)
+		if err != nil {
+			t.Fatalf("import %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(imported, exported) {
+			t.Fatalf("import %d restored %v rows, exported %v", i, imported, exported)
+		}
+		if got := snapshot(t, target); !reflect.DeepEqual(got, want) {
+			t.Fatalf("import %d restored %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestImportRejectsBrokenArchives(t *testing.T) {
+	quiet(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.tar.gz")
+	if _, err := Export(ctx, seed(t), path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(dir, "truncated.tar.gz")
+	if err := os.WriteFile(truncated, data[:len(data)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifestData := []byte(`{"version": 1, "counts": {"accounts": 1, "images": 1}}`)
+	accounts := []byte(`{"username": "alice", "passwordHash": "hash"}` + "\n")
+	incomplete := filepath.Join(dir, "incomplete.tar")
+	if err := writeTar(incomplete, []file{{manifestFile, manifestData}, {Accounts + ".jsonl", accounts}}); err != nil {
+		t.Fatal(err)
+	}
+	traversal := filepath.Join(dir, "traversal.tar")
+	if err := writeTar(traversal, []file{{manifestFile, manifestData}, {"images/../../etc/passwd", []byte("root")}}); err != nil {
+		t.Fatal(err)
+	}
+	newer := filepath.Join(dir, "newer.tar")
+	if err := writeTar(newer, []file{{manifestFile, []byte(`{"version": 99}`)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"truncated", truncated, "unexpected EOF"},
+		{"incomplete", incomplete, "the archive is incomplete"},
+		{"traversal", traversal, "invalid image name"},
+		{"newer version", newer, "version 99 is not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := st.NewMemoryStore()
+			_, err := Import(ctx, store, tt.path, 10)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got error %v, want %q", err, tt.want)
+			}
+			images, err := store.GetImages(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, image := range images {
+				if strings.Contains(image.Name, "/") || strings.Contains(image.Name, "..") {
+					t.Fatalf("restored image %q", image.Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package archive
+
+// Rows as they are written to the archive. The records are separate from the storage types, so the format of an
+// archive version stays the same when the schema changes.
+
+import (
+	"time"
+
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	st "github.com/na50r/wombo-combo-go-be/storage"
+)
+
+const dateLayout = "2006-01-02"
+
+// Status and lobby ownership are not archived, sessions and lobbies are not part of an archive
+type accountRecord struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"passwordHash"`
+	ImageName    string `json:"imageName"`
+	Wins         int    `json:"wins"`
+	Losses       int    `json:"losses"`
+	CreatedAt    string `json:"createdAt"`
+	NewWordCount int    `json:"newWordCount"`
+	WordCount    int    `json:"wordCount"`
+}
+
+type combinationRecord struct {
+	A      string `json:"a"`
+	B      string `json:"b"`
+	Result string `json:"result"`
+	Depth  int    `json:"depth"`
+}
+
+type wordRecord struct {
+	Word         string  `json:"word"`
+	Depth        int     `json:"depth"`
+	Reachability float64 `json:"reachability"`
+}
+
+type achievementRecord struct {
+	Title       string `json:"title"`
+	Type        string `json:"type"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+	ImageName   string `json:"imageName"`
+}
+
+type unlockRecord struct {
+	Username string `json:"username"`
+	Title    string `json:"title"`
+}
+
+type dailyWordRecord struct {
+	Date string `json:"date"`
+	Word string `json:"word"`
+}
+
+type challengeRecord struct {
+	Date      string `json:"date"`
+	Username  string `json:"username"`
+	WordCount int    `json:"wordCount"`
+}
+
+func fromAccount(acc *st.Account) *accountRecord {
+	return &accountRecord{
+		Username:     acc.Username,
+		PasswordHash: acc.Password,
+		ImageName:    acc.ImageName,
+		Wins:         acc.Wins,
+		Losses:       acc.Losses,
+		CreatedAt:    acc.CreatedAt,
+		NewWordCount: acc.NewWordCount,
+		WordCount:    acc.WordCount,
+	}
+}
+
+func (r *accountRecord) account() *st.Account {
+	return &st.Account{
+		Username:     r.Username,
+		Password:     r.PasswordHash,
+		ImageName:    r.ImageName,
+		Wins:         r.Wins,
+		Losses:       r.Losses,
+		CreatedAt:    r.CreatedAt,
+		Status:       c.OFFLINE,
+		NewWordCount: r.NewWordCount,
+		WordCount:    r.WordCount,
+	}
+}
+
+func fromCombination(combi *st.Combination) *combinationRecord {
+	return &combinationRecord{A: combi.A, B: combi.B, Result: combi.Result, Depth: combi.Depth}
+}
+
+func (r *combinationRecord) combination() *st.Combination {
+	return &st.Combination{A: r.A, B: r.B, Result: r.Result, Depth: r.Depth}
+}
+
+func fromWord(word *st.Word) *wordRecord {
+	return &wordRecord{Word: word.Word, Depth: word.Depth, Reachability: word.Reachability}
+}
+
+func (r *wordRecord) word() *st.Word {
+	return &st.Word{Word: r.Word, Depth: r.Depth, Reachability: r.Reachability}
+}
+
+func fromAchievement(entry *st.AchievementEntry) *achievementRecord {
+	return &achievementRecord{
+		Title:       entry.Title,
+		Type:        string(entry.Type),
+		Value:       entry.Value,
+		Description: entry.Description,
+		ImageName:   entry.ImageName,
+	}
+}
+
+func (r *achievementRecord) achievement() *st.AchievementEntry {
+	return &st.AchievementEntry{
+		Title:       r.Title,
+		Type:        c.Achievement(r.Type),
+		Value:       r.Value,
+		Description: r.Description,
+		ImageName:   r.ImageName,
+	}
+}
+
+func fromUnlocked(unlocked *st.Unlocked) *unlockRecord {
+	return &unlockRecord{Username: unlocked.Username, Title: unlocked.AchievmentTitle}
+}
+
+func fromDailyWord(daily *st.DailyWord) *dailyWordRecord {
+	return &dailyWordRecord{Date: daily.Timestamp.Format(dateLayout), Word: daily.Word}
+}
+
+func (r *dailyWordRecord) dailyWord() (*st.DailyWord, error) {
+	date, err := time.Parse(dateLayout, r.Date)
+	return &st.DailyWord{Timestamp: date, Word: r.Word}, err
+}
+
+func fromChallenger(entry *st.Challenger) *challengeRecord {
+	return &challengeRecord{Date: entry.Timestamp.Format(dateLayout), Username: entry.Username, WordCount: entry.WordCount}
+}
+
+func (r *challengeRecord) challenger() (*st.Challenger, error) {
+	date, err := time.Parse(dateLayout, r.Date)
+	return &st.Challenger{Timestamp: date, Username: r.Username, WordCount: r.WordCount}, err
+}
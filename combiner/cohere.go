@@ -0,0 +1,73 @@
+package combiner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	dto "github.com/na50r/wombo-combo-go-be/dto"
+	u "github.com/na50r/wombo-combo-go-be/utility"
+)
+
+const cohereURL = "https://api.cohere.ai/v2/chat"
+
+type CohereCombiner struct {
+	apiKey string
+	model  string
+	client *http.Client
+}
+
+func NewCohereCombiner(apiKey string) *CohereCombiner {
+	return &CohereCombiner{
+		apiKey: apiKey,
+		model:  "command-r",
+		client: &http.Client{},
+	}
+}
+
+func (cc *CohereCombiner) Combine(ctx context.Context, a, b string) (string, error) {
+	log.Println("Calling Cohere API")
+	body := map[string]interface{}{
+		"model": cc.model,
+		"messages": []map[string]string{
+			{
+				"role":    "user",
+				"content": prompt(a, b),
+			},
+		},
+	}
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cohereURL, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+cc.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := cc.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Cohere API returned status %d", resp.StatusCode)
+	}
+	var apiResponse dto.CohereResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
+		return "", err
+	}
+	if len(apiResponse.Message.Content) == 0 {
+		return "", fmt.Errorf("empty response from Cohere API")
+	}
+	newWord := u.FormatWord(apiResponse.Message.Content[0].Text)
+	if newWord == "" {
+		return "", fmt.Errorf("no word in response from Cohere API")
+	}
+	return newWord, nil
+}
@@ -0,0 +1,61 @@
+package combiner
+
+// Combiners come up with a new word for a pair of words that is not in the database yet
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+type Combiner interface {
+	Combine(ctx context.Context, a, b string) (string, error)
+}
+
+type Config struct {
+	Provider      string // COHERE, OPENAI or OFFLINE
+	CohereAPIKey  string
+	OpenAIBaseURL string
+	OpenAIAPIKey  string
+	OpenAIModel   string
+	Timeout       time.Duration // Deadline of a single call, applied with WithTimeout, 0 disables it
+}
+
+// New creates the configured combiner
+func New(config Config) (Combiner, error) {
+	log.Printf("Using combiner [%s]", config.Provider)
+	if config.Provider == "COHERE" {
+		return NewCohereCombiner(config.CohereAPIKey), nil
+	}
+	if config.Provider == "OPENAI" {
+		return NewOpenAICombiner(config.OpenAIBaseURL, config.OpenAIAPIKey, config.OpenAIModel), nil
+	}
+	if config.Provider == "OFFLINE" {
+		return NewOfflineCombiner(), nil
+	}
+	return nil, fmt.Errorf("Combiner [%s] not found", config.Provider)
+}
+
+type timeoutCombiner struct {
+	combiner Combiner
+	timeout  time.Duration
+}
+
+// WithTimeout bounds every call of combiner, a slow LLM fails the combination instead of holding the request
+func WithTimeout(combiner Combiner, timeout time.Duration) Combiner {
+	if timeout <= 0 {
+		return combiner
+	}
+	return &timeoutCombiner{combiner: combiner, timeout: timeout}
+}
+
+func (tc *timeoutCombiner) Combine(ctx context.Context, a, b string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, tc.timeout)
+	defer cancel()
+	return tc.combiner.Combine(ctx, a, b)
+}
+
+func prompt(a, b string) string {
+	return fmt.Sprintf("Given two words, come up with a new word that makes logical sense based on the two initial ones. Respond with nothing else but the new word. Example: Fire + Water = Steam\n\n Task: %s + %s = ?", a, b)
+}
@@ -0,0 +1,29 @@
+package combiner
+
+// Deterministic combiner without network access, for tests and air-gapped deployments
+
+import (
+	"context"
+	"fmt"
+
+	u "github.com/na50r/wombo-combo-go-be/utility"
+)
+
+type OfflineCombiner struct{}
+
+func NewOfflineCombiner() *OfflineCombiner {
+	return &OfflineCombiner{}
+}
+
+// Combine builds a portmanteau from the first half of a and the second half of b,
+// the pair is sorted first so that a + b and b + a give the same word
+func (oc *OfflineCombiner) Combine(ctx context.Context, a, b string) (string, error) {
+	a, b = u.SortAB(u.FormatWord(a), u.FormatWord(b))
+	// Halves are counted in runes, so that a multi-byte character is never cut
+	ra, rb := []rune(a), []rune(b)
+	newWord := string(ra[:(len(ra)+1)/2]) + string(rb[len(rb)/2:])
+	if newWord == "" {
+		return "", fmt.Errorf("cannot combine %q and %q", a, b)
+	}
+	return newWord, nil
+}
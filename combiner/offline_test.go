@@ -0,0 +1,34 @@
+package combiner
+
+import (
+	"context"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestOfflineCombine(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{"fire", "water", "fiter"},
+		{"Water", "FIRE", "fiter"},
+		{"steam", "earth", "eaream"},
+		// Multi-byte letters are dropped by FormatWord, the result stays valid UTF-8
+		{"crème", "brûlée", "brme"},
+		{"über", "öl", "bel"},
+	}
+	oc := NewOfflineCombiner()
+	for _, tt := range tests {
+		got, err := oc.Combine(context.Background(), tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("Combine(%q, %q): %v", tt.a, tt.b, err)
+		}
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("Combine(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if _, err := oc.Combine(context.Background(), "ß", "ü"); err == nil {
+		t.Error("combined two words without letters")
+	}
+}
@@ -0,0 +1,80 @@
+package combiner
+
+// Works with any endpoint that speaks the OpenAI chat completions protocol,
+// e.g. a local llama.cpp, vLLM or Ollama server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	dto "github.com/na50r/wombo-combo-go-be/dto"
+	u "github.com/na50r/wombo-combo-go-be/utility"
+)
+
+type OpenAICombiner struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+// The API key is optional since local model servers usually do not require one
+func NewOpenAICombiner(baseURL, apiKey, model string) *OpenAICombiner {
+	return &OpenAICombiner{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client:  &http.Client{},
+	}
+}
+
+func (oc *OpenAICombiner) Combine(ctx context.Context, a, b string) (string, error) {
+	log.Printf("Calling chat completions API at %s", oc.baseURL)
+	body := map[string]interface{}{
+		"model": oc.model,
+		"messages": []map[string]string{
+			{
+				"role":    "user",
+				"content": prompt(a, b),
+			},
+		},
+	}
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, oc.baseURL+"/chat/completions", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return "", err
+	}
+	if oc.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+oc.apiKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := oc.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("chat completions API returned status %d", resp.StatusCode)
+	}
+	var apiResponse dto.ChatCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
+		return "", err
+	}
+	if len(apiResponse.Choices) == 0 {
+		return "", fmt.Errorf("empty response from chat completions API")
+	}
+	newWord := u.FormatWord(apiResponse.Choices[0].Message.Content)
+	if newWord == "" {
+		return "", fmt.Errorf("no word in response from chat completions API")
+	}
+	return newWord, nil
+}
@@ -0,0 +1,309 @@
+package config
+
+// Typed configuration of the server.
+// Settings are read from the environment (and .env), an optional YAML or JSON file and flags,
+// later sources override earlier ones: env < file < flags.
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	cb "github.com/na50r/wombo-combo-go-be/combiner"
+	g "github.com/na50r/wombo-combo-go-be/game"
+	"github.com/na50r/wombo-combo-go-be/sse"
+	st "github.com/na50r/wombo-combo-go-be/storage"
+	t "github.com/na50r/wombo-combo-go-be/token"
+	"gopkg.in/yaml.v3"
+)
+
+// Timeouts of the HTTP server, 0 disables a timeout
+type HTTP struct {
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration // SSE streams lift it for their connection, WebSockets are not affected
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration // Time running requests get to finish on shutdown
+}
+
+type Config struct {
+	Client            string // Origin allowed by CORS
+	Port              string
+	HTTP              HTTP
+	Storage           st.Config
+	Combiner          cb.Config
+	Token             t.Config
+	Events            sse.Config
+	Hint              g.HintConfig
+	RecomputeInterval time.Duration // Time between recomputing depth and reachability of all words, 0 disables it
+	Backplane         string        // LOCAL or POSTGRES
+	Seed              st.SeedConfig
+}
+
+type setting struct {
+	key          string // Name of the environment variable and key in the config file
+	defaultValue string
+	usage        string
+}
+
+var settings = []setting{
+	{"CLIENT", "", "origin of the client, allowed by CORS"},
+	{"PORT", "3030", "port of the HTTP server"},
+	{"HTTP_READ_TIMEOUT", "15s", "time to read a request"},
+	{"HTTP_WRITE_TIMEOUT", "60s", "time to write a response, event streams are not affected"},
+	{"HTTP_IDLE_TIMEOUT", "120s", "time an idle keep-alive connection stays open"},
+	{"SHUTDOWN_TIMEOUT", "15s", "time running requests get to finish on shutdown"},
+	{"DB", "", "storage backend: SQLITE, POSTGRES or MEMORY"},
+	{"POSTGRES_CONNECTION", "", "connection string, required by DB or BACKPLANE POSTGRES"},
+	{"DB_TIMEOUT", "5s", "deadline of a single storage operation, 0 disables it"},
+	{"COMBINER", "COHERE", "combiner: COHERE, OPENAI or OFFLINE"},
+	{"COHERE_API_KEY", "", "API key, required by COMBINER COHERE"},
+	{"OPENAI_BASE_URL", "", "base URL, required by COMBINER OPENAI"},
+	{"OPENAI_API_KEY", "", "API key of COMBINER OPENAI, optional"},
+	{"OPENAI_MODEL", "", "model, required by COMBINER OPENAI"},
+	{"COMBINER_TIMEOUT", "30s", "deadline of a single combiner call, 0 disables it"},
+	{"JWT_SECRET", "", "secret that signs all tokens"},
+	{"SSE_BUFFER_SIZE", "", "messages buffered per client"},
+	{"SSE_SLOW_CONSUMER", "", "what happens to a client with a full buffer: DROP or DISCONNECT"},
+	{"SSE_HISTORY_SIZE", "", "messages kept per lobby for Last-Event-ID replay"},
+	{"SSE_HEARTBEAT", "", "time between heartbeats, 0 disables them"},
+	{"SSE_HEARTBEAT_STYLE", "", "heartbeat style: COMMENT or PING"},
+	{"HINT_COST", "5", "points a hint costs"},
+	{"HINT_COOLDOWN", "30s", "time between two hints of a player, 0 disables the limit"},
+	{"RECOMPUTE_INTERVAL", "0", "time between recomputing depth and reachability of all words, 0 disables it"},
+	{"BACKPLANE", "LOCAL", "fan out of events between instances: LOCAL or POSTGRES"},
+	{"WORDS", "", "CSV file of words, required by --seed"},
+	{"COMBINATIONS", "", "CSV file of combinations, required by --seed"},
+	{"ICONS", "", "directory of player icons, required by --seed"},
+	{"ACHIEVEMENTS", "", "CSV file of achievements, required by --seed"},
+	{"ACHIEVEMENT_ICONS", "", "directory of achievement icons, required by --seed"},
+}
+
+// flagName turns DB_TIMEOUT into db-timeout
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+type Loader struct {
+	file  *string
+	flags map[string]*string
+	fs    *flag.FlagSet
+}
+
+// NewLoader registers --config and a flag for every setting, Load has to be called after parsing fs
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{
+		file:  fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or JSON file with settings, keys are the names of the environment variables"),
+		flags: make(map[string]*string),
+		fs:    fs,
+	}
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.key)
+		if s.defaultValue != "" {
+			usage = fmt.Sprintf("%s (env %s, default %s)", s.usage, s.key, s.defaultValue)
+		}
+		l.flags[s.key] = fs.String(flagName(s.key), "", usage)
+	}
+	return l
+}
+
+// Load collects the settings and validates all of them, every problem is reported in the returned error.
+// The seed files are only required when seeding.
+func (l *Loader) Load(seeding bool) (*Config, error) {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, continuing...")
+	}
+	values := make(map[string]string)
+	for _, s := range settings {
+		values[s.key] = os.Getenv(s.key)
+	}
+	if *l.file != "" {
+		if err := readFile(*l.file, values); err != nil {
+			return nil, err
+		}
+	}
+	l.fs.Visit(func(f *flag.Flag) {
+		for key, value := range l.flags {
+			if flagName(key) == f.Name {
+				values[key] = *value
+			}
+		}
+	})
+	for _, s := range settings {
+		if values[s.key] == "" {
+			values[s.key] = s.defaultValue
+		}
+	}
+	return parse(values, seeding)
+}
+
+// YAML is a superset of JSON, one parser reads both
+func readFile(path string, values map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read config file: %w", err)
+	}
+	file := make(map[string]any)
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("Unable to parse config file %s: %w", path, err)
+	}
+	errs := []error{}
+	for key, value := range file {
+		if _, ok := values[key]; !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %s", path, key))
+			continue
+		}
+		if value != nil {
+			values[key] = fmt.Sprint(value)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parser records every error, so that all of them can be reported at once
+type parser struct {
+	values map[string]string
+	errs   []error
+}
+
+func (p *parser) fail(format string, args ...any) {
+	p.errs = append(p.errs, fmt.Errorf(format, args...))
+}
+
+func (p *parser) required(key string) string {
+	value := p.values[key]
+	if value == "" {
+		p.fail("%s not set", key)
+	}
+	return value
+}
+
+func (p *parser) oneOf(key string, options ...string) string {
+	value := p.values[key]
+	for _, option := range options {
+		if value == option {
+			return value
+		}
+	}
+	if value == "" {
+		p.fail("%s not set", key)
+	} else {
+		p.fail("%s [%s] must be one of %s", key, value, strings.Join(options, ", "))
+	}
+	return value
+}
+
+// duration parses a non-negative duration, 0 usually disables what it configures
+func (p *parser) duration(key string, fallback time.Duration) time.Duration {
+	value := p.values[key]
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		p.fail("%s [%s] must be a duration like 5s, 0 disables it", key, value)
+		return fallback
+	}
+	return duration
+}
+
+func (p *parser) number(key string, min, fallback int) int {
+	value := p.values[key]
+	if value == "" {
+		return fallback
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < min {
+		p.fail("%s [%s] must be a number of at least %d", key, value, min)
+		return fallback
+	}
+	return number
+}
+
+func parse(values map[string]string, seeding bool) (*Config, error) {
+	p := &parser{values: values}
+	config := &Config{
+		Client: p.required("CLIENT"),
+		Port:   p.required("PORT"),
+		HTTP: HTTP{
+			ReadTimeout:     p.duration("HTTP_READ_TIMEOUT", 0),
+			WriteTimeout:    p.duration("HTTP_WRITE_TIMEOUT", 0),
+			IdleTimeout:     p.duration("HTTP_IDLE_TIMEOUT", 0),
+			ShutdownTimeout: p.duration("SHUTDOWN_TIMEOUT", 0),
+		},
+		Storage: st.Config{
+			Driver:             p.oneOf("DB", "SQLITE", "POSTGRES", "MEMORY"),
+			PostgresConnection: values["POSTGRES_CONNECTION"],
+			SQLiteName:         "store",
+			Timeout:            p.duration("DB_TIMEOUT", 0),
+		},
+		Combiner: cb.Config{
+			Provider:      p.oneOf("COMBINER", "COHERE", "OPENAI", "OFFLINE"),
+			CohereAPIKey:  values["COHERE_API_KEY"],
+			OpenAIBaseURL: values["OPENAI_BASE_URL"],
+			OpenAIAPIKey:  values["OPENAI_API_KEY"],
+			OpenAIModel:   values["OPENAI_MODEL"],
+			Timeout:       p.duration("COMBINER_TIMEOUT", 0),
+		},
+		Token: t.Config{Secret: p.required("JWT_SECRET")},
+		Hint: g.HintConfig{
+			Cost:     p.number("HINT_COST", 0, 0),
+			Cooldown: p.duration("HINT_COOLDOWN", 0),
+		},
+		RecomputeInterval: p.duration("RECOMPUTE_INTERVAL", 0),
+		Backplane:         p.oneOf("BACKPLANE", "LOCAL", "POSTGRES"),
+		Seed: st.SeedConfig{
+			Words:            values["WORDS"],
+			Combinations:     values["COMBINATIONS"],
+			Icons:            values["ICONS"],
+			Achievements:     values["ACHIEVEMENTS"],
+			AchievementIcons: values["ACHIEVEMENT_ICONS"],
+		},
+	}
+	if seeding {
+		for _, key := range []string{"WORDS", "COMBINATIONS", "ICONS", "ACHIEVEMENTS", "ACHIEVEMENT_ICONS"} {
+			p.required(key)
+		}
+	}
+
+	defaults := sse.DefaultConfig()
+	config.Events = sse.Config{
+		BufferSize:        p.number("SSE_BUFFER_SIZE", 1, defaults.BufferSize),
+		Policy:            sse.SlowConsumerPolicy(values["SSE_SLOW_CONSUMER"]),
+		HistorySize:       p.number("SSE_HISTORY_SIZE", 0, defaults.HistorySize),
+		HeartbeatInterval: p.duration("SSE_HEARTBEAT", defaults.HeartbeatInterval),
+		HeartbeatStyle:    sse.HeartbeatStyle(values["SSE_HEARTBEAT_STYLE"]),
+	}
+	if config.Events.Policy == "" {
+		config.Events.Policy = defaults.Policy
+	} else {
+		p.oneOf("SSE_SLOW_CONSUMER", string(sse.DropMessages), string(sse.DisconnectClient))
+	}
+	if config.Events.HeartbeatStyle == "" {
+		config.Events.HeartbeatStyle = defaults.HeartbeatStyle
+	} else {
+		p.oneOf("SSE_HEARTBEAT_STYLE", string(sse.HeartbeatComment), string(sse.HeartbeatPing))
+	}
+
+	if config.Storage.Driver == "POSTGRES" && config.Storage.PostgresConnection == "" {
+		p.fail("POSTGRES_CONNECTION not set, DB [POSTGRES] requires it")
+	}
+	if config.Backplane == "POSTGRES" && config.Storage.Driver != "POSTGRES" {
+		p.fail("BACKPLANE [POSTGRES] requires DB [POSTGRES]")
+	}
+	if config.Combiner.Provider == "COHERE" && config.Combiner.CohereAPIKey == "" {
+		p.fail("COHERE_API_KEY not set, COMBINER [COHERE] requires it")
+	}
+	if config.Combiner.Provider == "OPENAI" && (config.Combiner.OpenAIBaseURL == "" || config.Combiner.OpenAIModel == "") {
+		p.fail("OPENAI_BASE_URL and OPENAI_MODEL must be set, COMBINER [OPENAI] requires them")
+	}
+	if len(p.errs) > 0 {
+		return nil, errors.Join(p.errs...)
+	}
+	return config, nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func validValues() map[string]string {
+	values := make(map[string]string)
+	for _, s := range settings {
+		values[s.key] = s.defaultValue
+	}
+	values["CLIENT"] = "http://localhost:5173"
+	values["DB"] = "MEMORY"
+	values["COMBINER"] = "OFFLINE"
+	values["JWT_SECRET"] = "secret"
+	return values
+}
+
+func TestSeedFilesOnlyRequiredWhenSeeding(t *testing.T) {
+	if _, err := parse(validValues(), false); err != nil {
+		t.Fatalf("parse without seeding: %v", err)
+	}
+	values := validValues()
+	values["JWT_SECRET"] = ""
+	_, err := parse(values, true)
+	if err == nil {
+		t.Fatal("parse with seeding accepted missing seed files")
+	}
+	// Every problem is reported at once
+	for _, key := range []string{"JWT_SECRET", "WORDS", "COMBINATIONS", "ICONS", "ACHIEVEMENTS", "ACHIEVEMENT_ICONS"} {
+		if !strings.Contains(err.Error(), key+" not set") {
+			t.Errorf("error does not report %s: %v", key, err)
+		}
+	}
+}
@@ -1,41 +1,81 @@
-package constants
-
-type EventMesage string
-type GameMode string
-type Status string 
-type Achievement string
-
-const (
-	LOBBY_CREATED EventMesage = "LOBBY_CREATED"
-	PLAYER_JOINED EventMesage = "PLAYER_JOINED"
-	GAME_STARTED  EventMesage = "GAME_STARTED"
-	GAME_DELETED  EventMesage = "GAME_DELETED"
-	LOBBY_DELETED EventMesage = "LOBBY_DELETED"
-	PLAYER_LEFT   EventMesage = "PLAYER_LEFT"
-	GAME_OVER     EventMesage = "GAME_OVER"
-	ACCOUNT_UPDATE EventMesage = "ACCOUNT_UPDATE"
-	WOMBO_COMBO_EVENT   EventMesage = "WOMBO_COMBO"
-	TIMER_STOPPED EventMesage = "TIMER_STOPPED"
-)
-
-const (
-	VANILLA GameMode = "Vanilla"
-	WOMBO_COMBO GameMode = "Wombo Combo"
-	FUSION_FRENZY GameMode = "Fusion Frenzy"
-	DAILY_CHALLENGE GameMode = "Daily Challenge"
-)
-
-const (
-	ONLINE Status = "ONLINE"
-	OFFLINE Status = "OFFLINE"
-)
-
-const (
-	Unauthorized string = "You are not authorized to perform this action."
-)
-
-const (
-	NewWordCount Achievement = "New Word Count"
-	WordCount Achievement = "Word Count"
-	TargetWord Achievement = "Target Word"
-)
\ No newline at end of file
+package constants
+
+type EventMesage string
+type GameMode string
+type Status string
+type Achievement string
+type ErrorCode string
+type WSCommand string
+type RevokeReason string
+
+const (
+	LOBBY_CREATED        EventMesage = "LOBBY_CREATED"
+	PLAYER_JOINED        EventMesage = "PLAYER_JOINED"
+	GAME_STARTED         EventMesage = "GAME_STARTED"
+	GAME_DELETED         EventMesage = "GAME_DELETED"
+	LOBBY_DELETED        EventMesage = "LOBBY_DELETED"
+	PLAYER_LEFT          EventMesage = "PLAYER_LEFT"
+	GAME_OVER            EventMesage = "GAME_OVER"
+	ACCOUNT_UPDATE       EventMesage = "ACCOUNT_UPDATE"
+	WOMBO_COMBO_EVENT    EventMesage = "WOMBO_COMBO"
+	TIMER_STOPPED        EventMesage = "TIMER_STOPPED"
+	SERVER_SHUTDOWN      EventMesage = "SERVER_SHUTDOWN"
+	TIME_LEFT            EventMesage = "TIME_LEFT"
+	GAME_EDITED          EventMesage = "GAME_EDITED"
+	ACHIEVEMENT_UNLOCKED EventMesage = "ACHIEVEMENT_UNLOCKED"
+	MESSAGE              EventMesage = "msg"
+)
+
+const (
+	VANILLA         GameMode = "Vanilla"
+	WOMBO_COMBO     GameMode = "Wombo Combo"
+	FUSION_FRENZY   GameMode = "Fusion Frenzy"
+	DAILY_CHALLENGE GameMode = "Daily Challenge"
+	FINITE_FUSION   GameMode = "Finite Fusion"
+)
+
+const (
+	ONLINE  Status = "ONLINE"
+	OFFLINE Status = "OFFLINE"
+)
+
+// Why a session was revoked, only a reused rotated refresh token ends all sessions of an account
+const (
+	ROTATED    RevokeReason = "ROTATED"
+	LOGGED_OUT RevokeReason = "LOGGED_OUT"
+	REUSED     RevokeReason = "REUSED"
+)
+
+const (
+	Unauthorized string = "You are not authorized to perform this action."
+)
+
+const (
+	INTERNAL            ErrorCode = "INTERNAL"
+	NOT_FOUND           ErrorCode = "NOT_FOUND"
+	UNAUTHORIZED        ErrorCode = "UNAUTHORIZED"
+	FORBIDDEN           ErrorCode = "FORBIDDEN"
+	CONFLICT            ErrorCode = "CONFLICT"
+	VALIDATION          ErrorCode = "VALIDATION"
+	RATE_LIMITED        ErrorCode = "RATE_LIMITED"
+	UPSTREAM            ErrorCode = "UPSTREAM"
+	TIMEOUT             ErrorCode = "TIMEOUT"
+	METHOD_NOT_ALLOWED  ErrorCode = "METHOD_NOT_ALLOWED"
+	WORD_NOT_OWNED      ErrorCode = "WORD_NOT_OWNED"
+	WORD_USED_UP        ErrorCode = "WORD_USED_UP"
+	INVALID_CREDENTIALS ErrorCode = "INVALID_CREDENTIALS"
+	SESSION_EXPIRED     ErrorCode = "SESSION_EXPIRED"
+	USERNAME_TAKEN      ErrorCode = "USERNAME_TAKEN"
+)
+
+const (
+	COMBINATION_COMMAND WSCommand = "combination"
+	LEAVE_COMMAND       WSCommand = "leave"
+	EDIT_MODE_COMMAND   WSCommand = "edit-mode"
+)
+
+const (
+	NewWordCount Achievement = "New Word Count"
+	WordCount    Achievement = "Word Count"
+	TargetWord   Achievement = "Target Word"
+)
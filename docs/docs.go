@@ -55,11 +55,35 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
                     "405": {
                         "description": "Method Not Allowed",
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             },
@@ -111,11 +135,35 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
                     "405": {
                         "description": "Method Not Allowed",
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             }
@@ -160,11 +208,35 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
                     "405": {
                         "description": "Method Not Allowed",
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             }
@@ -212,11 +284,35 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
                     "405": {
                         "description": "Method Not Allowed",
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             }
@@ -263,6 +359,18 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             }
@@ -315,7 +423,7 @@ const docTemplate = `{
         },
         "/events": {
             "get": {
-                "description": "Server-Sent Events",
+                "description": "Server-Sent Events, every event has an ID and is named after its type (e.g. GAME_STARTED, TIME_LEFT).\nPlayers that reconnect with the Last-Event-ID header (or lastEventId query parameter) get the missed events of their lobby replayed.",
                 "consumes": [
                     "application/json"
                 ],
@@ -348,6 +456,32 @@ const docTemplate = `{
                 }
             }
         },
+        "/events/stats": {
+            "get": {
+                "description": "Number of connected clients, messages dropped for slow clients and clients disconnected for being too slow",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "events"
+                ],
+                "summary": "Event delivery statistics",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/sse.Stats"
+                        }
+                    },
+                    "405": {
+                        "description": "Method Not Allowed",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    }
+                }
+            }
+        },
         "/games/{lobbyCode}/{playerName}/combinations": {
             "post": {
                 "security": [
@@ -404,11 +538,41 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
                     "405": {
                         "description": "Method Not Allowed",
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             }
@@ -460,11 +624,35 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
                     "405": {
                         "description": "Method Not Allowed",
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             }
@@ -516,11 +704,35 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
                     "405": {
                         "description": "Method Not Allowed",
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             },
@@ -579,11 +791,35 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
                     "405": {
                         "description": "Method Not Allowed",
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             },
@@ -633,11 +869,121 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "405": {
+                        "description": "Method Not Allowed",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/games/{lobbyCode}/{playerName}/hint": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Reveals the next combination of the shortest known recipe chain to the target word. Costs points, one hint per cooldown",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "game"
+                ],
+                "summary": "Get a hint",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Lobby code",
+                        "name": "lobbyCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Player name",
+                        "name": "playerName",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.HintResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
                     "405": {
                         "description": "Method Not Allowed",
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             }
@@ -682,11 +1028,35 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
                     "405": {
                         "description": "Method Not Allowed",
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             }
@@ -725,6 +1095,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             },
@@ -764,11 +1140,29 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.APIError"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
                     "405": {
                         "description": "Method Not Allowed",
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             },
@@ -813,11 +1207,29 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
                     "405": {
                         "description": "Method Not Allowed",
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             }
@@ -869,11 +1281,35 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
                     "405": {
                         "description": "Method Not Allowed",
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             }
@@ -934,11 +1370,35 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
                     "405": {
                         "description": "Method Not Allowed",
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             }
@@ -990,18 +1450,42 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
                     "405": {
                         "description": "Method Not Allowed",
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             }
         },
         "/login": {
             "post": {
-                "description": "Authenticates a user and returns a JWT token",
+                "description": "Authenticates a user and returns a short-lived JWT access token and a refresh token",
                 "consumes": [
                     "application/json"
                 ],
@@ -1036,11 +1520,23 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
                     "405": {
                         "description": "Method Not Allowed",
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             }
@@ -1052,7 +1548,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Logs out a user",
+                "description": "Logs out a user and revokes the session of the access token",
                 "consumes": [
                     "application/json"
                 ],
@@ -1076,30 +1572,180 @@ const docTemplate = `{
                             "$ref": "#/definitions/dto.APIError"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
                     "405": {
                         "description": "Method Not Allowed",
                         "schema": {
                             "$ref": "#/definitions/dto.APIError"
                         }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/refresh": {
+            "post": {
+                "description": "Exchanges a refresh token for a new access token and a new refresh token, the old refresh token is revoked",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Refresh an access token",
+                "parameters": [
+                    {
+                        "description": "Refresh token",
+                        "name": "refresh",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.RefreshRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.LoginResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "405": {
+                        "description": "Method Not Allowed",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/ws": {
+            "get": {
+                "description": "Authenticates once with the player token (Authorization header or token query parameter) and upgrades to a WebSocket.\nThe client sends commands of type combination, leave or edit-mode (dto.WSCommand) and receives replies (dto.WSReply) and the same game events as on /events (dto.WSEvent).",
+                "tags": [
+                    "events"
+                ],
+                "summary": "WebSocket for game traffic",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Player token",
+                        "name": "token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols",
+                        "schema": {
+                            "$ref": "#/definitions/dto.WSEvent"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.APIError"
+                        }
                     }
                 }
             }
         }
     },
     "definitions": {
+        "constants.ErrorCode": {
+            "type": "string",
+            "enum": [
+                "INTERNAL",
+                "NOT_FOUND",
+                "UNAUTHORIZED",
+                "FORBIDDEN",
+                "CONFLICT",
+                "VALIDATION",
+                "RATE_LIMITED",
+                "UPSTREAM",
+                "METHOD_NOT_ALLOWED",
+                "WORD_NOT_OWNED",
+                "WORD_USED_UP",
+                "INVALID_CREDENTIALS",
+                "SESSION_EXPIRED",
+                "USERNAME_TAKEN"
+            ],
+            "x-enum-varnames": [
+                "INTERNAL",
+                "NOT_FOUND",
+                "UNAUTHORIZED",
+                "FORBIDDEN",
+                "CONFLICT",
+                "VALIDATION",
+                "RATE_LIMITED",
+                "UPSTREAM",
+                "METHOD_NOT_ALLOWED",
+                "WORD_NOT_OWNED",
+                "WORD_USED_UP",
+                "INVALID_CREDENTIALS",
+                "SESSION_EXPIRED",
+                "USERNAME_TAKEN"
+            ]
+        },
         "constants.GameMode": {
             "type": "string",
             "enum": [
                 "Vanilla",
                 "Wombo Combo",
                 "Fusion Frenzy",
-                "Daily Challenge"
+                "Daily Challenge",
+                "Finite Fusion"
             ],
             "x-enum-varnames": [
                 "VANILLA",
                 "WOMBO_COMBO",
                 "FUSION_FRENZY",
-                "DAILY_CHALLENGE"
+                "DAILY_CHALLENGE",
+                "FINITE_FUSION"
             ]
         },
         "constants.Status": {
@@ -1116,6 +1762,14 @@ const docTemplate = `{
         "dto.APIError": {
             "type": "object",
             "properties": {
+                "code": {
+                    "description": "Machine-readable error code, if any",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/constants.ErrorCode"
+                        }
+                    ]
+                },
                 "error": {
                     "type": "string"
                 }
@@ -1276,6 +1930,28 @@ const docTemplate = `{
                 }
             }
         },
+        "dto.HintResponse": {
+            "type": "object",
+            "properties": {
+                "a": {
+                    "type": "string"
+                },
+                "b": {
+                    "type": "string"
+                },
+                "cost": {
+                    "description": "Points deducted for the hint",
+                    "type": "integer"
+                },
+                "result": {
+                    "type": "string"
+                },
+                "steps": {
+                    "description": "Combinations left to the target word, including this one",
+                    "type": "integer"
+                }
+            }
+        },
         "dto.ImagesResponse": {
             "type": "object",
             "properties": {
@@ -1369,6 +2045,9 @@ const docTemplate = `{
         "dto.LoginResponse": {
             "type": "object",
             "properties": {
+                "refreshToken": {
+                    "type": "string"
+                },
                 "token": {
                     "type": "string"
                 }
@@ -1408,6 +2087,14 @@ const docTemplate = `{
                 }
             }
         },
+        "dto.RefreshRequest": {
+            "type": "object",
+            "properties": {
+                "refreshToken": {
+                    "type": "string"
+                }
+            }
+        },
         "dto.RegisterRequest": {
             "type": "object",
             "properties": {
@@ -1430,6 +2117,28 @@ const docTemplate = `{
                 },
                 "withTimer": {
                     "type": "boolean"
+                },
+                "wordUses": {
+                    "description": "Uses per word in Finite Fusion, defaults to 3",
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.WSEvent": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "object"
+                },
+                "event": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "type": {
+                    "description": "Always \"event\"",
+                    "type": "string"
                 }
             }
         },
@@ -1461,6 +2170,13 @@ const docTemplate = `{
                 "targetWord": {
                     "type": "string"
                 },
+                "wordUses": {
+                    "description": "Remaining uses per word, only set in Finite Fusion",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
                 "words": {
                     "type": "array",
                     "items": {
@@ -1474,6 +2190,22 @@ const docTemplate = `{
             "properties": {
                 "data": {}
             }
+        },
+        "sse.Stats": {
+            "type": "object",
+            "properties": {
+                "clients": {
+                    "type": "integer"
+                },
+                "dropped": {
+                    "description": "Messages dropped because a client's buffer was full",
+                    "type": "integer"
+                },
+                "evicted": {
+                    "description": "Clients disconnected for being too slow",
+                    "type": "integer"
+                }
+            }
         }
     },
     "securityDefinitions": {
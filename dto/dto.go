@@ -1,185 +1,236 @@
-package dto
-
-import (
-	c "github.com/na50r/wombo-combo-go-be/constants"
-)
-
-type CohereResponse struct {
-	ID      string `json:"id"`
-	Message struct {
-		Role    string `json:"role"`
-		Content []struct {
-			Type string `json:"type"`
-			Text string `json:"text"`
-		} `json:"content"`
-	} `json:"message"`
-}
-
-type APIError struct {
-	Error string `json:"error"`
-}
-
-type GenericResponse struct {
-	Message string `json:"message"`
-}
-
-type RegisterRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-}
-
-type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-}
-
-type LoginResponse struct {
-	Token string `json:"token"`
-}
-
-type AccountDTO struct {
-	Username  string `json:"username"`  // Username of the account
-	Wins      int    `json:"wins"`      // Number of wins
-	Losses    int    `json:"losses"`    // Number of losses
-	ImageName string `json:"imageName"` // Name of the user's profile image
-	Image     []byte `json:"image"`     // Base64-encoded image
-	CreatedAt string `json:"createdAt"` // ISO8601 creation timestamp
-	Status    c.Status `json:"status"`    // ONLINE or OFFLINE
-}
-
-type CreateLobbyRequest struct {
-	Name string `json:"name"`
-}
-
-type CreateLobbyResponse struct {
-	Token    string `json:"token"`
-	LobbyDTO `json:"lobby"`
-}
-
-type JoinLobbyRespone struct {
-	Token    string `json:"token"`
-	LobbyDTO `json:"lobby"`
-}
-
-type PlayerDTO struct {
-	Name  string `json:"name"`
-	Image []byte `json:"image"`
-}
-
-type LobbyDTO struct {
-	LobbyCode string       `json:"lobbyCode"`
-	Name      string       `json:"name"`
-	GameMode  c.GameMode     `json:"gameMode"`
-	Owner     string       `json:"owner"`
-	Players   []*PlayerDTO `json:"players"`
-	GameModes []c.GameMode   `json:"gameModes"`
-}
-
-type LobbiesDTO struct {
-	Image       []byte `json:"image"`
-	PlayerCount int    `json:"playerCount"`
-	LobbyCode   string `json:"lobbyCode"`
-}
-
-type UpdateAccountRequest struct {
-	Username  string `json:"username"`
-	Password  string `json:"password"`
-	ImageName string `json:"imageName"`
-}
-
-type ImagesResponse struct {
-	Names []string `json:"names"`
-}
-
-type ChangeImageRequest struct {
-	ImageName string `json:"imageName"`
-}
-
-type EditAccountRequest struct {
-	Type        string `json:"type"`
-	Username    string `json:"username"`
-	ImageName   string `json:"imageName"`
-	OldPassword string `json:"oldPassword"`
-	NewPassword string `json:"newPassword"`
-}
-
-type JoinLobbyRequest struct {
-	PlayerName string `json:"playerName"`
-	LobbyCode  string `json:"lobbyCode"`
-}
-
-type EditGameRequest struct {
-	GameMode c.GameMode `json:"gameMode"`
-	Duration int      `json:"duration"`
-}
-
-type GameEditEvent struct {
-	GameMode c.GameMode `json:"gameMode"`
-	Duration int      `json:"duration"`
-}
-
-type WordRequest struct {
-	A string `json:"a"`
-	B string `json:"b"`
-}
-
-type WordResponse struct {
-	Result string `json:"result"`
-	IsNew  bool   `json:"isNew"`
-}
-
-type Words struct {
-	Words      []string `json:"words"`
-	TargetWord string   `json:"targetWord"`
-}
-
-type StartGameRequest struct {
-	GameMode  c.GameMode `json:"gameMode"`
-	WithTimer bool     `json:"withTimer"`
-	Duration  int      `json:"duration"`
-}
-
-type PlayerWordCount struct {
-	PlayerName string `json:"playerName"`
-	WordCount  int    `json:"wordCount"`
-}
-
-type PlayerResultDTO struct {
-	PlayerName string `json:"playerName"`
-	Image      []byte `json:"image"`
-	WordCount  int    `json:"wordCount"`
-	Points     int    `json:"points"`
-}
-
-type GameEndResponse struct {
-	GameMode    c.GameMode           `json:"gameMode"`
-	Winner      string             `json:"winner"`
-	PlayerWords []*PlayerResultDTO `json:"playerResults"`
-	ManualEnd   bool               `json:"manualEnd"`
-}
-
-type TimeEvent struct {
-	SecondsLeft int `json:"secondsLeft"`
-}
-
-type AchievementEvent struct {
-	AchievementTitle string `json:"achievementTitle"`
-}
-
-type ChallengeEntryDTO struct {
-	WordCount int    `json:"wordCount"`
-	Username  string `json:"username"`
-	Image     []byte `json:"image"`
-}
-
-type AchievementDTO struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Image       []byte `json:"image"`
-	Unlocked    bool   `json:"unlocked"`
-}
-
-func NewGameModes() []c.GameMode {
-	return []c.GameMode{c.VANILLA, c.WOMBO_COMBO, c.FUSION_FRENZY, c.DAILY_CHALLENGE}
-}
-
+package dto
+
+import (
+	"encoding/json"
+
+	c "github.com/na50r/wombo-combo-go-be/constants"
+)
+
+type CohereResponse struct {
+	ID      string `json:"id"`
+	Message struct {
+		Role    string `json:"role"`
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	} `json:"message"`
+}
+
+type ChatCompletionResponse struct {
+	ID      string `json:"id"`
+	Choices []struct {
+		Message struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+type APIError struct {
+	Error string      `json:"error"`
+	Code  c.ErrorCode `json:"code,omitempty"` // Machine-readable error code, if any
+}
+
+type GenericResponse struct {
+	Message string `json:"message"`
+}
+
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type LoginResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type AccountDTO struct {
+	Username  string   `json:"username"`  // Username of the account
+	Wins      int      `json:"wins"`      // Number of wins
+	Losses    int      `json:"losses"`    // Number of losses
+	ImageName string   `json:"imageName"` // Name of the user's profile image
+	Image     []byte   `json:"image"`     // Base64-encoded image
+	CreatedAt string   `json:"createdAt"` // ISO8601 creation timestamp
+	Status    c.Status `json:"status"`    // ONLINE or OFFLINE
+}
+
+type CreateLobbyRequest struct {
+	Name string `json:"name"`
+}
+
+type CreateLobbyResponse struct {
+	Token    string `json:"token"`
+	LobbyDTO `json:"lobby"`
+}
+
+type JoinLobbyRespone struct {
+	Token    string `json:"token"`
+	LobbyDTO `json:"lobby"`
+}
+
+type PlayerDTO struct {
+	Name  string `json:"name"`
+	Image []byte `json:"image"`
+}
+
+type LobbyDTO struct {
+	LobbyCode string       `json:"lobbyCode"`
+	Name      string       `json:"name"`
+	GameMode  c.GameMode   `json:"gameMode"`
+	Owner     string       `json:"owner"`
+	Players   []*PlayerDTO `json:"players"`
+	GameModes []c.GameMode `json:"gameModes"`
+}
+
+type LobbiesDTO struct {
+	Image       []byte `json:"image"`
+	PlayerCount int    `json:"playerCount"`
+	LobbyCode   string `json:"lobbyCode"`
+}
+
+type UpdateAccountRequest struct {
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	ImageName string `json:"imageName"`
+}
+
+type ImagesResponse struct {
+	Names []string `json:"names"`
+}
+
+type ChangeImageRequest struct {
+	ImageName string `json:"imageName"`
+}
+
+type EditAccountRequest struct {
+	Type        string `json:"type"`
+	Username    string `json:"username"`
+	ImageName   string `json:"imageName"`
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+type JoinLobbyRequest struct {
+	PlayerName string `json:"playerName"`
+	LobbyCode  string `json:"lobbyCode"`
+}
+
+type EditGameRequest struct {
+	GameMode c.GameMode `json:"gameMode"`
+	Duration int        `json:"duration"`
+}
+
+type GameEditEvent struct {
+	GameMode c.GameMode `json:"gameMode"`
+	Duration int        `json:"duration"`
+}
+
+type WordRequest struct {
+	A string `json:"a"`
+	B string `json:"b"`
+}
+
+type WordResponse struct {
+	Result string `json:"result"`
+	IsNew  bool   `json:"isNew"`
+}
+
+// Next step of the shortest known recipe chain to the target word
+type HintResponse struct {
+	A      string `json:"a"`
+	B      string `json:"b"`
+	Result string `json:"result"`
+	Steps  int    `json:"steps"` // Combinations left to the target word, including this one
+	Cost   int    `json:"cost"`  // Points deducted for the hint
+}
+
+type Words struct {
+	Words      []string       `json:"words"`
+	TargetWord string         `json:"targetWord"`
+	WordUses   map[string]int `json:"wordUses,omitempty"` // Remaining uses per word, only set in Finite Fusion
+}
+
+type StartGameRequest struct {
+	GameMode  c.GameMode `json:"gameMode"`
+	WithTimer bool       `json:"withTimer"`
+	Duration  int        `json:"duration"`
+	WordUses  int        `json:"wordUses"` // Uses per word in Finite Fusion, defaults to 3
+}
+
+type PlayerWordCount struct {
+	PlayerName string `json:"playerName"`
+	WordCount  int    `json:"wordCount"`
+}
+
+type PlayerResultDTO struct {
+	PlayerName string `json:"playerName"`
+	Image      []byte `json:"image"`
+	WordCount  int    `json:"wordCount"`
+	Points     int    `json:"points"`
+}
+
+type GameEndResponse struct {
+	GameMode    c.GameMode         `json:"gameMode"`
+	Winner      string             `json:"winner"`
+	PlayerWords []*PlayerResultDTO `json:"playerResults"`
+	ManualEnd   bool               `json:"manualEnd"`
+}
+
+type TimeEvent struct {
+	SecondsLeft int `json:"secondsLeft"`
+}
+
+type AchievementEvent struct {
+	AchievementTitle string `json:"achievementTitle"`
+}
+
+// Command sent by a player over the WebSocket, the fields of the matching REST request are inlined
+type WSCommand struct {
+	Type      c.WSCommand `json:"type"`
+	RequestID string      `json:"requestId,omitempty"` // Echoed in the reply
+	WordRequest
+	EditGameRequest
+}
+
+type WSReply struct {
+	Type      string      `json:"type"` // Always "reply"
+	RequestID string      `json:"requestId,omitempty"`
+	Result    interface{} `json:"result,omitempty"`
+	Error     *APIError   `json:"error,omitempty"`
+}
+
+// Game event pushed over the WebSocket, same ID, name and data as on /events
+type WSEvent struct {
+	Type  string          `json:"type"` // Always "event"
+	ID    uint64          `json:"id"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data" swaggertype:"object"`
+}
+
+type ChallengeEntryDTO struct {
+	WordCount int    `json:"wordCount"`
+	Username  string `json:"username"`
+	Image     []byte `json:"image"`
+}
+
+type AchievementDTO struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Image       []byte `json:"image"`
+	Unlocked    bool   `json:"unlocked"`
+}
+
+func NewGameModes() []c.GameMode {
+	return []c.GameMode{c.VANILLA, c.WOMBO_COMBO, c.FUSION_FRENZY, c.DAILY_CHALLENGE, c.FINITE_FUSION}
+}
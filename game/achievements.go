@@ -1,140 +1,141 @@
-package game
-
-import (
-	"log"
-	"net/http"
-	"strconv"
-	"strings"
-	c "github.com/na50r/wombo-combo-go-be/constants"
-	dto "github.com/na50r/wombo-combo-go-be/dto"
-	u "github.com/na50r/wombo-combo-go-be/utility"
-
-)
-
-type AchievementMaps struct {
-	NewWordCount map[int]string // int milestone → achievement title
-	WordCount    map[int]string
-	TargetWord   map[string]string // word string → achievement title
-}
-
-func (server *GameService) SetupAchievements() error {
-	s := server.store
-	newWordCnt := map[int]string{}
-	wordCnt := map[int]string{}
-	targetWord := map[string]string{}
-	achievementEntries, err := s.GetAchievements()
-	if err != nil {
-		return err
-	}
-	for _, entry := range achievementEntries {
-		switch entry.Type {
-		case c.NewWordCount:
-			val, err := strconv.Atoi(entry.Value)
-			if err != nil {
-				return err
-			}
-			newWordCnt[val] = entry.Title
-		case c.WordCount:
-			val, err := strconv.Atoi(entry.Value)
-			if err != nil {
-				return err
-			}
-			wordCnt[val] = entry.Title
-		case c.TargetWord:
-			targetWord[entry.Value] = entry.Title
-		}
-	}
-	server.achievements = AchievementMaps{
-		NewWordCount: newWordCnt,
-		WordCount:    wordCnt,
-		TargetWord:   targetWord,
-	}
-	log.Println("Achievements loaded")
-	return nil
-}
-
-func UnlockAchievement(s *GameService, username, achievementTitle string) error {
-	newUnlock, err := s.store.UnlockAchievement(username, achievementTitle)
-	if err != nil {
-		return err
-	}
-	if newUnlock {
-		log.Printf("Achievement unlocked: %s", achievementTitle)
-		s.broker.PublishToPlayer(username, Message{Data: dto.AchievementEvent{AchievementTitle: achievementTitle}})
-	}
-	log.Printf("Achievement already unlocked: %s", achievementTitle)
-	return nil
-}
-
-func CheckAchievements(s *GameService, username string, updatedWordCnt, updatedNewWordCnt int, currentWord string) error {
-	a := s.achievements
-	if title, ok := a.NewWordCount[updatedNewWordCnt]; ok {
-		err := UnlockAchievement(s, username, title)
-		if err != nil {
-			return err
-		}
-	}
-	if title, ok := a.WordCount[updatedWordCnt]; ok {
-		err := UnlockAchievement(s, username, title)
-		if err != nil {
-			return err
-		}
-	}
-	if title, ok := a.TargetWord[strings.ToLower(currentWord)]; ok {
-		err := UnlockAchievement(s, username, title)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func GetAchievementsForUser(s *GameService, username string) ([]*dto.AchievementDTO, error) {
-	achievementTitles, err := s.store.GetAchievementsForUser(username)
-	if err != nil {
-		return nil, err
-	}
-	unlockedAchievements := map[string]bool{}
-	for _, title := range achievementTitles {
-		unlockedAchievements[title] = true
-	}
-	allAchievements, err := s.store.GetAchievements()
-	if err != nil {
-		return nil, err
-	}
-	achievements := []*dto.AchievementDTO{}
-	for _, entry := range allAchievements {
-		image, err := s.store.GetAchievementImage(entry.ImageName)
-		if err != nil {
-			return nil, err
-		}
-		unlocked := unlockedAchievements[entry.Title]
-		achievements = append(achievements, &dto.AchievementDTO{Title: entry.Title, Description: entry.Description, Image: image, Unlocked: unlocked})
-	}
-	return achievements, nil
-}
-
-// HandleAchievements godoc
-// @Summary Get all achievements for a user
-// @Description Get all achievements for a user
-// @Tags account
-// @Accept json
-// @Produce json
-// @Security BearerAuth
-// @Param username path string true "Username"
-// @Success 200 {array} dto.AchievementDTO
-func (s *GameService) HandleAchievements(w http.ResponseWriter, r *http.Request) error {
-	if r.Method != http.MethodGet {
-		err := u.WriteJSON(w, http.StatusMethodNotAllowed, dto.APIError{Error: "Method not allowed"})
-		return err
-	}
-	username, err := u.GetUsername(r)
-	if err != nil {
-		return err
-	}
-	achievements, err := GetAchievementsForUser(s, username)
-	if err != nil {
-		return err
-	}
-	return u.WriteJSON(w, http.StatusOK, achievements)
-}
+package game
+
+import (
+	"context"
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	dto "github.com/na50r/wombo-combo-go-be/dto"
+	u "github.com/na50r/wombo-combo-go-be/utility"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type AchievementMaps struct {
+	NewWordCount map[int]string // int milestone → achievement title
+	WordCount    map[int]string
+	TargetWord   map[string]string // word string → achievement title
+}
+
+func (server *GameService) SetupAchievements(ctx context.Context) error {
+	s := server.store
+	newWordCnt := map[int]string{}
+	wordCnt := map[int]string{}
+	targetWord := map[string]string{}
+	achievementEntries, err := s.GetAchievements(ctx)
+	if err != nil {
+		return err
+	}
+	for _, entry := range achievementEntries {
+		switch entry.Type {
+		case c.NewWordCount:
+			val, err := strconv.Atoi(entry.Value)
+			if err != nil {
+				return err
+			}
+			newWordCnt[val] = entry.Title
+		case c.WordCount:
+			val, err := strconv.Atoi(entry.Value)
+			if err != nil {
+				return err
+			}
+			wordCnt[val] = entry.Title
+		case c.TargetWord:
+			targetWord[entry.Value] = entry.Title
+		}
+	}
+	server.achievements = AchievementMaps{
+		NewWordCount: newWordCnt,
+		WordCount:    wordCnt,
+		TargetWord:   targetWord,
+	}
+	log.Println("Achievements loaded")
+	return nil
+}
+
+func UnlockAchievement(ctx context.Context, s *GameService, username, achievementTitle string) error {
+	newUnlock, err := s.store.UnlockAchievement(ctx, username, achievementTitle)
+	if err != nil {
+		return err
+	}
+	if newUnlock {
+		log.Printf("Achievement unlocked: %s", achievementTitle)
+		s.broker.PublishToPlayer(username, Message{Data: dto.AchievementEvent{AchievementTitle: achievementTitle}})
+	}
+	log.Printf("Achievement already unlocked: %s", achievementTitle)
+	return nil
+}
+
+func CheckAchievements(ctx context.Context, s *GameService, username string, updatedWordCnt, updatedNewWordCnt int, currentWord string) error {
+	a := s.achievements
+	if title, ok := a.NewWordCount[updatedNewWordCnt]; ok {
+		err := UnlockAchievement(ctx, s, username, title)
+		if err != nil {
+			return err
+		}
+	}
+	if title, ok := a.WordCount[updatedWordCnt]; ok {
+		err := UnlockAchievement(ctx, s, username, title)
+		if err != nil {
+			return err
+		}
+	}
+	if title, ok := a.TargetWord[strings.ToLower(currentWord)]; ok {
+		err := UnlockAchievement(ctx, s, username, title)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GetAchievementsForUser(ctx context.Context, s *GameService, username string) ([]*dto.AchievementDTO, error) {
+	achievementTitles, err := s.store.GetAchievementsForUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	unlockedAchievements := map[string]bool{}
+	for _, title := range achievementTitles {
+		unlockedAchievements[title] = true
+	}
+	allAchievements, err := s.store.GetAchievements(ctx)
+	if err != nil {
+		return nil, err
+	}
+	achievements := []*dto.AchievementDTO{}
+	for _, entry := range allAchievements {
+		image, err := s.store.GetAchievementImage(ctx, entry.ImageName)
+		if err != nil {
+			return nil, err
+		}
+		unlocked := unlockedAchievements[entry.Title]
+		achievements = append(achievements, &dto.AchievementDTO{Title: entry.Title, Description: entry.Description, Image: image, Unlocked: unlocked})
+	}
+	return achievements, nil
+}
+
+// HandleAchievements godoc
+// @Summary Get all achievements for a user
+// @Description Get all achievements for a user
+// @Tags account
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param username path string true "Username"
+// @Success 200 {array} dto.AchievementDTO
+func (s *GameService) HandleAchievements(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	if r.Method != http.MethodGet {
+		err := u.WriteJSON(w, http.StatusMethodNotAllowed, dto.APIError{Error: "Method not allowed", Code: c.METHOD_NOT_ALLOWED})
+		return err
+	}
+	username, err := u.GetUsername(r)
+	if err != nil {
+		return err
+	}
+	achievements, err := GetAchievementsForUser(ctx, s, username)
+	if err != nil {
+		return err
+	}
+	return u.WriteJSON(w, http.StatusOK, achievements)
+}
@@ -0,0 +1,143 @@
+package game
+
+// Backplanes fan out GameBroker events to every instance of the server.
+// Only events are shared: running games live in the memory of the instance that started them,
+// so requests of a lobby have to be routed to that instance (sticky sessions).
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+type Target string
+
+const (
+	TargetAll    Target = "ALL"
+	TargetLobby  Target = "LOBBY"
+	TargetPlayer Target = "PLAYER"
+	// Subscription changes, so that every instance stops sending lobby events to the same clients
+	TargetRemovePlayer Target = "REMOVE_PLAYER"
+	TargetRemoveLobby  Target = "REMOVE_LOBBY"
+)
+
+// Envelope is an encoded event with its recipients, as exchanged between instances
+type Envelope struct {
+	Target Target          `json:"target"`
+	Key    string          `json:"key,omitempty"`    // Lobby code or player name
+	Player string          `json:"player,omitempty"` // Player that is removed from the lobby
+	ID     uint64          `json:"id"`               // Assigned by the backplane
+	Event  string          `json:"event"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Event IDs come from the backplane, not from the instance that publishes, so that replays after a reconnect
+// can rely on them: every instance receives the envelopes in the order of their IDs.
+type Backplane interface {
+	// Publish assigns the next ID to the envelope and sends it to the subscribers of every instance, including this one
+	Publish(env Envelope) error
+	Subscribe(handler func(env Envelope))
+	Close() error
+}
+
+// LocalBackplane delivers envelopes within the process, for running a single instance
+type LocalBackplane struct {
+	mu      sync.Mutex // held while an envelope is numbered and delivered, so that IDs are delivered in order
+	lastID  uint64
+	handler func(env Envelope)
+}
+
+func NewLocalBackplane() *LocalBackplane {
+	// Start from the current time, so that IDs keep increasing across restarts
+	return &LocalBackplane{lastID: uint64(time.Now().UnixMilli())}
+}
+
+func (b *LocalBackplane) Publish(env Envelope) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastID++
+	env.ID = b.lastID
+	b.deliver(env)
+	return nil
+}
+
+// deliver hands an envelope to the subscriber, callers hold mu
+func (b *LocalBackplane) deliver(env Envelope) {
+	if b.handler != nil {
+		b.handler(env)
+	}
+}
+
+func (b *LocalBackplane) Subscribe(handler func(env Envelope)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handler = handler
+}
+
+func (b *LocalBackplane) Close() error {
+	return nil
+}
+
+const notifyChannel = "game_events"
+
+// Postgres limits NOTIFY payloads to 8000 bytes
+const maxNotifyPayload = 8000
+
+// Notifier is implemented by storages that can notify other instances, e.g. PostgresStore
+type Notifier interface {
+	// NotifyInOrder sends the payload built for the next ID to every listener of the channel,
+	// listeners receive the payloads in the order of their IDs
+	NotifyInOrder(channel string, payload func(id uint64) (string, error)) error
+	Listen(channel string, handler func(payload string)) (io.Closer, error)
+}
+
+// PostgresBackplane exchanges envelopes through LISTEN/NOTIFY, so that replicas share events
+type PostgresBackplane struct {
+	LocalBackplane
+	notifier Notifier
+	listener io.Closer
+}
+
+func NewPostgresBackplane(notifier Notifier) (*PostgresBackplane, error) {
+	b := &PostgresBackplane{notifier: notifier}
+	listener, err := notifier.Listen(notifyChannel, b.receive)
+	if err != nil {
+		return nil, err
+	}
+	b.listener = listener
+	return b, nil
+}
+
+func (b *PostgresBackplane) receive(payload string) {
+	var env Envelope
+	if err := json.Unmarshal([]byte(payload), &env); err != nil {
+		log.Printf("unable to unmarshal event: %s", err.Error())
+		return
+	}
+	// Delivered locally once it comes back from Postgres, just like on the other instances
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.deliver(env)
+}
+
+// The ID is taken from a sequence of the database, so that IDs of all instances are unique and arrive in order
+func (b *PostgresBackplane) Publish(env Envelope) error {
+	return b.notifier.NotifyInOrder(notifyChannel, func(id uint64) (string, error) {
+		env.ID = id
+		payload, err := json.Marshal(env)
+		if err != nil {
+			return "", err
+		}
+		if len(payload) > maxNotifyPayload {
+			return "", fmt.Errorf("Event %s is too large for NOTIFY (%d bytes)", env.Event, len(payload))
+		}
+		return string(payload), nil
+	})
+}
+
+func (b *PostgresBackplane) Close() error {
+	return b.listener.Close()
+}
@@ -0,0 +1,312 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ae "github.com/na50r/wombo-combo-go-be/apierror"
+	cb "github.com/na50r/wombo-combo-go-be/combiner"
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	dto "github.com/na50r/wombo-combo-go-be/dto"
+	"github.com/na50r/wombo-combo-go-be/sse"
+	st "github.com/na50r/wombo-combo-go-be/storage"
+	"github.com/na50r/wombo-combo-go-be/token"
+)
+
+// newTestService runs a GameService on a MemoryStore with the base words and steam as a target
+func newTestService(tb testing.TB) *GameService {
+	tb.Helper()
+	token.Configure(token.Config{Secret: "test-secret"})
+	ctx := context.Background()
+	store := st.NewMemoryStore()
+	if err := store.AddImage(ctx, []byte("png"), "default.png"); err != nil {
+		tb.Fatal(err)
+	}
+	words := []*st.Word{
+		{Word: "fire", Depth: 0, Reachability: 1},
+		{Word: "water", Depth: 0, Reachability: 1},
+		{Word: "earth", Depth: 0, Reachability: 1},
+		{Word: "wind", Depth: 0, Reachability: 1},
+		{Word: "steam", Depth: 3, Reachability: 0.125},
+	}
+	for _, word := range words {
+		if err := store.AddWord(ctx, word); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	config := sse.DefaultConfig()
+	config.BufferSize = 4
+	s := NewGameService(store, cb.NewOfflineCombiner(), time.Second, config, HintConfig{Cost: 1}, "*", NewLocalBackplane())
+	tb.Cleanup(func() { s.Shutdown(context.Background()) })
+	return s
+}
+
+// join adds a guest through the handler and returns its player token
+func join(s *GameService, lobbyCode, playerName string) (string, error) {
+	body := fmt.Sprintf(`{"playerName": %q, "lobbyCode": %q}`, playerName, lobbyCode)
+	w := httptest.NewRecorder()
+	if err := s.handleJoinLobby(w, httptest.NewRequest(http.MethodPut, "/lobbies", strings.NewReader(body))); err != nil {
+		return "", err
+	}
+	resp := new(dto.JoinLobbyRespone)
+	if err := json.NewDecoder(w.Body).Decode(resp); err != nil {
+		return "", err
+	}
+	return resp.Token, nil
+}
+
+// subscribe connects a player to the events and drains them until the broker shuts down
+func subscribe(s *GameService, playerToken string, lastEventID uint64) *sse.Client {
+	r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/events?token=%s&lastEventId=%d", playerToken, lastEventID), nil)
+	client := s.broker.Broker.Subscribe(r)
+	go func() {
+		for {
+			select {
+			case <-client.Frames:
+			case <-client.Closing:
+				return
+			}
+		}
+	}()
+	return client
+}
+
+// Run with -race, players of many lobbies join, play, ask for hints and leave at the same time,
+// while timers run out and events are published to every lobby
+func TestConcurrentPlayers(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	s := newTestService(t)
+	ctx := context.Background()
+
+	const lobbies, guests, moves = 6, 5, 20
+	modes := []c.GameMode{c.VANILLA, c.FINITE_FUSION, c.FUSION_FRENZY}
+	codes := make([]string, lobbies)
+	for l := range codes {
+		codes[l] = fmt.Sprintf("lobby%d", l)
+		owner := fmt.Sprintf("owner%d", l)
+		if err := s.store.CreateLobby(ctx, st.NewLobby(owner+"'s lobby", codes[l], "default.png")); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.store.CreatePlayer(ctx, st.NewPlayer(owner, codes[l], "default.png", true, false, 0, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Guests join and subscribe concurrently
+	tokens := make([][]string, lobbies)
+	clients := make([][]*sse.Client, lobbies)
+	var wg sync.WaitGroup
+	for l := range codes {
+		tokens[l] = make([]string, guests)
+		clients[l] = make([]*sse.Client, guests)
+		for p := 0; p < guests; p++ {
+			wg.Add(1)
+			go func(l, p int) {
+				defer wg.Done()
+				playerToken, err := join(s, codes[l], guestName(l, p))
+				if err != nil {
+					t.Errorf("join %s: %v", codes[l], err)
+					return
+				}
+				client := subscribe(s, playerToken, 0)
+				tokens[l][p], clients[l][p] = playerToken, client
+			}(l, p)
+		}
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	// Every second lobby plays with a timer that runs out during the test
+	games := make([]*Game, lobbies)
+	for l, code := range codes {
+		game, err := NewGame(ctx, s.store, code, modes[l%len(modes)], false, 0, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l%2 == 0 {
+			game.WithTimer = true
+			game.Timer = ResumeTimer(1, time.Now().Add(2*time.Second))
+		}
+		if err := SeedPlayerWords(ctx, s.store, code, game); err != nil {
+			t.Fatal(err)
+		}
+		s.setGame(game)
+		s.broker.PublishToLobby(code, Message{Data: c.GAME_STARTED})
+		if err := game.StartTimer(s); err != nil {
+			t.Fatal(err)
+		}
+		games[l] = game
+	}
+
+	// Unexpected errors are Internal, the others are expected when a player left or a game ended
+	check := func(what string, err error) {
+		if err != nil && ae.Is(err, ae.Internal) {
+			t.Errorf("%s: %v", what, err)
+		}
+	}
+	var played atomic.Int64
+	for l, code := range codes {
+		for p := 0; p < guests; p++ {
+			wg.Add(1)
+			go func(l, p int) {
+				defer wg.Done()
+				name := guestName(l, p)
+				for i := 0; i < moves; i++ {
+					words, err := s.store.GetPlayerWords(ctx, name, code)
+					check("get words", err)
+					if len(words) >= 2 {
+						a, b := words[i%len(words)], words[(i*7+1)%len(words)]
+						_, err := s.Combine(ctx, code, name, &dto.WordRequest{A: a, B: b})
+						check("combine", err)
+						if err == nil {
+							played.Add(1)
+						}
+					}
+					if i%5 == 0 {
+						_, err := s.Hint(ctx, code, name)
+						check("hint", err)
+						s.broker.PublishToPlayer(name, Message{Data: c.PLAYER_JOINED})
+					}
+					// A reconnect replays the missed events of the lobby
+					if i == moves/2 {
+						clients[l][p].Close()
+						clients[l][p] = subscribe(s, tokens[l][p], 1)
+					}
+				}
+				// The last guest of every lobby leaves
+				if p == guests-1 {
+					_, err := s.LeaveLobby(ctx, code, name)
+					check("leave", err)
+				}
+				clients[l][p].Close()
+			}(l, p)
+		}
+	}
+	// Owners of lobbies without a timer leave in the middle of the game, which deletes the lobby
+	for l, code := range codes {
+		if l%2 == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(50 * time.Millisecond)
+			_, err := s.LeaveLobby(ctx, code, fmt.Sprintf("owner%d", l))
+			check("owner leave", err)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			s.broker.Publish(Message{Data: c.LOBBY_CREATED})
+			s.broker.Broker.Stats()
+		}
+	}()
+	wg.Wait()
+	if played.Load() == 0 {
+		t.Fatal("no move succeeded")
+	}
+
+	// Timers end their games with a winner
+	deadline := time.Now().Add(10 * time.Second)
+	for l := 0; l < lobbies; l += 2 {
+		for {
+			if winner, _ := games[l].Result(); winner != "" {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timer of %s did not end the game", codes[l])
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	for l := 1; l < lobbies; l += 2 {
+		if s.getGame(codes[l]) != nil {
+			t.Errorf("game of deleted lobby %s is still running", codes[l])
+		}
+	}
+}
+
+func guestName(l, p int) string {
+	return fmt.Sprintf("guest%d-%d", l, p)
+}
+
+// Events published at the same time reach the lobby in the order of their IDs, so that a replay misses none of them
+func TestLobbyEventsInOrder(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	s := newTestService(t)
+	ctx := context.Background()
+	if err := s.store.CreateLobby(ctx, st.NewLobby("alice's lobby", "ordered", "default.png")); err != nil {
+		t.Fatal(err)
+	}
+	playerToken, err := join(s, "ordered", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive := func(lastEventID uint64, count int) []uint64 {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/events?token=%s&lastEventId=%d", playerToken, lastEventID), nil)
+		client := s.broker.Broker.Subscribe(r)
+		defer client.Close()
+		ids := []uint64{}
+		if lastEventID == 0 {
+			// Wait until the client is subscribed to the lobby
+			for {
+				s.broker.mu.RLock()
+				subscribed := len(s.broker.lobbyClients["ordered"]) > 0
+				s.broker.mu.RUnlock()
+				if subscribed {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			var wg sync.WaitGroup
+			for p := 0; p < 4; p++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range count / 4 {
+						s.broker.PublishToLobby("ordered", Message{Data: c.GAME_EDITED})
+					}
+				}()
+			}
+			wg.Wait()
+		}
+		timeout := time.After(5 * time.Second)
+		for len(ids) < count {
+			select {
+			case frame := <-client.Frames:
+				ids = append(ids, frame.ID)
+			case <-timeout:
+				t.Fatalf("received %d of %d events", len(ids), count)
+			}
+		}
+		return ids
+	}
+	ids := receive(0, 24)
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("event %d has ID %d after %d", i, ids[i], ids[i-1])
+		}
+	}
+	if replayed := receive(ids[9], 14); !slices.Equal(replayed, ids[10:]) {
+		t.Fatalf("replayed %v, want %v", replayed, ids[10:])
+	}
+}
@@ -0,0 +1,189 @@
+package game
+
+// Finite Fusion: every word in a player's inventory can only be used n times
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+
+	ae "github.com/na50r/wombo-combo-go-be/apierror"
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	st "github.com/na50r/wombo-combo-go-be/storage"
+)
+
+const DefaultWordUses = 3
+
+type WordUses struct {
+	mu    sync.Mutex
+	limit int
+	uses  map[string]map[string]int // player name → word → remaining uses
+}
+
+func NewWordUses(limit int) *WordUses {
+	if limit < 1 {
+		limit = DefaultWordUses
+	}
+	return &WordUses{
+		limit: limit,
+		uses:  make(map[string]map[string]int),
+	}
+}
+
+// Add gives a newly discovered word the full budget, known words keep their remaining uses
+func (wu *WordUses) Add(playerName, word string) {
+	wu.mu.Lock()
+	defer wu.mu.Unlock()
+	word = strings.ToLower(word)
+	if wu.uses[playerName] == nil {
+		wu.uses[playerName] = make(map[string]int)
+	}
+	if _, ok := wu.uses[playerName][word]; ok {
+		return
+	}
+	wu.uses[playerName][word] = wu.limit
+}
+
+// Consume uses up a and b once, combining a word with itself costs two uses
+func (wu *WordUses) Consume(playerName, a, b string) error {
+	wu.mu.Lock()
+	defer wu.mu.Unlock()
+	a = strings.ToLower(a)
+	b = strings.ToLower(b)
+	words := wu.uses[playerName]
+	required := map[string]int{a: 1}
+	required[b]++
+	for word, n := range required {
+		left, ok := words[word]
+		if !ok {
+			return ae.Forbiddenf("Word %s is not in your inventory", word).WithCode(c.WORD_NOT_OWNED)
+		}
+		if left < n {
+			return ae.Conflictf("Word %s has no uses left", word).WithCode(c.WORD_USED_UP)
+		}
+	}
+	for word, n := range required {
+		words[word] -= n
+	}
+	return nil
+}
+
+// Refund gives back the uses of a move that failed after Consume
+func (wu *WordUses) Refund(playerName, a, b string) {
+	wu.mu.Lock()
+	defer wu.mu.Unlock()
+	words := wu.uses[playerName]
+	if words == nil {
+		return
+	}
+	for _, word := range []string{strings.ToLower(a), strings.ToLower(b)} {
+		if _, ok := words[word]; ok {
+			words[word]++
+		}
+	}
+}
+
+func (wu *WordUses) Remove(playerName string) {
+	wu.mu.Lock()
+	defer wu.mu.Unlock()
+	delete(wu.uses, playerName)
+}
+
+func (wu *WordUses) Remaining(playerName string) map[string]int {
+	wu.mu.Lock()
+	defer wu.mu.Unlock()
+	remaining := make(map[string]int, len(wu.uses[playerName]))
+	for word, left := range wu.uses[playerName] {
+		remaining[word] = left
+	}
+	return remaining
+}
+
+// set restores the remaining uses of a word
+func (wu *WordUses) set(playerName, word string, left int) {
+	wu.mu.Lock()
+	defer wu.mu.Unlock()
+	if wu.uses[playerName] == nil {
+		wu.uses[playerName] = make(map[string]int)
+	}
+	wu.uses[playerName][strings.ToLower(word)] = left
+}
+
+// rows returns the remaining uses of the given words of a player, as persisted
+func (wu *WordUses) rows(lobbyCode, playerName string, words ...string) []*st.WordUse {
+	wu.mu.Lock()
+	defer wu.mu.Unlock()
+	rows := []*st.WordUse{}
+	for _, word := range words {
+		word = strings.ToLower(word)
+		if left, ok := wu.uses[playerName][word]; ok {
+			rows = append(rows, &st.WordUse{LobbyCode: lobbyCode, PlayerName: playerName, Word: word, UsesLeft: left})
+		}
+	}
+	return rows
+}
+
+// allRows returns the remaining uses of every word of every player
+func (wu *WordUses) allRows(lobbyCode string) []*st.WordUse {
+	wu.mu.Lock()
+	defer wu.mu.Unlock()
+	rows := []*st.WordUse{}
+	for playerName, words := range wu.uses {
+		for word, left := range words {
+			rows = append(rows, &st.WordUse{LobbyCode: lobbyCode, PlayerName: playerName, Word: word, UsesLeft: left})
+		}
+	}
+	return rows
+}
+
+// A move is legal if two distinct words are left or one word can be combined with itself
+func hasMove(words map[string]int) bool {
+	usable := 0
+	for _, left := range words {
+		if left >= 2 {
+			return true
+		}
+		if left == 1 {
+			usable++
+		}
+	}
+	return usable >= 2
+}
+
+func (wu *WordUses) HasMove(playerName string) bool {
+	wu.mu.Lock()
+	defer wu.mu.Unlock()
+	return hasMove(wu.uses[playerName])
+}
+
+func (wu *WordUses) AnyMoveLeft() bool {
+	wu.mu.Lock()
+	defer wu.mu.Unlock()
+	for _, words := range wu.uses {
+		if hasMove(words) {
+			return true
+		}
+	}
+	return false
+}
+
+// EndFiniteFusion ends the game once no player has a legal move left, a game that is over already is left as it is
+func (s *GameService) EndFiniteFusion(ctx context.Context, game *Game) error {
+	ended, err := s.endGame(ctx, game, "", false)
+	if ended {
+		winner, _ := game.Result()
+		log.Printf("No moves left in lobby %s, winner is %s", game.LobbyCode, winner)
+	}
+	return err
+}
+
+// endIfNoMoveLeft ends a running game once a player left and nobody else can move
+func (s *GameService) endIfNoMoveLeft(ctx context.Context, game *Game) {
+	if game.WordUses.AnyMoveLeft() {
+		return
+	}
+	if err := s.EndFiniteFusion(ctx, game); err != nil {
+		log.Printf("Error ending game %s: %v", game.LobbyCode, err)
+	}
+}
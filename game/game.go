@@ -1,561 +1,802 @@
-package game
-
-import (
-	"encoding/json"
-	"fmt"
-	"log"
-	"math/rand"
-	"net/http"
-	"sort"
-	c "github.com/na50r/wombo-combo-go-be/constants"
-	dto "github.com/na50r/wombo-combo-go-be/dto"
-	u "github.com/na50r/wombo-combo-go-be/utility"
-	st "github.com/na50r/wombo-combo-go-be/storage"
-	t "github.com/na50r/wombo-combo-go-be/token"
-)
-
-type Game struct {
-	GameMode    c.GameMode `json:"gameMode"`
-	LobbyCode   string   `json:"lobbyCode"`
-	TargetWord  string   `json:"targetWord"`
-	TargetWords []string `json:"targetWords"`
-	Winner      string   `json:"winner"`
-	WithTimer   bool     `json:"withTimer"`
-	Timer       *Timer   `json:"timer"`
-	ManualEnd   bool     `json:"manualEnd"`
-}
-
-type GameService struct {
-	store st.Storage
-	broker *GameBroker
-	games map[string]*Game
-	apiKey string
-	achievements AchievementMaps
-}
-
-func NewGameService(store st.Storage, apiKey string) *GameService {
-	return &GameService{
-		store: store,
-		broker: NewGameBroker(),
-		games: make(map[string]*Game),
-		apiKey: apiKey,
-	}
-}
-
-func (s *GameService) HandleGame(w http.ResponseWriter, r *http.Request) error {
-	switch r.Method {
-	case http.MethodGet:
-		return s.handleGetGameStats(w, r)
-	case http.MethodPost:
-		return s.handleCreateGame(w, r)
-	case http.MethodDelete:
-		return s.handleDeleteGame(w, r)
-	default:
-		err := u.WriteJSON(w, http.StatusMethodNotAllowed, dto.APIError{Error: "Method not allowed"})
-		return err
-	}
-}
-
-// handleDeleteGame godoc
-// @Summary Delete a game (owner)
-// @Description Delete a game
-// @Tags game
-// @Accept json
-// @Produce json
-// @Security BearerAuth
-// @Param lobbyCode path string true "Lobby code"
-// @Param playerName path string true "Player name"
-// @Success 200 {object} dto.GenericResponse
-// @Failure 400 {object} dto.APIError
-// @Failure 405 {object} dto.APIError
-// @Router /games/{lobbyCode}/{playerName}/game [delete]
-func (s *GameService) handleDeleteGame(w http.ResponseWriter, r *http.Request) error {
-	playerClaims := r.Context().Value(t.AuthKey{}).(*t.PlayerClaims)
-	if !playerClaims.IsOwner {
-		return fmt.Errorf("unauthorized")
-	}
-
-	lobbyCode, err := u.GetLobbyCode(r)
-	if err != nil {
-		return err
-	}
-	if game, ok := s.games[lobbyCode]; ok {
-		game.StopTimer()
-		delete(s.games, lobbyCode)
-	}
-	if err := s.store.DeletePlayerWordsByLobbyCode(lobbyCode); err != nil {
-		log.Printf("Error deleting player words before returning to lobby: %v", err)
-		return err
-	}
-	s.broker.PublishToLobby(lobbyCode, Message{Data: c.GAME_DELETED})
-	return u.WriteJSON(w, http.StatusOK, dto.GenericResponse{Message: "Game deleted"})
-}
-
-// HandleGetWords godoc
-// @Summary Get a player's words
-// @Description Get a player's words
-// @Tags game
-// @Accept json
-// @Produce json
-// @Security BearerAuth
-// @Param lobbyCode path string true "Lobby code"
-// @Success 200 {object} dto.Words
-// @Failure 400 {object} dto.APIError
-// @Failure 405 {object} dto.APIError
-// @Router /games/{lobbyCode}/{playerName}/words [get]
-func (s *GameService) HandleGetWords(w http.ResponseWriter, r *http.Request) error {
-	lobbyCode, err := u.GetLobbyCode(r)
-	if err != nil {
-		return err
-	}
-	playerName, err := u.GetPlayername(r)
-	if err != nil {
-		return err
-	}
-	words, err := s.store.GetPlayerWords(playerName, lobbyCode)
-	if err != nil {
-		return err
-	}
-	targetWord, err := s.store.GetPlayerTargetWord(playerName, lobbyCode)
-	if err != nil {
-		return err
-	}
-	wordsDTO := dto.Words{Words: words, TargetWord: targetWord}
-	return u.WriteJSON(w, http.StatusOK, wordsDTO)
-}
-
-// handleCreateGame godoc
-// @Summary Start a game (owner)
-// @Description Start a game
-// @Tags game
-// @Accept json
-// @Produce json
-// @Param game body dto.StartGameRequest true "Game to start"
-// @Security BearerAuth
-// @Param lobbyCode path string true "Lobby code"
-// @Param playerName path string true "Player name"
-// @Success 200 {object} dto.GenericResponse
-// @Failure 400 {object} dto.APIError
-// @Failure 405 {object} dto.APIError
-// @Router /games/{lobbyCode}/{playerName}/game [post]
-func (s *GameService) handleCreateGame(w http.ResponseWriter, r *http.Request) error {
-	playerClaims := r.Context().Value(t.AuthKey{}).(*t.PlayerClaims)
-	if !playerClaims.IsOwner {
-		return fmt.Errorf(c.Unauthorized)
-	}
-
-	lobbyCode, err := u.GetLobbyCode(r)
-	if err != nil {
-		return err
-	}
-	req := new(dto.StartGameRequest)
-	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-		return err
-	}
-	if err := s.store.EditGameMode(lobbyCode, req.GameMode); err != nil {
-		return err
-	}
-	lobby, err := s.store.GetLobbyByCode(lobbyCode)
-	if err != nil {
-		return err
-	}
-	if req.GameMode == c.DAILY_CHALLENGE {
-		if lobby.PlayerCount > 1 || req.WithTimer {
-			return fmt.Errorf("Daily challenge must be played solo and without a timer")
-		}
-	}
-	game, err := NewGame(s.store, lobbyCode, req.GameMode, req.WithTimer, req.Duration)
-	if err != nil {
-		return err
-	}
-	s.games[lobbyCode] = game
-	if err := s.store.DeletePlayerWordsByLobbyCode(lobbyCode); err != nil {
-		log.Printf("Error deleting player words before game start: %v", err)
-		return err
-	}
-	if err := s.store.ResetPlayerPoints(lobbyCode); err != nil {
-		return err
-	}
-	if err := SeedPlayerWords(s.store, lobbyCode, game); err != nil {
-		return err
-	}
-	log.Printf("Game created\nLobby code: %s", lobbyCode)
-	log.Printf("Game mode: %s", game.GameMode)
-	log.Printf("Timer: %v", game.WithTimer)
-	log.Println("Target words: ", game.TargetWords)
-	s.broker.PublishToLobby(lobbyCode, Message{Data: c.GAME_STARTED})
-	game.StartTimer(s)
-	return u.WriteJSON(w, http.StatusOK, dto.GenericResponse{Message: "Game started"})
-}
-
-// HandleCombination godoc
-// @Summary Make a move
-// @Description Make a move
-// @Tags game
-// @Accept json
-// @Produce json
-// @Param move body dto.WordRequest true "Move to make"
-// @Security BearerAuth
-// @Param lobbyCode path string true "Lobby code"
-// @Param playerName path string true "Player name"
-// @Success 200 {object} dto.WordResponse
-// @Failure 400 {object} dto.APIError
-// @Failure 405 {object} dto.APIError
-// @Router /games/{lobbyCode}/{playerName}/combinations [post]
-func (s *GameService) HandleCombination(w http.ResponseWriter, r *http.Request) error {
-	if r.Method != http.MethodPost {
-		err := u.WriteJSON(w, http.StatusMethodNotAllowed, dto.APIError{Error: "Method not allowed"})
-		return err
-	}
-	lobbyCode, err := u.GetLobbyCode(r)
-	if err != nil {
-		return err
-	}
-	game := s.games[lobbyCode]
-	if game == nil {
-		return fmt.Errorf("Game not found")
-	}
-	req := new(dto.WordRequest)
-	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-		return err
-	}
-	result, isNew, err := st.GetCombination(s.store, req.A, req.B, s.apiKey)
-	if err != nil {
-		return err
-	}
-	playerName, err := u.GetPlayername(r)
-	if err != nil {
-		return err
-	}
-	player, err := s.store.GetPlayerByLobbyCodeAndName(playerName, lobbyCode)
-	if err != nil {
-		return err
-	}
-	log.Printf("Player %s played %s + %s = %s", playerName, req.A, req.B, result)
-	err = ProcessMove(s, game, player, result, isNew)
-	if err != nil {
-		return err
-	}
-	return u.WriteJSON(w, http.StatusOK, dto.WordResponse{Result: result, IsNew: isNew})
-}
-
-// handleGetGameStats godoc
-// @Summary Get game stats
-// @Description Get game stats
-// @Tags game
-// @Accept json
-// @Produce json
-// @Security BearerAuth
-// @Param lobbyCode path string true "Lobby code"
-// @Param playerName path string true "Player name"
-// @Success 200 {object} dto.GameEndResponse
-// @Failure 400 {object} dto.APIError
-// @Failure 405 {object} dto.APIError
-// @Router /games/{lobbyCode}/{playerName}/game [get]
-func (s *GameService) handleGetGameStats(w http.ResponseWriter, r *http.Request) error {
-	lobbyCode, err := u.GetLobbyCode(r)
-	if err != nil {
-		return err
-	}
-	winner := s.games[lobbyCode].Winner
-	playerWordCounts, err := s.store.GetWordCountByLobbyCode(lobbyCode)
-	if err != nil {
-		return err
-	}
-	playerWordsDTO := []*dto.PlayerResultDTO{}
-	for _, playerWordCount := range playerWordCounts {
-		player, err := s.store.GetPlayerByLobbyCodeAndName(playerWordCount.PlayerName, lobbyCode)
-		if err != nil {
-			return err
-		}
-		img, err := s.store.GetImage(player.ImageName)
-		if err != nil {
-			return err
-		}
-		playerWordsDTO = append(playerWordsDTO, &dto.PlayerResultDTO{PlayerName: player.Name, Image: img, WordCount: playerWordCount.WordCount, Points: player.Points + playerWordCount.WordCount})
-	}
-	sort.Slice(playerWordsDTO, func(i, j int) bool {
-		if playerWordsDTO[i].PlayerName == winner {
-			return true
-		}
-		if playerWordsDTO[j].PlayerName == winner {
-			return false
-		}
-		return playerWordsDTO[i].Points > playerWordsDTO[j].Points
-	})
-	return u.WriteJSON(w, http.StatusOK, dto.GameEndResponse{Winner: winner, PlayerWords: playerWordsDTO, GameMode: s.games[lobbyCode].GameMode, ManualEnd: s.games[lobbyCode].ManualEnd})
-}
-
-// HandleManualGameEnd godoc
-// @Summary End a game (owner)
-// @Description End a game
-// @Tags game
-// @Accept json
-// @Produce json
-// @Security BearerAuth
-// @Param lobbyCode path string true "Lobby code"
-// @Param playerName path string true "Player name"
-// @Success 200 {object} dto.GenericResponse
-// @Failure 400 {object} dto.APIError
-// @Failure 405 {object} dto.APIError
-// @Router /games/{lobbyCode}/{playerName}/end [post]
-func (s *GameService) HandleManualGameEnd(w http.ResponseWriter, r *http.Request) error {
-	if r.Method != http.MethodPost {
-		err := u.WriteJSON(w, http.StatusMethodNotAllowed, dto.APIError{Error: "Method not allowed"})
-		return err
-	}
-	playerClaims := r.Context().Value(t.AuthKey{}).(*t.PlayerClaims)
-	if !playerClaims.IsOwner {
-		return fmt.Errorf(c.Unauthorized)
-	}
-	lobbyCode, err := u.GetLobbyCode(r)
-	if err != nil {
-		return err
-	}
-	game := s.games[lobbyCode]
-	if game == nil {
-		return fmt.Errorf("Game not found")
-	}
-	game.StopTimer()
-	winner, err := s.store.SelectWinnerByPoints(lobbyCode)
-	if err != nil {
-		return err
-	}
-	if err := s.store.UpdateAccountWinsAndLosses(lobbyCode, winner); err != nil {
-		return err
-	}
-	s.broker.PublishToLobby(lobbyCode, Message{Data: c.ACCOUNT_UPDATE})
-	game.Winner = winner
-	game.ManualEnd = true
-	s.broker.PublishToLobby(lobbyCode, Message{Data: c.GAME_OVER})
-	return u.WriteJSON(w, http.StatusOK, dto.GenericResponse{Message: "Game ended"})
-}
-
-// Game Logic
-func (g *Game) SetTarget() (string, error) {
-	if g.GameMode == c.VANILLA {
-		return "", nil
-	}
-	if g.GameMode == c.WOMBO_COMBO {
-		log.Println("Number of target words ", len(g.TargetWords))
-		targetWord := g.TargetWords[rand.Intn(len(g.TargetWords))]
-		return targetWord, nil
-	}
-	if g.GameMode == c.FUSION_FRENZY {
-		return g.TargetWord, nil
-	}
-	if g.GameMode == c.DAILY_CHALLENGE {
-		return g.TargetWord, nil
-	}
-	return "", fmt.Errorf("Game mode %s not found", g.GameMode)
-}
-
-func ProcessMove(server *GameService, game *Game, player *st.Player, result string, isNew bool) error {
-	if game.GameMode == c.FUSION_FRENZY && player.TargetWord == result {
-		game.StopTimer()
-		game.Winner = player.Name
-		if err := server.store.UpdateAccountWinsAndLosses(game.LobbyCode, player.Name); err != nil {
-			return err
-		}
-		if err := server.store.UpdateAccountWordCount(player.Name, player.NewWordCount, player.WordCount); err != nil {
-			return err
-		}
-		server.broker.PublishToLobby(game.LobbyCode, Message{Data: c.GAME_OVER})
-		server.broker.PublishToLobby(game.LobbyCode, Message{Data: c.ACCOUNT_UPDATE})
-		return nil
-	}
-	if game.GameMode == c.WOMBO_COMBO && player.TargetWord == result {
-		var newTargetWord string
-		var err error
-		for {
-			newTargetWord, err = game.SetTarget()
-			if err != nil {
-				return err
-			}
-			if newTargetWord != player.TargetWord {
-				break
-			}
-		}
-		log.Printf("Player %s reached target word %s, new target word is %s", player.Name, player.TargetWord, newTargetWord)
-		if err := server.store.SetPlayerTargetWord(player.Name, newTargetWord, game.LobbyCode); err != nil {
-			return err
-		}
-		if err := server.store.IncrementPlayerPoints(player.Name, game.LobbyCode, 10); err != nil {
-			return err
-		}
-			server.broker.PublishToLobby(game.LobbyCode, Message{Data: c.WOMBO_COMBO_EVENT})
-	}
-	if game.GameMode == c.DAILY_CHALLENGE && player.TargetWord == result {
-		wordCounts, err := server.store.GetWordCountByLobbyCode(game.LobbyCode)
-		if err != nil {
-			return err
-		}
-		wordCount := wordCounts[0].WordCount
-		log.Printf("Player %s completed daily challenge with word count %d", player.Name, wordCount)
-		if err := server.store.AddDailyChallengeEntry(wordCount+1, player.Name); err != nil {
-			return err
-		}
-		server.broker.PublishToLobby(game.LobbyCode, Message{Data: c.GAME_OVER})
-		return nil
-	}
-	if err := server.store.AddPlayerWord(player.Name, result, game.LobbyCode); err != nil {
-		return err
-	}
-	updatedWordCnt := player.WordCount + 1
-	updatedNewWordCnt := player.NewWordCount
-	if isNew {
-		updatedNewWordCnt = player.NewWordCount + 1
-	}
-	if err := server.store.UpdatePlayerWordCount(player.Name, game.LobbyCode, updatedNewWordCnt, updatedWordCnt); err != nil {
-		return err
-	}
-	if err := CheckAchievements(server, player.Name, updatedWordCnt, updatedNewWordCnt, result); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (g *Game) StartTimer(s *GameService) {
-	if g.WithTimer {
-		g.Timer.Start(s, g.LobbyCode, g)
-	}
-}
-
-func (g *Game) StopTimer() {
-	if g.WithTimer {
-		g.Timer.Stop()
-	}
-}
-
-func SeedPlayerWords(s st.Storage, lobbyCode string, game *Game) error {
-	players, err := s.GetPlayersByLobbyCode(lobbyCode)
-	if err != nil {
-		return err
-	}
-	for _, player := range players {
-		target, err := game.SetTarget()
-		if err != nil {
-			return err
-		}
-		if err := s.SetPlayerTargetWord(player.Name, target, lobbyCode); err != nil {
-			return err
-		}
-		s.AddPlayerWord(player.Name, "fire", lobbyCode)
-		s.AddPlayerWord(player.Name, "water", lobbyCode)
-		s.AddPlayerWord(player.Name, "earth", lobbyCode)
-		s.AddPlayerWord(player.Name, "wind", lobbyCode)
-	}
-	return nil
-}
-
-func NewGame(s st.Storage, lobbyCode string, gameMode c.GameMode, withTimer bool, duration int) (*Game, error) {
-	game := new(Game)
-	game.LobbyCode = lobbyCode
-	game.GameMode = gameMode
-	game.WithTimer = withTimer
-	game.ManualEnd = false
-
-	if withTimer {
-		game.Timer = NewTimer(duration)
-	}
-
-	err := fmt.Errorf("Game mode %s not found", gameMode)
-	if gameMode == c.VANILLA {
-		return game, nil
-	}
-	// Reachability is between 0 and 1
-	// Reachability is computed with: 1 / (2 ^ depth)
-	// Reachability is updated with:
-	// 0.75 * newReachability + 0.25 * oldReachability if newDepth < oldDepth
-	// 0.25 * newReachability + 0.75 * oldReachability if newDepth >= oldDepth
-	// The less deep and the more paths are available, the more reachable a word is
-	if gameMode == c.FUSION_FRENZY {
-		game.TargetWord, err = s.GetTargetWord(0.0375, 0.2, 10)
-		if err != nil {
-			return nil, err
-		}
-		return game, nil
-	}
-	if gameMode == c.WOMBO_COMBO {
-		game.TargetWords, err = s.GetTargetWords(0.0375, 0.2, 10)
-		if err != nil {
-			return nil, err
-		}
-		return game, nil
-	}
-	if gameMode == c.DAILY_CHALLENGE {
-		game.TargetWord, err = s.CreateOrGetDailyWord(0.0375, 0.2, 8)
-		if err != nil {
-			log.Printf("Error creating or getting daily word: %v", err)
-			return nil, err
-		}
-		return game, nil
-	}
-	return nil, err
-}
-
-
-func (s *GameService) Logout(lobbyCode, username string) error {
-	var err error
-		if game, ok := s.games[lobbyCode]; ok {
-			game.StopTimer()
-			delete(s.games, lobbyCode)
-		}
-		if err := s.store.DeleteLobby(lobbyCode); err != nil {
-			return err
-		}
-		if err := s.store.DeletePlayersForLobby(lobbyCode); err != nil {
-			return err
-		}
-		if err := s.store.DeletePlayerWordsByLobbyCode(lobbyCode); err != nil {
-			return err
-		}
-		err = s.store.SetIsOwner(username, false)
-		if err != nil {
-			return err
-		}
-		delete(s.broker.lobbyClients, lobbyCode)
-		delete(s.games, lobbyCode)
-		s.broker.PublishToLobby(lobbyCode,Message{Data: c.GAME_DELETED})
-		s.broker.Publish(Message{Data: c.LOBBY_DELETED})
-		return nil
-}
-
-
-// handleLeaderboard godoc
-// @Summary Get the leaderboard
-// @Description Get the leaderboard
-// @Tags
-// @Accept json
-// @Produce json
-// @Security BearerAuth
-// @Param username path string true "Username"
-// @Success 200 {array} dto.ChallengeEntryDTO
-// @Failure 400 {object} dto.APIError
-// @Failure 405 {object} dto.APIError
-// @Router /account/{username}/leaderboard [get]
-func (s *GameService) HandleLeaderboard(w http.ResponseWriter, r *http.Request) error {
-	if r.Method != http.MethodGet {
-		err := u.WriteJSON(w, http.StatusMethodNotAllowed, dto.APIError{Error: "Method not allowed"})
-		return err
-	}
-	entries, err := s.store.GetChallengeEntries()
-	if err != nil {
-		return err
-	}
-	entriesDTO := []*dto.ChallengeEntryDTO{}
-	for _, entry := range entries {
-		image, err := s.store.GetImageByUsername(entry.Username)
-		if err != nil {
-			return err
-		}
-		entriesDTO = append(entriesDTO, &dto.ChallengeEntryDTO{WordCount: entry.WordCount, Username: entry.Username, Image: image})
-	}
-	sort.Slice(entriesDTO, func(i, j int) bool {
-		return entriesDTO[i].WordCount < entriesDTO[j].WordCount
-	})
-	return u.WriteJSON(w, http.StatusOK, entriesDTO)
-}
-
-// TODO: Add Finite-Fusion game mode
-// Only n attempts of using word a/b for combination
\ No newline at end of file
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	ae "github.com/na50r/wombo-combo-go-be/apierror"
+	cb "github.com/na50r/wombo-combo-go-be/combiner"
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	dto "github.com/na50r/wombo-combo-go-be/dto"
+	"github.com/na50r/wombo-combo-go-be/graph"
+	"github.com/na50r/wombo-combo-go-be/sse"
+	st "github.com/na50r/wombo-combo-go-be/storage"
+	t "github.com/na50r/wombo-combo-go-be/token"
+	u "github.com/na50r/wombo-combo-go-be/utility"
+	"log"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Game struct {
+	GameMode    c.GameMode `json:"gameMode"`
+	LobbyCode   string     `json:"lobbyCode"`
+	TargetWord  string     `json:"targetWord"`
+	TargetWords []string   `json:"targetWords"`
+	Winner      string     `json:"winner"`
+	WithTimer   bool       `json:"withTimer"`
+	Timer       *Timer     `json:"timer"`
+	ManualEnd   bool       `json:"manualEnd"`
+	WordUses    *WordUses  `json:"-"`
+	mu          sync.Mutex // guards Winner, ManualEnd and ending, which the timer sets concurrently
+	ending      bool       // a winner is being selected
+}
+
+type GameService struct {
+	store        st.Storage
+	broker       *GameBroker
+	gamesMu      sync.RWMutex
+	games        map[string]*Game
+	combiner     cb.Combiner
+	combinations *st.CombinationGroup
+	achievements AchievementMaps
+	hintConfig   HintConfig
+	hints        *hintLimiter
+	recipes      *recipeGraph
+	client       string         // Origin of the frontend, the only one allowed to open WebSockets
+	connections  sync.WaitGroup // open WebSockets, the HTTP server does not track hijacked connections
+}
+
+// combinerTimeout bounds a lookup that is shared by every player combining the same pair, 0 disables it.
+// client is the origin of the frontend, "*" allows any.
+func NewGameService(store st.Storage, combiner cb.Combiner, combinerTimeout time.Duration, eventConfig sse.Config, hintConfig HintConfig, client string, backplane Backplane) *GameService {
+	return &GameService{
+		store:        store,
+		broker:       NewGameBroker(eventConfig, backplane),
+		games:        make(map[string]*Game),
+		combiner:     combiner,
+		combinations: st.NewCombinationGroup(combinerTimeout),
+		hintConfig:   hintConfig,
+		hints:        newHintLimiter(hintConfig.Cooldown),
+		recipes:      &recipeGraph{},
+		client:       client,
+	}
+}
+
+func (s *GameService) getGame(lobbyCode string) *Game {
+	s.gamesMu.RLock()
+	defer s.gamesMu.RUnlock()
+	return s.games[lobbyCode]
+}
+
+func (s *GameService) setGame(game *Game) {
+	s.gamesMu.Lock()
+	defer s.gamesMu.Unlock()
+	if old, ok := s.games[game.LobbyCode]; ok && old != game {
+		old.StopTimer()
+	}
+	s.games[game.LobbyCode] = game
+}
+
+func (s *GameService) removeGame(lobbyCode string) *Game {
+	s.gamesMu.Lock()
+	defer s.gamesMu.Unlock()
+	game := s.games[lobbyCode]
+	delete(s.games, lobbyCode)
+	return game
+}
+
+func (s *GameService) allGames() []*Game {
+	s.gamesMu.RLock()
+	defer s.gamesMu.RUnlock()
+	games := make([]*Game, 0, len(s.games))
+	for _, game := range s.games {
+		games = append(games, game)
+	}
+	return games
+}
+
+func (s *GameService) gameCount() int {
+	s.gamesMu.RLock()
+	defer s.gamesMu.RUnlock()
+	return len(s.games)
+}
+
+func (g *Game) SetWinner(winner string, manualEnd bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.Winner = winner
+	g.ManualEnd = manualEnd
+}
+
+// startEnd claims ending the game, false if it is over already or another request is ending it
+func (g *Game) startEnd() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.Winner != "" || g.ending {
+		return false
+	}
+	g.ending = true
+	return true
+}
+
+func (g *Game) cancelEnd() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.ending = false
+}
+
+func (g *Game) Result() (string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.Winner, g.ManualEnd
+}
+
+func (s *GameService) HandleGame(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+		return s.handleGetGameStats(w, r)
+	case http.MethodPost:
+		return s.handleCreateGame(w, r)
+	case http.MethodDelete:
+		return s.handleDeleteGame(w, r)
+	default:
+		err := u.WriteJSON(w, http.StatusMethodNotAllowed, dto.APIError{Error: "Method not allowed", Code: c.METHOD_NOT_ALLOWED})
+		return err
+	}
+}
+
+// handleDeleteGame godoc
+// @Summary Delete a game (owner)
+// @Description Delete a game
+// @Tags game
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param lobbyCode path string true "Lobby code"
+// @Param playerName path string true "Player name"
+// @Success 200 {object} dto.GenericResponse
+// @Failure 400 {object} dto.APIError
+// @Failure 401 {object} dto.APIError
+// @Failure 403 {object} dto.APIError
+// @Failure 404 {object} dto.APIError
+// @Failure 405 {object} dto.APIError
+// @Failure 500 {object} dto.APIError
+// @Router /games/{lobbyCode}/{playerName}/game [delete]
+func (s *GameService) handleDeleteGame(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	playerClaims := r.Context().Value(t.AuthKey{}).(*t.PlayerClaims)
+	if !playerClaims.IsOwner {
+		return ae.Forbiddenf(c.Unauthorized)
+	}
+
+	lobbyCode, err := u.GetLobbyCode(r)
+	if err != nil {
+		return err
+	}
+	s.deleteGame(ctx, lobbyCode)
+	if err := s.store.DeletePlayerWordsByLobbyCode(ctx, lobbyCode); err != nil {
+		log.Printf("Error deleting player words before returning to lobby: %v", err)
+		return err
+	}
+	s.broker.PublishToLobby(lobbyCode, Message{Data: c.GAME_DELETED})
+	return u.WriteJSON(w, http.StatusOK, dto.GenericResponse{Message: "Game deleted"})
+}
+
+// HandleGetWords godoc
+// @Summary Get a player's words
+// @Description Get a player's words
+// @Tags game
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param lobbyCode path string true "Lobby code"
+// @Success 200 {object} dto.Words
+// @Failure 400 {object} dto.APIError
+// @Failure 401 {object} dto.APIError
+// @Failure 403 {object} dto.APIError
+// @Failure 404 {object} dto.APIError
+// @Failure 405 {object} dto.APIError
+// @Failure 500 {object} dto.APIError
+// @Router /games/{lobbyCode}/{playerName}/words [get]
+func (s *GameService) HandleGetWords(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	lobbyCode, err := u.GetLobbyCode(r)
+	if err != nil {
+		return err
+	}
+	playerName, err := u.GetPlayername(r)
+	if err != nil {
+		return err
+	}
+	words, err := s.store.GetPlayerWords(ctx, playerName, lobbyCode)
+	if err != nil {
+		return err
+	}
+	targetWord, err := s.store.GetPlayerTargetWord(ctx, playerName, lobbyCode)
+	if err != nil {
+		return err
+	}
+	wordsDTO := dto.Words{Words: words, TargetWord: targetWord}
+	if game := s.getGame(lobbyCode); game != nil && game.GameMode == c.FINITE_FUSION {
+		wordsDTO.WordUses = game.WordUses.Remaining(playerName)
+	}
+	return u.WriteJSON(w, http.StatusOK, wordsDTO)
+}
+
+// handleCreateGame godoc
+// @Summary Start a game (owner)
+// @Description Start a game
+// @Tags game
+// @Accept json
+// @Produce json
+// @Param game body dto.StartGameRequest true "Game to start"
+// @Security BearerAuth
+// @Param lobbyCode path string true "Lobby code"
+// @Param playerName path string true "Player name"
+// @Success 200 {object} dto.GenericResponse
+// @Failure 400 {object} dto.APIError
+// @Failure 401 {object} dto.APIError
+// @Failure 403 {object} dto.APIError
+// @Failure 404 {object} dto.APIError
+// @Failure 405 {object} dto.APIError
+// @Failure 500 {object} dto.APIError
+// @Router /games/{lobbyCode}/{playerName}/game [post]
+func (s *GameService) handleCreateGame(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	playerClaims := r.Context().Value(t.AuthKey{}).(*t.PlayerClaims)
+	if !playerClaims.IsOwner {
+		return ae.Forbiddenf(c.Unauthorized)
+	}
+
+	lobbyCode, err := u.GetLobbyCode(r)
+	if err != nil {
+		return err
+	}
+	req := new(dto.StartGameRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return ae.Wrap(ae.Validation, err, "Invalid request body")
+	}
+	var game *Game
+	err = s.store.WithTx(ctx, func(tx st.Storage) error {
+		if err := tx.EditGameMode(ctx, lobbyCode, req.GameMode); err != nil {
+			return err
+		}
+		lobby, err := tx.GetLobbyByCode(ctx, lobbyCode)
+		if err != nil {
+			return err
+		}
+		if req.GameMode == c.DAILY_CHALLENGE {
+			if lobby.PlayerCount > 1 || req.WithTimer {
+				return ae.Validationf("Daily challenge must be played solo and without a timer")
+			}
+		}
+		game, err = NewGame(ctx, tx, lobbyCode, req.GameMode, req.WithTimer, req.Duration, req.WordUses)
+		if err != nil {
+			return err
+		}
+		if err := tx.DeletePlayerWordsByLobbyCode(ctx, lobbyCode); err != nil {
+			log.Printf("Error deleting player words before game start: %v", err)
+			return err
+		}
+		if err := tx.ResetPlayerPoints(ctx, lobbyCode); err != nil {
+			return err
+		}
+		if err := tx.DeleteWordUses(ctx, lobbyCode); err != nil {
+			return err
+		}
+		return SeedPlayerWords(ctx, tx, lobbyCode, game)
+	})
+	if err != nil {
+		return err
+	}
+	if err := game.StartTimer(s); err != nil {
+		return err
+	}
+	s.setGame(game)
+	log.Printf("Game created\nLobby code: %s", lobbyCode)
+	log.Printf("Game mode: %s", game.GameMode)
+	log.Printf("Timer: %v", game.WithTimer)
+	log.Println("Target words: ", game.TargetWords)
+	s.broker.PublishToLobby(lobbyCode, Message{Data: c.GAME_STARTED})
+	s.saveGame(ctx, game)
+	return u.WriteJSON(w, http.StatusOK, dto.GenericResponse{Message: "Game started"})
+}
+
+// HandleCombination godoc
+// @Summary Make a move
+// @Description Make a move
+// @Tags game
+// @Accept json
+// @Produce json
+// @Param move body dto.WordRequest true "Move to make"
+// @Security BearerAuth
+// @Param lobbyCode path string true "Lobby code"
+// @Param playerName path string true "Player name"
+// @Success 200 {object} dto.WordResponse
+// @Failure 400 {object} dto.APIError
+// @Failure 401 {object} dto.APIError
+// @Failure 403 {object} dto.APIError
+// @Failure 404 {object} dto.APIError
+// @Failure 405 {object} dto.APIError
+// @Failure 409 {object} dto.APIError
+// @Failure 500 {object} dto.APIError
+// @Router /games/{lobbyCode}/{playerName}/combinations [post]
+func (s *GameService) HandleCombination(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		err := u.WriteJSON(w, http.StatusMethodNotAllowed, dto.APIError{Error: "Method not allowed", Code: c.METHOD_NOT_ALLOWED})
+		return err
+	}
+	lobbyCode, err := u.GetLobbyCode(r)
+	if err != nil {
+		return err
+	}
+	req := new(dto.WordRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return ae.Wrap(ae.Validation, err, "Invalid request body")
+	}
+	playerName, err := u.GetPlayername(r)
+	if err != nil {
+		return err
+	}
+	resp, err := s.Combine(r.Context(), lobbyCode, playerName, req)
+	if err != nil {
+		return err
+	}
+	return u.WriteJSON(w, http.StatusOK, resp)
+}
+
+// Combine plays a move for a player, shared by the REST and WebSocket transports
+func (s *GameService) Combine(ctx context.Context, lobbyCode, playerName string, req *dto.WordRequest) (*dto.WordResponse, error) {
+	game := s.getGame(lobbyCode)
+	if game == nil {
+		return nil, ae.NotFoundf("Game not found")
+	}
+	if winner, _ := game.Result(); winner != "" {
+		return nil, ae.Conflictf("The game is over")
+	}
+	// Player words are stored in lower case, the same words are checked, consumed and combined
+	a, b := strings.ToLower(req.A), strings.ToLower(req.B)
+	for _, word := range []string{a, b} {
+		owned, err := s.store.IsPlayerWord(ctx, playerName, word, lobbyCode)
+		if err != nil {
+			return nil, err
+		}
+		if !owned {
+			return nil, ae.Forbiddenf("Word %s is not in your inventory", word).WithCode(c.WORD_NOT_OWNED)
+		}
+	}
+	if game.GameMode == c.FINITE_FUSION {
+		// Consumed up front, so that concurrent moves can not spend the same uses twice
+		if err := game.WordUses.Consume(playerName, a, b); err != nil {
+			return nil, err
+		}
+	}
+	result, isNew, err := s.move(ctx, game, playerName, a, b)
+	if err != nil {
+		if game.GameMode == c.FINITE_FUSION {
+			game.WordUses.Refund(playerName, a, b)
+		}
+		return nil, err
+	}
+	if game.GameMode == c.FINITE_FUSION {
+		s.saveWordUses(ctx, game, playerName, a, b, result)
+	}
+	return &dto.WordResponse{Result: result, IsNew: isNew}, nil
+}
+
+// move looks up the combination and applies it to the player
+func (s *GameService) move(ctx context.Context, game *Game, playerName, a, b string) (string, bool, error) {
+	result, isNew, err := s.combinations.GetCombination(ctx, s.store, s.combiner, a, b)
+	if err != nil {
+		return "", false, err
+	}
+	if isNew {
+		s.recipes.add(graph.Recipe{A: a, B: b, Result: result})
+	}
+	player, err := s.store.GetPlayerByLobbyCodeAndName(ctx, playerName, game.LobbyCode)
+	if err != nil {
+		return "", false, err
+	}
+	log.Printf("Player %s played %s + %s = %s", playerName, a, b, result)
+	if err := ProcessMove(ctx, s, game, player, result, isNew); err != nil {
+		return "", false, err
+	}
+	return result, isNew, nil
+}
+
+// handleGetGameStats godoc
+// @Summary Get game stats
+// @Description Get game stats
+// @Tags game
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param lobbyCode path string true "Lobby code"
+// @Param playerName path string true "Player name"
+// @Success 200 {object} dto.GameEndResponse
+// @Failure 400 {object} dto.APIError
+// @Failure 401 {object} dto.APIError
+// @Failure 403 {object} dto.APIError
+// @Failure 404 {object} dto.APIError
+// @Failure 405 {object} dto.APIError
+// @Failure 500 {object} dto.APIError
+// @Router /games/{lobbyCode}/{playerName}/game [get]
+func (s *GameService) handleGetGameStats(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	lobbyCode, err := u.GetLobbyCode(r)
+	if err != nil {
+		return err
+	}
+	game := s.getGame(lobbyCode)
+	if game == nil {
+		return ae.NotFoundf("Game not found")
+	}
+	winner, manualEnd := game.Result()
+	playerWordCounts, err := s.store.GetWordCountByLobbyCode(ctx, lobbyCode)
+	if err != nil {
+		return err
+	}
+	playerWordsDTO := []*dto.PlayerResultDTO{}
+	for _, playerWordCount := range playerWordCounts {
+		player, err := s.store.GetPlayerByLobbyCodeAndName(ctx, playerWordCount.PlayerName, lobbyCode)
+		if err != nil {
+			return err
+		}
+		img, err := s.store.GetImage(ctx, player.ImageName)
+		if err != nil {
+			return err
+		}
+		playerWordsDTO = append(playerWordsDTO, &dto.PlayerResultDTO{PlayerName: player.Name, Image: img, WordCount: playerWordCount.WordCount, Points: player.Points + playerWordCount.WordCount})
+	}
+	sort.Slice(playerWordsDTO, func(i, j int) bool {
+		if playerWordsDTO[i].PlayerName == winner {
+			return true
+		}
+		if playerWordsDTO[j].PlayerName == winner {
+			return false
+		}
+		return playerWordsDTO[i].Points > playerWordsDTO[j].Points
+	})
+	return u.WriteJSON(w, http.StatusOK, dto.GameEndResponse{Winner: winner, PlayerWords: playerWordsDTO, GameMode: game.GameMode, ManualEnd: manualEnd})
+}
+
+// HandleManualGameEnd godoc
+// @Summary End a game (owner)
+// @Description End a game
+// @Tags game
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param lobbyCode path string true "Lobby code"
+// @Param playerName path string true "Player name"
+// @Success 200 {object} dto.GenericResponse
+// @Failure 400 {object} dto.APIError
+// @Failure 401 {object} dto.APIError
+// @Failure 403 {object} dto.APIError
+// @Failure 404 {object} dto.APIError
+// @Failure 405 {object} dto.APIError
+// @Failure 500 {object} dto.APIError
+// @Router /games/{lobbyCode}/{playerName}/end [post]
+func (s *GameService) HandleManualGameEnd(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	if r.Method != http.MethodPost {
+		err := u.WriteJSON(w, http.StatusMethodNotAllowed, dto.APIError{Error: "Method not allowed", Code: c.METHOD_NOT_ALLOWED})
+		return err
+	}
+	playerClaims := r.Context().Value(t.AuthKey{}).(*t.PlayerClaims)
+	if !playerClaims.IsOwner {
+		return ae.Forbiddenf(c.Unauthorized)
+	}
+	lobbyCode, err := u.GetLobbyCode(r)
+	if err != nil {
+		return err
+	}
+	game := s.getGame(lobbyCode)
+	if game == nil {
+		return ae.NotFoundf("Game not found")
+	}
+	ended, err := s.endGame(ctx, game, "", true)
+	if err != nil {
+		return err
+	}
+	if !ended {
+		return ae.Conflictf("The game is over")
+	}
+	return u.WriteJSON(w, http.StatusOK, dto.GenericResponse{Message: "Game ended"})
+}
+
+// endGame is the only way a game ends: the winner is selected unless it is given, the accounts are settled,
+// then the game is saved and the lobby is told. It returns false if the game is over already or another request is ending it.
+func (s *GameService) endGame(ctx context.Context, game *Game, winner string, manualEnd bool) (bool, error) {
+	if !game.startEnd() {
+		return false, nil
+	}
+	if winner == "" {
+		var err error
+		winner, err = game.SelectWinner(ctx, s.store)
+		if err != nil {
+			game.cancelEnd()
+			return false, err
+		}
+	}
+	if err := s.store.UpdateAccountWinsAndLosses(ctx, game.LobbyCode, winner); err != nil {
+		game.cancelEnd()
+		return false, err
+	}
+	game.StopTimer()
+	game.SetWinner(winner, manualEnd)
+	s.saveGame(ctx, game)
+	s.broker.PublishToLobby(game.LobbyCode, Message{Data: c.GAME_OVER})
+	s.broker.PublishToLobby(game.LobbyCode, Message{Data: c.ACCOUNT_UPDATE})
+	return true, nil
+}
+
+// Game Logic
+func (g *Game) SetTarget() (string, error) {
+	if g.GameMode == c.VANILLA {
+		return "", nil
+	}
+	if g.GameMode == c.WOMBO_COMBO {
+		log.Println("Number of target words ", len(g.TargetWords))
+		targetWord := g.TargetWords[rand.Intn(len(g.TargetWords))]
+		return targetWord, nil
+	}
+	if g.GameMode == c.FUSION_FRENZY {
+		return g.TargetWord, nil
+	}
+	if g.GameMode == c.DAILY_CHALLENGE {
+		return g.TargetWord, nil
+	}
+	if g.GameMode == c.FINITE_FUSION {
+		return "", nil
+	}
+	return "", fmt.Errorf("Game mode %s not found", g.GameMode)
+}
+
+// Finite Fusion is decided by the number of words found, all other modes by points
+func (g *Game) SelectWinner(ctx context.Context, s st.Storage) (string, error) {
+	if g.GameMode != c.FINITE_FUSION {
+		return s.SelectWinnerByPoints(ctx, g.LobbyCode)
+	}
+	wordCounts, err := s.GetWordCountByLobbyCode(ctx, g.LobbyCode)
+	if err != nil {
+		return "", err
+	}
+	if len(wordCounts) == 0 {
+		return "", fmt.Errorf("No players found")
+	}
+	return wordCounts[0].PlayerName, nil
+}
+
+func ProcessMove(ctx context.Context, server *GameService, game *Game, player *st.Player, result string, isNew bool) error {
+	if game.GameMode == c.FUSION_FRENZY && player.TargetWord == result {
+		ended, err := server.endGame(ctx, game, player.Name, false)
+		if err != nil {
+			return err
+		}
+		if !ended {
+			return ae.Conflictf("The game is over")
+		}
+		return server.store.UpdateAccountWordCount(ctx, player.Name, player.NewWordCount, player.WordCount)
+	}
+	if game.GameMode == c.WOMBO_COMBO && player.TargetWord == result {
+		var newTargetWord string
+		var err error
+		for {
+			newTargetWord, err = game.SetTarget()
+			if err != nil {
+				return err
+			}
+			if newTargetWord != player.TargetWord {
+				break
+			}
+		}
+		log.Printf("Player %s reached target word %s, new target word is %s", player.Name, player.TargetWord, newTargetWord)
+		if err := server.store.SetPlayerTargetWord(ctx, player.Name, newTargetWord, game.LobbyCode); err != nil {
+			return err
+		}
+		if err := server.store.IncrementPlayerPoints(ctx, player.Name, game.LobbyCode, 10); err != nil {
+			return err
+		}
+		server.broker.PublishToLobby(game.LobbyCode, Message{Data: c.WOMBO_COMBO_EVENT})
+	}
+	if game.GameMode == c.DAILY_CHALLENGE && player.TargetWord == result {
+		// Played solo and without wins and losses, but claimed like every other end so that it is recorded once
+		if !game.startEnd() {
+			return ae.Conflictf("The game is over")
+		}
+		wordCounts, err := server.store.GetWordCountByLobbyCode(ctx, game.LobbyCode)
+		if err != nil {
+			game.cancelEnd()
+			return err
+		}
+		wordCount := wordCounts[0].WordCount
+		log.Printf("Player %s completed daily challenge with word count %d", player.Name, wordCount)
+		if err := server.store.AddDailyChallengeEntry(ctx, wordCount+1, player.Name); err != nil {
+			game.cancelEnd()
+			return err
+		}
+		game.SetWinner(player.Name, false)
+		server.saveGame(ctx, game)
+		server.broker.PublishToLobby(game.LobbyCode, Message{Data: c.GAME_OVER})
+		return nil
+	}
+	if err := server.store.AddPlayerWord(ctx, player.Name, result, game.LobbyCode); err != nil {
+		return err
+	}
+	updatedWordCnt := player.WordCount + 1
+	updatedNewWordCnt := player.NewWordCount
+	if isNew {
+		updatedNewWordCnt = player.NewWordCount + 1
+	}
+	if err := server.store.UpdatePlayerWordCount(ctx, player.Name, game.LobbyCode, updatedNewWordCnt, updatedWordCnt); err != nil {
+		return err
+	}
+	if err := CheckAchievements(ctx, server, player.Name, updatedWordCnt, updatedNewWordCnt, result); err != nil {
+		return err
+	}
+	if game.GameMode == c.FINITE_FUSION {
+		game.WordUses.Add(player.Name, result)
+		if !game.WordUses.AnyMoveLeft() {
+			return server.EndFiniteFusion(ctx, game)
+		}
+	}
+	return nil
+}
+
+func (g *Game) StartTimer(s *GameService) error {
+	if !g.WithTimer {
+		return nil
+	}
+	return g.Timer.Start(s, g.LobbyCode, g)
+}
+
+func (g *Game) StopTimer() {
+	if g.WithTimer {
+		g.Timer.Stop()
+	}
+}
+
+func (g *Game) SuspendTimer() {
+	if g.WithTimer {
+		g.Timer.Suspend()
+	}
+}
+
+func SeedPlayerWords(ctx context.Context, s st.Storage, lobbyCode string, game *Game) error {
+	players, err := s.GetPlayersByLobbyCode(ctx, lobbyCode)
+	if err != nil {
+		return err
+	}
+	for _, player := range players {
+		target, err := game.SetTarget()
+		if err != nil {
+			return err
+		}
+		if err := s.SetPlayerTargetWord(ctx, player.Name, target, lobbyCode); err != nil {
+			return err
+		}
+		s.AddPlayerWord(ctx, player.Name, "fire", lobbyCode)
+		s.AddPlayerWord(ctx, player.Name, "water", lobbyCode)
+		s.AddPlayerWord(ctx, player.Name, "earth", lobbyCode)
+		s.AddPlayerWord(ctx, player.Name, "wind", lobbyCode)
+		if game.GameMode == c.FINITE_FUSION {
+			for _, word := range []string{"fire", "water", "earth", "wind"} {
+				game.WordUses.Add(player.Name, word)
+			}
+		}
+	}
+	return nil
+}
+
+func NewGame(ctx context.Context, s st.Storage, lobbyCode string, gameMode c.GameMode, withTimer bool, duration, wordUses int) (*Game, error) {
+	game := new(Game)
+	game.LobbyCode = lobbyCode
+	game.GameMode = gameMode
+	game.WithTimer = withTimer
+	game.ManualEnd = false
+
+	if withTimer {
+		if err := validateDuration(duration); err != nil {
+			return nil, err
+		}
+		game.Timer = NewTimer(duration)
+	}
+
+	var err error = ae.Validationf("Game mode %s not found", gameMode)
+	if gameMode == c.VANILLA {
+		return game, nil
+	}
+	if gameMode == c.FINITE_FUSION {
+		game.WordUses = NewWordUses(wordUses)
+		return game, nil
+	}
+	// Reachability is between 0 and 1
+	// Reachability is computed with: 1 / (2 ^ depth)
+	// Reachability is updated with:
+	// 0.75 * newReachability + 0.25 * oldReachability if newDepth < oldDepth
+	// 0.25 * newReachability + 0.75 * oldReachability if newDepth >= oldDepth
+	// The less deep and the more paths are available, the more reachable a word is
+	if gameMode == c.FUSION_FRENZY {
+		game.TargetWord, err = s.GetTargetWord(ctx, 0.0375, 0.2, 10)
+		if err != nil {
+			return nil, err
+		}
+		return game, nil
+	}
+	if gameMode == c.WOMBO_COMBO {
+		game.TargetWords, err = s.GetTargetWords(ctx, 0.0375, 0.2, 10)
+		if err != nil {
+			return nil, err
+		}
+		return game, nil
+	}
+	if gameMode == c.DAILY_CHALLENGE {
+		game.TargetWord, err = s.CreateOrGetDailyWord(ctx, 0.0375, 0.2, 8)
+		if err != nil {
+			log.Printf("Error creating or getting daily word: %v", err)
+			return nil, err
+		}
+		return game, nil
+	}
+	return nil, err
+}
+
+// Logout deletes the lobby of an owner that logs out as part of the caller's transaction tx,
+// LobbyDeleted has to be called once tx is committed
+func (s *GameService) Logout(ctx context.Context, tx st.Storage, lobbyCode, username string) error {
+	return deleteLobby(ctx, tx, lobbyCode, username)
+}
+
+// LobbyDeleted removes the game of a deleted lobby and tells its clients, the deletion has to be committed already
+func (s *GameService) LobbyDeleted(ctx context.Context, lobbyCode string) {
+	s.deleteGame(ctx, lobbyCode)
+	s.broker.PublishToLobby(lobbyCode, Message{Data: c.GAME_DELETED})
+	s.broker.RemoveLobby(lobbyCode)
+	s.broker.Publish(Message{Data: c.LOBBY_DELETED})
+}
+
+// handleLeaderboard godoc
+// @Summary Get the leaderboard
+// @Description Get the leaderboard
+// @Tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param username path string true "Username"
+// @Success 200 {array} dto.ChallengeEntryDTO
+// @Failure 400 {object} dto.APIError
+// @Failure 401 {object} dto.APIError
+// @Failure 403 {object} dto.APIError
+// @Failure 404 {object} dto.APIError
+// @Failure 405 {object} dto.APIError
+// @Failure 500 {object} dto.APIError
+// @Router /account/{username}/leaderboard [get]
+func (s *GameService) HandleLeaderboard(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	if r.Method != http.MethodGet {
+		err := u.WriteJSON(w, http.StatusMethodNotAllowed, dto.APIError{Error: "Method not allowed", Code: c.METHOD_NOT_ALLOWED})
+		return err
+	}
+	entries, err := s.store.GetChallengeEntries(ctx)
+	if err != nil {
+		return err
+	}
+	entriesDTO := []*dto.ChallengeEntryDTO{}
+	for _, entry := range entries {
+		image, err := s.store.GetImageByUsername(ctx, entry.Username)
+		if err != nil {
+			return err
+		}
+		entriesDTO = append(entriesDTO, &dto.ChallengeEntryDTO{WordCount: entry.WordCount, Username: entry.Username, Image: image})
+	}
+	sort.Slice(entriesDTO, func(i, j int) bool {
+		return entriesDTO[i].WordCount < entriesDTO[j].WordCount
+	})
+	return u.WriteJSON(w, http.StatusOK, entriesDTO)
+}
@@ -0,0 +1,194 @@
+package game
+
+// Hints reveal the next step of the shortest known recipe chain to a player's target word
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	ae "github.com/na50r/wombo-combo-go-be/apierror"
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	dto "github.com/na50r/wombo-combo-go-be/dto"
+	"github.com/na50r/wombo-combo-go-be/graph"
+	st "github.com/na50r/wombo-combo-go-be/storage"
+	u "github.com/na50r/wombo-combo-go-be/utility"
+)
+
+type HintConfig struct {
+	Cost     int           // Points deducted per hint
+	Cooldown time.Duration // Time between two hints of a player, 0 disables the limit
+}
+
+// One hint per player and cooldown, tracked per instance
+type hintLimiter struct {
+	mu       sync.Mutex
+	cooldown time.Duration
+	last     map[string]time.Time
+}
+
+func newHintLimiter(cooldown time.Duration) *hintLimiter {
+	return &hintLimiter{cooldown: cooldown, last: make(map[string]time.Time)}
+}
+
+// allow takes a hint for key, if it is too early it returns the time left instead
+func (l *hintLimiter) allow(key string, now time.Time) (time.Duration, bool) {
+	if l.cooldown <= 0 {
+		return 0, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if last, ok := l.last[key]; ok {
+		if wait := last.Add(l.cooldown).Sub(now); wait > 0 {
+			return wait, false
+		}
+	}
+	// Expired entries are dropped here, players of ended games do not pile up
+	for k, last := range l.last {
+		if now.Sub(last) >= l.cooldown {
+			delete(l.last, k)
+		}
+	}
+	l.last[key] = now
+	return 0, true
+}
+
+// recipeGraph caches the recipe graph for hints. It is loaded from the combination table on the
+// first hint and extended by the combinations discovered on this instance afterwards;
+// combinations added by other instances or imports are only picked up after a restart.
+type recipeGraph struct {
+	loadMu  sync.Mutex   // one load at a time, moves do not wait for it
+	mu      sync.RWMutex // guards graph, loading and pending
+	graph   *graph.Graph // nil until loaded
+	loading bool
+	pending []graph.Recipe // discovered while loading, the load may have missed them
+}
+
+func (r *recipeGraph) load(ctx context.Context, store st.Storage) error {
+	r.loadMu.Lock()
+	defer r.loadMu.Unlock()
+	r.mu.Lock()
+	if r.graph != nil {
+		r.mu.Unlock()
+		return nil
+	}
+	r.loading = true
+	r.mu.Unlock()
+
+	combinations, err := store.GetCombinations(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loading = false
+	pending := r.pending
+	r.pending = nil
+	if err != nil {
+		return err
+	}
+	g := graph.New(combinations)
+	for _, recipe := range pending {
+		g.Add(recipe)
+	}
+	r.graph = g
+	return nil
+}
+
+func (r *recipeGraph) add(recipe graph.Recipe) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.graph != nil {
+		r.graph.Add(recipe)
+	} else if r.loading {
+		r.pending = append(r.pending, recipe)
+	}
+}
+
+func (r *recipeGraph) path(ctx context.Context, store st.Storage, have []string, target string) ([]graph.Recipe, bool, error) {
+	if err := r.load(ctx, store); err != nil {
+		return nil, false, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	path, ok := r.graph.Path(have, target)
+	return path, ok, nil
+}
+
+// HandleHint godoc
+// @Summary Get a hint
+// @Description Reveals the next combination of the shortest known recipe chain to the target word. Costs points, one hint per cooldown
+// @Tags game
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param lobbyCode path string true "Lobby code"
+// @Param playerName path string true "Player name"
+// @Success 200 {object} dto.HintResponse
+// @Failure 401 {object} dto.APIError
+// @Failure 403 {object} dto.APIError
+// @Failure 404 {object} dto.APIError
+// @Failure 405 {object} dto.APIError
+// @Failure 409 {object} dto.APIError
+// @Failure 429 {object} dto.APIError
+// @Failure 500 {object} dto.APIError
+// @Router /games/{lobbyCode}/{playerName}/hint [post]
+func (s *GameService) HandleHint(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		err := u.WriteJSON(w, http.StatusMethodNotAllowed, dto.APIError{Error: "Method not allowed", Code: c.METHOD_NOT_ALLOWED})
+		return err
+	}
+	lobbyCode, err := u.GetLobbyCode(r)
+	if err != nil {
+		return err
+	}
+	playerName, err := u.GetPlayername(r)
+	if err != nil {
+		return err
+	}
+	hint, err := s.Hint(r.Context(), lobbyCode, playerName)
+	if err != nil {
+		return err
+	}
+	return u.WriteJSON(w, http.StatusOK, hint)
+}
+
+// Hint finds the next step to the player's target word and deducts the hint cost from their points
+func (s *GameService) Hint(ctx context.Context, lobbyCode, playerName string) (*dto.HintResponse, error) {
+	game := s.getGame(lobbyCode)
+	if game == nil {
+		return nil, ae.NotFoundf("Game not found")
+	}
+	target, err := s.store.GetPlayerTargetWord(ctx, playerName, lobbyCode)
+	if err != nil {
+		return nil, err
+	}
+	if target == "" {
+		return nil, ae.Conflictf("There is no target word in %s", game.GameMode)
+	}
+	if winner, _ := game.Result(); winner != "" {
+		return nil, ae.Conflictf("The game is over")
+	}
+	words, err := s.store.GetPlayerWords(ctx, playerName, lobbyCode)
+	if err != nil {
+		return nil, err
+	}
+	path, ok, err := s.recipes.path(ctx, s.store, words, target)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ae.NotFoundf("No known recipe leads to %s from your words", target)
+	}
+	if len(path) == 0 {
+		return nil, ae.Conflictf("You already have %s", target)
+	}
+	// Only hints that are given take the cooldown
+	if wait, ok := s.hints.allow(lobbyCode+"/"+playerName, time.Now()); !ok {
+		return nil, ae.RateLimitedf("Next hint in %s", wait.Round(time.Second))
+	}
+	if err := s.store.IncrementPlayerPoints(ctx, playerName, lobbyCode, -s.hintConfig.Cost); err != nil {
+		return nil, err
+	}
+	next := path[0]
+	return &dto.HintResponse{A: next.A, B: next.B, Result: next.Result, Steps: len(path), Cost: s.hintConfig.Cost}, nil
+}
@@ -0,0 +1,47 @@
+package game
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/na50r/wombo-combo-go-be/graph"
+	st "github.com/na50r/wombo-combo-go-be/storage"
+)
+
+func TestHintLimiter(t *testing.T) {
+	limiter := newHintLimiter(time.Minute)
+	now := time.Now()
+	if _, ok := limiter.allow("abc/alice", now); !ok {
+		t.Fatal("first hint was not allowed")
+	}
+	if wait, ok := limiter.allow("abc/alice", now.Add(time.Second)); ok || wait != 59*time.Second {
+		t.Fatalf("second hint: wait %s, allowed %v", wait, ok)
+	}
+	if _, ok := limiter.allow("abc/bob", now); !ok {
+		t.Fatal("players share the cooldown")
+	}
+	if _, ok := limiter.allow("abc/alice", now.Add(time.Minute)); !ok {
+		t.Fatal("hint after the cooldown was not allowed")
+	}
+}
+
+func TestRecipeGraphKeepsDiscoveries(t *testing.T) {
+	ctx := context.Background()
+	store := st.NewMemoryStore()
+	if err := store.AddCombination(ctx, &st.Combination{A: "fire", B: "water", Result: "steam"}); err != nil {
+		t.Fatal(err)
+	}
+	recipes := &recipeGraph{}
+	// Not loaded yet, the table will contain it
+	recipes.add(graph.Recipe{A: "fire", B: "water", Result: "steam"})
+	if _, ok, err := recipes.path(ctx, store, []string{"fire", "water", "earth"}, "geyser"); err != nil || ok {
+		t.Fatalf("path to geyser before it was discovered: %v %v", ok, err)
+	}
+	// Discovered after the load, the graph is not loaded again
+	recipes.add(graph.Recipe{A: "steam", B: "earth", Result: "geyser"})
+	path, ok, err := recipes.path(ctx, store, []string{"fire", "water", "earth"}, "geyser")
+	if err != nil || !ok || len(path) != 2 {
+		t.Fatalf("path %v %v %v, want 2 steps", path, ok, err)
+	}
+}
@@ -1,157 +1,344 @@
-package game
-
-// Implement SSE for game events
-
-import (
-	"log"
-	"net/http"
-	"encoding/json"
-	"github.com/na50r/wombo-combo-go-be/sse"
-	t "github.com/na50r/wombo-combo-go-be/token"
-)
-
-type Message struct {
-	Data interface{} `json:"data"`
-}
-
-func (m *Message) toSSE() sse.Message {
-	return sse.Message{
-		Data: m.Data,
-	}
-}
-
-type GameBroker struct {
-	Broker       sse.Broker
-	lobbyClients map[string]map[int]bool
-	playerClient map[string]int
-}
-
-type PlayerSubscription struct {
-	sse.BaseSubscription
-	LobbyCode  string
-	PlayerName string
-	IsPlayer   bool
-}
-
-func NewGameBroker() *GameBroker {
-	gb := &GameBroker{
-		lobbyClients: make(map[string]map[int]bool),
-		playerClient: make(map[string]int),
-	}
-
-	gb.Broker = *sse.NewBroker(
-		gb.OnNewPlayerSub,
-		gb.OnRemovePlayerSub,
-		MakePlayerSubscription,
-	)
-	return gb
-}
-
-func (ps PlayerSubscription) GetChannelID() int       { return ps.ChannelID }
-func (ps PlayerSubscription) GetChannel() chan []byte { return ps.Channel }
-
-func MakePlayerSubscription(r *http.Request, id int, channel chan []byte) sse.Subscription {
-	token, tokenExists := t.GetToken(r)
-	ps := PlayerSubscription{
-		BaseSubscription: sse.NewSubscription(id, channel),
-		IsPlayer:         false,
-	}
-	if !tokenExists {
-		return ps
-	}
-	claims, err := t.VerifyPlayerJWT(token)
-	if err != nil {
-		log.Printf("JWT verification failed: %v", err)
-		return ps
-	}
-	ps.PlayerName = claims.PlayerName
-	ps.LobbyCode = claims.LobbyCode
-	ps.IsPlayer = true
-	return ps
-}
-
-func (gb *GameBroker) OnNewPlayerSub(sub sse.Subscription) {
-	ps, ok := sub.(PlayerSubscription)
-	if !ok {
-		log.Println("Type conversion failed")
-		return
-	}
-	if !ps.IsPlayer {
-		return
-	}
-	if gb.lobbyClients[ps.LobbyCode] == nil {
-		gb.lobbyClients[ps.LobbyCode] = make(map[int]bool)
-	}
-	gb.lobbyClients[ps.LobbyCode][ps.ChannelID] = true
-	gb.playerClient[ps.PlayerName] = ps.ChannelID
-}
-
-func (gb *GameBroker) OnRemovePlayerSub(unsub sse.Subscription) {
-	ps, ok := unsub.(PlayerSubscription)
-	if !ok {
-		log.Println("Type conversion failed")
-		return
-	}
-	if !ps.IsPlayer {
-		return
-	}
-	delete(gb.lobbyClients[ps.LobbyCode], ps.ChannelID)
-	delete(gb.playerClient, ps.PlayerName)
-	log.Printf("player %s (ch=%d) disconnected from lobby %s", ps.PlayerName, ps.ChannelID, ps.LobbyCode)
-}
-
-func (gb *GameBroker) PublishToLobby(lobbyCode string, msg Message) {
-	group := gb.lobbyClients[lobbyCode]
-	gb.Broker.PublishToGroup(group, msg.toSSE())
-}
-
-func (gb *GameBroker) PublishToPlayer(playername string, msg Message) {
-	cli := gb.playerClient[playername]
-	gb.Broker.PublishToClient(cli, msg.toSSE())
-}
-
-func (gb *GameBroker) Publish(msg Message) {
-	gb.Broker.Publish(msg.toSSE())
-}
-
-// SSEHandler godoc
-// @Summary Server-Sent Events
-// @Description Server-Sent Events
-// @Tags events
-// @Accept json
-// @Produce json
-// @Success 200 {object} Message
-// @Failure 400 {object} dto.APIError
-// @Failure 405 {object} dto.APIError
-// @Router /events [get]
-func (gs *GameService) SSEHandler(w http.ResponseWriter, r *http.Request) {
-	gs.broker.Broker.SSEHandler(w, r)
-}
-
-//$ curl -X POST -H "Content-Type: application/json" -d '{"data": "Hello World"}' http://localhost:<port>/broadcast
-
-// Broadcast godoc
-// @Summary Broadcast a message to all clients
-// @Description Broadcast a message to all clients
-// @Tags events
-// @Accept json
-// @Produce json
-// @Param message body Message true "Message to broadcast"
-// @Success 200 {string} string "Message sent"
-// @Failure 400 {string} string "Bad request"
-// @Failure 405 {string} string "Method not allowed"
-// @Router /broadcast [post]
-func (gs *GameService) Broadcast(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	var m Message
-	err := json.NewDecoder(r.Body).Decode(&m)
-	if err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
-		return
-	}
-	gs.broker.Broker.Publish(m.toSSE())
-	w.Write([]byte("Msg sent\n"))
-}
\ No newline at end of file
+package game
+
+// Implement SSE for game events
+
+import (
+	"encoding/json"
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	dto "github.com/na50r/wombo-combo-go-be/dto"
+	"github.com/na50r/wombo-combo-go-be/sse"
+	t "github.com/na50r/wombo-combo-go-be/token"
+	u "github.com/na50r/wombo-combo-go-be/utility"
+	"log"
+	"net/http"
+	"sync"
+)
+
+type Message struct {
+	Data interface{} `json:"data"`
+}
+
+// Event name of the message, so that clients can listen to specific events
+func (m *Message) Event() c.EventMesage {
+	switch data := m.Data.(type) {
+	case c.EventMesage:
+		return data
+	case dto.TimeEvent:
+		return c.TIME_LEFT
+	case dto.GameEditEvent:
+		return c.GAME_EDITED
+	case dto.AchievementEvent:
+		return c.ACHIEVEMENT_UNLOCKED
+	}
+	return c.MESSAGE
+}
+
+func (m *Message) toSSE() sse.Message {
+	return sse.Message{
+		Event: string(m.Event()),
+		Data:  m.Data,
+	}
+}
+
+// Ring buffer of the latest events of a lobby, replayed to clients that reconnect
+type eventHistory struct {
+	events []sse.Message
+	next   int
+}
+
+func (h *eventHistory) add(msg sse.Message, size int) {
+	if len(h.events) < size {
+		h.events = append(h.events, msg)
+		return
+	}
+	h.events[h.next] = msg
+	h.next = (h.next + 1) % size
+}
+
+// since returns the events after lastID, oldest first
+func (h *eventHistory) since(lastID uint64) []sse.Message {
+	missed := []sse.Message{}
+	for i := range h.events {
+		msg := h.events[(h.next+i)%len(h.events)]
+		if msg.ID > lastID {
+			missed = append(missed, msg)
+		}
+	}
+	return missed
+}
+
+type GameBroker struct {
+	Broker       *sse.Broker
+	backplane    Backplane
+	mu           sync.RWMutex // guards lobbyClients, playerClient and history
+	lobbyClients map[string]map[int]bool
+	playerClient map[string]int
+	history      map[string]*eventHistory
+}
+
+type PlayerSubscription struct {
+	sse.BaseSubscription
+	LobbyCode   string
+	PlayerName  string
+	IsPlayer    bool
+	LastEventID uint64
+}
+
+func NewGameBroker(config sse.Config, backplane Backplane) *GameBroker {
+	if backplane == nil {
+		backplane = NewLocalBackplane()
+	}
+	gb := &GameBroker{
+		backplane:    backplane,
+		lobbyClients: make(map[string]map[int]bool),
+		playerClient: make(map[string]int),
+		history:      make(map[string]*eventHistory),
+	}
+	backplane.Subscribe(gb.deliver)
+
+	gb.Broker = sse.NewBroker(
+		config,
+		gb.OnNewPlayerSub,
+		gb.OnRemovePlayerSub,
+		MakePlayerSubscription,
+	)
+	return gb
+}
+
+func (ps PlayerSubscription) GetChannelID() int          { return ps.ChannelID }
+func (ps PlayerSubscription) GetChannel() chan sse.Frame { return ps.Channel }
+
+func MakePlayerSubscription(r *http.Request, id int, channel chan sse.Frame) sse.Subscription {
+	token, tokenExists := t.GetStreamToken(r)
+	ps := PlayerSubscription{
+		BaseSubscription: sse.NewSubscription(id, channel),
+		IsPlayer:         false,
+		LastEventID:      sse.LastEventID(r),
+	}
+	if !tokenExists {
+		return ps
+	}
+	claims, err := t.VerifyPlayerJWT(token)
+	if err != nil {
+		log.Printf("JWT verification failed: %v", err)
+		return ps
+	}
+	ps.PlayerName = claims.PlayerName
+	ps.LobbyCode = claims.LobbyCode
+	ps.IsPlayer = true
+	return ps
+}
+
+func (gb *GameBroker) OnNewPlayerSub(sub sse.Subscription) {
+	ps, ok := sub.(PlayerSubscription)
+	if !ok {
+		log.Println("Type conversion failed")
+		return
+	}
+	if !ps.IsPlayer {
+		return
+	}
+	gb.mu.Lock()
+	defer gb.mu.Unlock()
+	if gb.lobbyClients[ps.LobbyCode] == nil {
+		gb.lobbyClients[ps.LobbyCode] = make(map[int]bool)
+	}
+	gb.lobbyClients[ps.LobbyCode][ps.ChannelID] = true
+	gb.playerClient[ps.PlayerName] = ps.ChannelID
+	if ps.LastEventID == 0 || gb.history[ps.LobbyCode] == nil {
+		return
+	}
+	// Replay while holding the lock, so that no event of the lobby is published in between
+	missed := gb.history[ps.LobbyCode].since(ps.LastEventID)
+	for _, msg := range missed {
+		gb.Broker.PublishToClient(ps.ChannelID, msg)
+	}
+	log.Printf("replayed %d events to player %s (ch=%d)", len(missed), ps.PlayerName, ps.ChannelID)
+}
+
+func (gb *GameBroker) OnRemovePlayerSub(unsub sse.Subscription) {
+	ps, ok := unsub.(PlayerSubscription)
+	if !ok {
+		log.Println("Type conversion failed")
+		return
+	}
+	if !ps.IsPlayer {
+		return
+	}
+	gb.mu.Lock()
+	delete(gb.lobbyClients[ps.LobbyCode], ps.ChannelID)
+	if gb.playerClient[ps.PlayerName] == ps.ChannelID {
+		delete(gb.playerClient, ps.PlayerName)
+	}
+	gb.mu.Unlock()
+	log.Printf("player %s (ch=%d) disconnected from lobby %s", ps.PlayerName, ps.ChannelID, ps.LobbyCode)
+}
+
+// RemovePlayer unsubscribes a player that left the lobby from its events
+func (gb *GameBroker) RemovePlayer(lobbyCode, playerName string) {
+	gb.send(Envelope{Target: TargetRemovePlayer, Key: lobbyCode, Player: playerName})
+}
+
+func (gb *GameBroker) RemoveLobby(lobbyCode string) {
+	gb.send(Envelope{Target: TargetRemoveLobby, Key: lobbyCode})
+}
+
+func (gb *GameBroker) removePlayer(lobbyCode, playerName string) {
+	gb.mu.Lock()
+	defer gb.mu.Unlock()
+	if cli, ok := gb.playerClient[playerName]; ok {
+		delete(gb.lobbyClients[lobbyCode], cli)
+	}
+}
+
+func (gb *GameBroker) removeLobby(lobbyCode string) {
+	gb.mu.Lock()
+	defer gb.mu.Unlock()
+	delete(gb.lobbyClients, lobbyCode)
+	delete(gb.history, lobbyCode)
+}
+
+// Copy the group, so that sending does not happen while holding the lock, callers hold gb.mu
+func (gb *GameBroker) lobbyGroup(lobbyCode string) map[int]bool {
+	group := make(map[int]bool, len(gb.lobbyClients[lobbyCode]))
+	for cli := range gb.lobbyClients[lobbyCode] {
+		group[cli] = true
+	}
+	return group
+}
+
+func (gb *GameBroker) send(env Envelope) {
+	if err := gb.backplane.Publish(env); err != nil {
+		log.Printf("unable to publish %s event: %s", env.Target, err.Error())
+	}
+}
+
+// Events go through the backplane, so that clients connected to other instances receive them too
+func (gb *GameBroker) publish(target Target, key string, msg Message) {
+	sseMsg := msg.toSSE()
+	data, err := json.Marshal(sseMsg.Data)
+	if err != nil {
+		log.Printf("unable to marshal: %s", err.Error())
+		return
+	}
+	gb.send(Envelope{Target: target, Key: key, Event: sseMsg.Event, Data: data})
+}
+
+func (gb *GameBroker) PublishToLobby(lobbyCode string, msg Message) {
+	gb.publish(TargetLobby, lobbyCode, msg)
+}
+
+func (gb *GameBroker) PublishToPlayer(playername string, msg Message) {
+	gb.publish(TargetPlayer, playername, msg)
+}
+
+func (gb *GameBroker) Publish(msg Message) {
+	gb.publish(TargetAll, "", msg)
+}
+
+// deliver hands an envelope published by any instance to the clients connected to this one
+func (gb *GameBroker) deliver(env Envelope) {
+	gb.Broker.ObserveID(env.ID)
+	sseMsg := sse.Message{ID: env.ID, Event: env.Event, Data: env.Data}
+	switch env.Target {
+	case TargetLobby:
+		gb.deliverToLobby(env.Key, sseMsg)
+	case TargetPlayer:
+		gb.mu.RLock()
+		cli, ok := gb.playerClient[env.Key]
+		gb.mu.RUnlock()
+		if ok {
+			gb.Broker.PublishToClient(cli, sseMsg)
+		}
+	case TargetAll:
+		gb.Broker.Publish(sseMsg)
+	case TargetRemovePlayer:
+		gb.removePlayer(env.Key, env.Player)
+	case TargetRemoveLobby:
+		gb.removeLobby(env.Key)
+	default:
+		log.Printf("unknown event target %s", env.Target)
+	}
+}
+
+func (gb *GameBroker) deliverToLobby(lobbyCode string, msg sse.Message) {
+	size := gb.Broker.Config().HistorySize
+	// Record the event and copy the group under one lock, so that
+	// a reconnecting client gets the event either replayed or published, never both
+	gb.mu.Lock()
+	if size > 0 {
+		if gb.history[lobbyCode] == nil {
+			gb.history[lobbyCode] = &eventHistory{}
+		}
+		gb.history[lobbyCode].add(msg, size)
+	}
+	group := gb.lobbyGroup(lobbyCode)
+	gb.mu.Unlock()
+	gb.Broker.PublishToGroup(group, msg)
+}
+
+// Shutdown tells the clients of this instance that the server goes away and ends their streams.
+// It is not sent through the backplane, clients of other instances are not affected.
+func (gb *GameBroker) Shutdown() {
+	msg := Message{Data: c.SERVER_SHUTDOWN}
+	gb.Broker.Shutdown(msg.toSSE())
+}
+
+// SSEHandler godoc
+// @Summary Server-Sent Events
+// @Description Server-Sent Events, every event has an ID and is named after its type (e.g. GAME_STARTED, TIME_LEFT).
+// @Description Players that reconnect with the Last-Event-ID header (or lastEventId query parameter) get the missed events of their lobby replayed.
+// @Tags events
+// @Accept json
+// @Produce json
+// @Success 200 {object} Message
+// @Failure 400 {object} dto.APIError
+// @Failure 405 {object} dto.APIError
+// @Router /events [get]
+func (gs *GameService) SSEHandler(w http.ResponseWriter, r *http.Request) {
+	gs.broker.Broker.SSEHandler(w, r)
+}
+
+// HandleEventStats godoc
+// @Summary Event delivery statistics
+// @Description Number of connected clients, messages dropped for slow clients and clients disconnected for being too slow
+// @Tags events
+// @Produce json
+// @Success 200 {object} sse.Stats
+// @Failure 405 {object} dto.APIError
+// @Router /events/stats [get]
+func (gs *GameService) HandleEventStats(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		err := u.WriteJSON(w, http.StatusMethodNotAllowed, dto.APIError{Error: "Method not allowed", Code: c.METHOD_NOT_ALLOWED})
+		return err
+	}
+	return u.WriteJSON(w, http.StatusOK, gs.broker.Broker.Stats())
+}
+
+//$ curl -X POST -H "Content-Type: application/json" -d '{"data": "Hello World"}' http://localhost:<port>/broadcast
+
+// Broadcast godoc
+// @Summary Broadcast a message to all clients
+// @Description Broadcast a message to all clients
+// @Tags events
+// @Accept json
+// @Produce json
+// @Param message body Message true "Message to broadcast"
+// @Success 200 {string} string "Message sent"
+// @Failure 400 {string} string "Bad request"
+// @Failure 405 {string} string "Method not allowed"
+// @Router /broadcast [post]
+func (gs *GameService) Broadcast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var m Message
+	err := json.NewDecoder(r.Body).Decode(&m)
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	gs.broker.Publish(m)
+	w.Write([]byte("Msg sent\n"))
+}
@@ -0,0 +1,202 @@
+package game
+
+// Persist running games, so that they survive a restart of the server
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	ae "github.com/na50r/wombo-combo-go-be/apierror"
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	st "github.com/na50r/wombo-combo-go-be/storage"
+)
+
+func (g *Game) toState() *st.GameState {
+	winner, manualEnd := g.Result()
+	state := &st.GameState{
+		LobbyCode:   g.LobbyCode,
+		GameMode:    g.GameMode,
+		TargetWord:  g.TargetWord,
+		TargetWords: strings.Join(g.TargetWords, ","),
+		WithTimer:   g.WithTimer,
+		Winner:      winner,
+		ManualEnd:   manualEnd,
+	}
+	if g.WithTimer {
+		state.Duration = g.Timer.durationMinutes
+		state.Deadline = g.Timer.Deadline()
+	}
+	if g.WordUses != nil {
+		state.WordUses = g.WordUses.limit
+	}
+	return state
+}
+
+func gameFromState(state *st.GameState) *Game {
+	game := &Game{
+		GameMode:   state.GameMode,
+		LobbyCode:  state.LobbyCode,
+		TargetWord: state.TargetWord,
+		WithTimer:  state.WithTimer,
+		Winner:     state.Winner,
+		ManualEnd:  state.ManualEnd,
+	}
+	if state.TargetWords != "" {
+		game.TargetWords = strings.Split(state.TargetWords, ",")
+	}
+	if state.WithTimer {
+		game.Timer = ResumeTimer(state.Duration, state.Deadline)
+	}
+	return game
+}
+
+// saveGame persists the state of the game, for Finite Fusion together with the remaining uses of every word
+func (s *GameService) saveGame(ctx context.Context, game *Game) {
+	err := s.store.WithTx(ctx, func(tx st.Storage) error {
+		if err := tx.SaveGame(ctx, game.toState()); err != nil {
+			return err
+		}
+		if game.WordUses == nil {
+			return nil
+		}
+		if err := tx.DeleteWordUses(ctx, game.LobbyCode); err != nil {
+			return err
+		}
+		return tx.SaveWordUses(ctx, game.WordUses.allRows(game.LobbyCode))
+	})
+	if err != nil {
+		log.Printf("Error saving game %s: %v", game.LobbyCode, err)
+	}
+}
+
+// saveWordUses persists the remaining uses of the words a move changed
+func (s *GameService) saveWordUses(ctx context.Context, game *Game, playerName string, words ...string) {
+	if err := s.store.SaveWordUses(ctx, game.WordUses.rows(game.LobbyCode, playerName, words...)); err != nil {
+		log.Printf("Error saving word uses of game %s: %v", game.LobbyCode, err)
+	}
+}
+
+// Shutdown ends the event streams of this instance and saves the running games, their timers resume after the restart
+func (s *GameService) Shutdown(ctx context.Context) error {
+	s.broker.Shutdown()
+	for _, game := range s.allGames() {
+		game.SuspendTimer()
+		s.saveGame(ctx, game)
+	}
+	done := make(chan struct{})
+	go func() {
+		s.connections.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *GameService) deleteGame(ctx context.Context, lobbyCode string) {
+	if game := s.removeGame(lobbyCode); game != nil {
+		game.StopTimer()
+	}
+	err := s.store.WithTx(ctx, func(tx st.Storage) error {
+		if err := tx.DeleteGame(ctx, lobbyCode); err != nil {
+			return err
+		}
+		return tx.DeleteWordUses(ctx, lobbyCode)
+	})
+	if err != nil {
+		log.Printf("Error deleting game %s: %v", lobbyCode, err)
+	}
+}
+
+// Words of the players that are still in the lobby get their persisted remaining uses,
+// a word whose uses were not saved before the server stopped gets the full budget
+func restoreWordUses(ctx context.Context, store st.Storage, game *Game, limit int) error {
+	game.WordUses = NewWordUses(limit)
+	saved, err := store.GetWordUses(ctx, game.LobbyCode)
+	if err != nil {
+		return err
+	}
+	left := make(map[string]map[string]int)
+	for _, use := range saved {
+		if left[use.PlayerName] == nil {
+			left[use.PlayerName] = make(map[string]int)
+		}
+		left[use.PlayerName][use.Word] = use.UsesLeft
+	}
+	players, err := store.GetPlayersByLobbyCode(ctx, game.LobbyCode)
+	if err != nil {
+		return err
+	}
+	for _, player := range players {
+		words, err := store.GetPlayerWords(ctx, player.Name, game.LobbyCode)
+		if err != nil {
+			return err
+		}
+		for _, word := range words {
+			if uses, ok := left[player.Name][word]; ok {
+				game.WordUses.set(player.Name, word, uses)
+				continue
+			}
+			game.WordUses.Add(player.Name, word)
+		}
+	}
+	return nil
+}
+
+// RestoreGames rehydrates the games that were running when the server stopped,
+// timers continue with the remaining time and games whose deadline passed are ended.
+// A game that fails to restore is logged and skipped, it is kept in the store for the next start.
+func (s *GameService) RestoreGames(ctx context.Context) error {
+	states, err := s.store.GetGames(ctx)
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		if err := s.restoreGame(ctx, state); err != nil {
+			log.Printf("Error restoring game %s, skipping it: %v", state.LobbyCode, err)
+		}
+	}
+	log.Printf("Restored %d games", s.gameCount())
+	return nil
+}
+
+func (s *GameService) restoreGame(ctx context.Context, state *st.GameState) error {
+	_, err := s.store.GetLobbyByCode(ctx, state.LobbyCode)
+	if ae.Is(err, ae.NotFound) {
+		log.Printf("Lobby %s no longer exists, dropping its game", state.LobbyCode)
+		s.deleteGame(ctx, state.LobbyCode)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	game := gameFromState(state)
+	if game.GameMode == c.FINITE_FUSION {
+		if err := restoreWordUses(ctx, s.store, game, state.WordUses); err != nil {
+			return err
+		}
+	}
+	if !game.WithTimer || game.Winner != "" || game.ManualEnd {
+		s.setGame(game)
+		return nil
+	}
+	if err := validateDuration(game.Timer.durationMinutes); err != nil {
+		// Saved before durations were validated, the game never had a running timer
+		log.Printf("Game %s has an invalid timer, dropping it: %v", game.LobbyCode, err)
+		s.deleteGame(ctx, game.LobbyCode)
+		return nil
+	}
+	s.setGame(game)
+	if time.Now().After(game.Timer.Deadline()) {
+		log.Printf("Timer of game %s expired while the server was down", game.LobbyCode)
+		s.expire(ctx, game)
+		return nil
+	}
+	log.Printf("Resuming timer of game %s, %v left", game.LobbyCode, time.Until(game.Timer.Deadline()).Round(time.Second))
+	return game.StartTimer(s)
+}
@@ -0,0 +1,151 @@
+package game
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ae "github.com/na50r/wombo-combo-go-be/apierror"
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	st "github.com/na50r/wombo-combo-go-be/storage"
+)
+
+// addAccountPlayer adds a player with an account to the lobby, the owner creates it
+func addAccountPlayer(t *testing.T, s *GameService, lobbyCode, name string, owner bool) {
+	t.Helper()
+	ctx := context.Background()
+	acc, err := st.NewAccount(name, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.CreateAccount(ctx, acc); err != nil {
+		t.Fatal(err)
+	}
+	if owner {
+		if err := s.store.CreateLobby(ctx, st.NewLobby(name+"'s lobby", lobbyCode, "default.png")); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.store.CreatePlayer(ctx, st.NewPlayer(name, lobbyCode, "default.png", true, true, 0, 0)); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if err := s.store.AddPlayerToLobby(ctx, lobbyCode, st.NewPlayer(name, lobbyCode, "default.png", false, true, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func wantRecord(t *testing.T, s *GameService, username string, wins, losses int) {
+	t.Helper()
+	acc, err := s.store.GetAccountByUsername(context.Background(), username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Wins != wins || acc.Losses != losses {
+		t.Errorf("%s has %d wins and %d losses, want %d and %d", username, acc.Wins, acc.Losses, wins, losses)
+	}
+}
+
+func waitForWinner(t *testing.T, game *Game) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if winner, _ := game.Result(); winner != "" {
+			return winner
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("game %s did not end", game.LobbyCode)
+	return ""
+}
+
+func TestTimerSettlesAccounts(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	addAccountPlayer(t, s, "abc", "alice", true)
+	addAccountPlayer(t, s, "abc", "bob", false)
+	if err := s.store.IncrementPlayerPoints(ctx, "alice", "abc", 10); err != nil {
+		t.Fatal(err)
+	}
+	game := &Game{GameMode: c.VANILLA, LobbyCode: "abc", WithTimer: true, Timer: ResumeTimer(1, time.Now().Add(time.Second))}
+	s.setGame(game)
+	if err := game.StartTimer(s); err != nil {
+		t.Fatal(err)
+	}
+	if winner := waitForWinner(t, game); winner != "alice" {
+		t.Fatalf("winner %s, want alice", winner)
+	}
+	wantRecord(t, s, "alice", 1, 0)
+	wantRecord(t, s, "bob", 0, 1)
+}
+
+func TestRestoreGames(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	addAccountPlayer(t, s, "expired", "alice", true)
+	addAccountPlayer(t, s, "expired", "bob", false)
+	if err := s.store.IncrementPlayerPoints(ctx, "bob", "expired", 10); err != nil {
+		t.Fatal(err)
+	}
+	addAccountPlayer(t, s, "running", "carol", true)
+	// Nobody is left to win, the game has to go instead of waiting for a winner forever
+	addAccountPlayer(t, s, "noplayers", "dave", true)
+	if err := s.store.DeletePlayersForLobby(ctx, "noplayers"); err != nil {
+		t.Fatal(err)
+	}
+	addAccountPlayer(t, s, "invalid", "erin", true)
+	states := []*st.GameState{
+		{LobbyCode: "expired", GameMode: c.VANILLA, WithTimer: true, Duration: 2, Deadline: time.Now().Add(-time.Minute)},
+		{LobbyCode: "noplayers", GameMode: c.FINITE_FUSION, WithTimer: true, Duration: 2, Deadline: time.Now().Add(-time.Minute), WordUses: 3},
+		{LobbyCode: "invalid", GameMode: c.VANILLA, WithTimer: true, Duration: 0},
+		{LobbyCode: "running", GameMode: c.VANILLA, WithTimer: true, Duration: 2, Deadline: time.Now().Add(time.Minute)},
+		{LobbyCode: "gone", GameMode: c.VANILLA},
+	}
+	for _, state := range states {
+		if err := s.store.SaveGame(ctx, state); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.RestoreGames(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	expired := s.getGame("expired")
+	if expired == nil {
+		t.Fatal("expired game was not restored")
+	}
+	if winner, _ := expired.Result(); winner != "bob" {
+		t.Errorf("expired game has winner %q, want bob", winner)
+	}
+	wantRecord(t, s, "bob", 1, 0)
+	wantRecord(t, s, "alice", 0, 1)
+
+	running := s.getGame("running")
+	if running == nil {
+		t.Fatal("running game was not restored")
+	}
+	if winner, _ := running.Result(); winner != "" {
+		t.Errorf("running game was ended, winner %q", winner)
+	}
+	for _, lobbyCode := range []string{"noplayers", "invalid", "gone"} {
+		if s.getGame(lobbyCode) != nil {
+			t.Errorf("game %s was restored", lobbyCode)
+		}
+	}
+	saved, err := s.store.GetGames(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 2 {
+		t.Errorf("%d games are saved after the restore, want expired and running", len(saved))
+	}
+}
+
+func TestNewGameRejectsInvalidDuration(t *testing.T) {
+	s := newTestService(t)
+	for _, duration := range []int{0, 5} {
+		if _, err := NewGame(context.Background(), s.store, "abc", c.VANILLA, true, duration, 0); !ae.Is(err, ae.Validation) {
+			t.Errorf("duration %d: error %v, want a validation error", duration, err)
+		}
+	}
+}
@@ -1,81 +1,138 @@
-package game
-
-import (
-	"context"
-	"fmt"
-	"log"
-	"time"
-	c "github.com/na50r/wombo-combo-go-be/constants"
-	dto "github.com/na50r/wombo-combo-go-be/dto"
-)
-
-type Timer struct {
-	durationMinutes int
-	cancelFunc      context.CancelFunc
-}
-
-func NewTimer(durationMinutes int) *Timer {
-	return &Timer{durationMinutes: durationMinutes}
-}
-
-func (mt *Timer) Start(s *GameService, lobbyCode string, game *Game) error {
-	if mt.durationMinutes < 1 {
-		return fmt.Errorf("duration must be at least 1 minute")
-	}
-	if mt.durationMinutes >= 5 {
-		return fmt.Errorf("duration must be less than or equal to 5 minutes")
-	}
-	ctx, cancel := context.WithCancel(context.Background())
-	mt.cancelFunc = cancel
-	ticker := time.NewTicker(time.Second)
-	total := mt.durationMinutes * 60
-	half := total / 2
-	one_quarter := half / 2
-	three_quarter := half + one_quarter
-	now := time.Now()
-	triggers := map[int]bool{three_quarter: false, half: false, one_quarter: false}
-	publishTimeEvent := func(secondsLeft int) {
-		s.broker.PublishToLobby(lobbyCode, Message{Data: dto.TimeEvent{SecondsLeft: secondsLeft}})
-	}
-	go func() {
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				s.broker.PublishToLobby(lobbyCode, Message{Data: c.TIMER_STOPPED})
-				log.Printf("Timer %s stopped\n", lobbyCode)
-				return
-			case t := <-ticker.C:
-				elapsed := int(t.Sub(now).Seconds())
-				secondsLeft := total - elapsed
-				log.Printf("Timer %s: %ds left\n", lobbyCode, secondsLeft)
-				switch {
-				case secondsLeft <= three_quarter && triggers[three_quarter] == false:
-					triggers[three_quarter] = true
-					publishTimeEvent(secondsLeft)
-				case secondsLeft <= half && triggers[half] == false:
-					triggers[half] = true
-					publishTimeEvent(secondsLeft)
-				case secondsLeft <= one_quarter && triggers[one_quarter] == false:
-					triggers[one_quarter] = true
-					publishTimeEvent(secondsLeft)
-				case secondsLeft <= 10 && secondsLeft > 0:
-					publishTimeEvent(secondsLeft)
-				case secondsLeft <= 0:
-					var err error
-					game.Winner, err = s.store.SelectWinnerByPoints(lobbyCode)
-					if err != nil {
-						log.Printf("Error selecting winner: %v", err)
-					}
-					s.broker.PublishToLobby(lobbyCode, Message{Data: c.GAME_OVER})
-					return
-				}
-			}
-		}
-	}()
-	return nil
-}
-
-func (mt *Timer) Stop() {
-	mt.cancelFunc()
-}
+package game
+
+import (
+	"context"
+	ae "github.com/na50r/wombo-combo-go-be/apierror"
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	dto "github.com/na50r/wombo-combo-go-be/dto"
+	"log"
+	"sync"
+	"time"
+)
+
+type Timer struct {
+	mu              sync.Mutex // guards deadline, cancelFunc and suspended
+	durationMinutes int
+	deadline        time.Time
+	cancelFunc      context.CancelFunc
+	suspended       bool
+}
+
+func NewTimer(durationMinutes int) *Timer {
+	return &Timer{durationMinutes: durationMinutes}
+}
+
+// ResumeTimer recreates a timer that was already running before a restart
+func ResumeTimer(durationMinutes int, deadline time.Time) *Timer {
+	return &Timer{durationMinutes: durationMinutes, deadline: deadline}
+}
+
+func (mt *Timer) Deadline() time.Time {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	return mt.deadline
+}
+
+func validateDuration(minutes int) error {
+	if minutes < 1 {
+		return ae.Validationf("duration must be at least 1 minute")
+	}
+	if minutes >= 5 {
+		return ae.Validationf("duration must be less than 5 minutes")
+	}
+	return nil
+}
+
+func (mt *Timer) Start(s *GameService, lobbyCode string, game *Game) error {
+	if err := validateDuration(mt.durationMinutes); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	mt.mu.Lock()
+	mt.cancelFunc = cancel
+	ticker := time.NewTicker(time.Second)
+	total := mt.durationMinutes * 60
+	half := total / 2
+	one_quarter := half / 2
+	three_quarter := half + one_quarter
+	if mt.deadline.IsZero() {
+		mt.deadline = time.Now().Add(time.Duration(total) * time.Second)
+	}
+	deadline := mt.deadline
+	mt.mu.Unlock()
+	// A resumed timer must not repeat events that were already sent
+	initialLeft := int(time.Until(deadline).Seconds())
+	triggers := map[int]bool{three_quarter: initialLeft <= three_quarter, half: initialLeft <= half, one_quarter: initialLeft <= one_quarter}
+	publishTimeEvent := func(secondsLeft int) {
+		s.broker.PublishToLobby(lobbyCode, Message{Data: dto.TimeEvent{SecondsLeft: secondsLeft}})
+	}
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				if mt.isSuspended() {
+					log.Printf("Timer %s suspended\n", lobbyCode)
+					return
+				}
+				s.broker.PublishToLobby(lobbyCode, Message{Data: c.TIMER_STOPPED})
+				log.Printf("Timer %s stopped\n", lobbyCode)
+				return
+			case t := <-ticker.C:
+				secondsLeft := int(deadline.Sub(t).Seconds())
+				log.Printf("Timer %s: %ds left\n", lobbyCode, secondsLeft)
+				switch {
+				case secondsLeft <= three_quarter && triggers[three_quarter] == false:
+					triggers[three_quarter] = true
+					publishTimeEvent(secondsLeft)
+				case secondsLeft <= half && triggers[half] == false:
+					triggers[half] = true
+					publishTimeEvent(secondsLeft)
+				case secondsLeft <= one_quarter && triggers[one_quarter] == false:
+					triggers[one_quarter] = true
+					publishTimeEvent(secondsLeft)
+				case secondsLeft <= 10 && secondsLeft > 0:
+					publishTimeEvent(secondsLeft)
+				case secondsLeft <= 0:
+					// Ending the game stops the timer, the storage calls must not use its context
+					s.expire(context.Background(), game)
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// expire ends a game whose time ran out, a game whose winner can not be selected is removed instead of running forever
+func (s *GameService) expire(ctx context.Context, game *Game) {
+	if _, err := s.endGame(ctx, game, "", false); err != nil {
+		log.Printf("Error ending game %s, removing it: %v", game.LobbyCode, err)
+		s.deleteGame(ctx, game.LobbyCode)
+		s.broker.PublishToLobby(game.LobbyCode, Message{Data: c.GAME_DELETED})
+	}
+}
+
+func (mt *Timer) Stop() {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	if mt.cancelFunc != nil {
+		mt.cancelFunc()
+	}
+}
+
+// Suspend stops the timer without telling the lobby, the deadline is kept so that the game resumes after a restart
+func (mt *Timer) Suspend() {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	mt.suspended = true
+	if mt.cancelFunc != nil {
+		mt.cancelFunc()
+	}
+}
+
+func (mt *Timer) isSuspended() bool {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	return mt.suspended
+}
@@ -0,0 +1,191 @@
+package game
+
+// WebSocket transport: moves and game events over a single connection
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	ae "github.com/na50r/wombo-combo-go-be/apierror"
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	dto "github.com/na50r/wombo-combo-go-be/dto"
+	"github.com/na50r/wombo-combo-go-be/sse"
+	t "github.com/na50r/wombo-combo-go-be/token"
+	u "github.com/na50r/wombo-combo-go-be/utility"
+)
+
+const wsWriteTimeout = 10 * time.Second
+
+// checkOrigin only lets the configured client open a WebSocket, like the CORS policy of the REST API.
+// Requests without an Origin header do not come from a browser and are authenticated by their token alone.
+func (gs *GameService) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || gs.client == "*" || strings.EqualFold(origin, gs.client)
+}
+
+type wsConn struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex // replies and events are written from different goroutines
+}
+
+func (wc *wsConn) writeJSON(v interface{}) error {
+	wc.writeMu.Lock()
+	defer wc.writeMu.Unlock()
+	wc.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return wc.conn.WriteJSON(v)
+}
+
+func (wc *wsConn) ping() error {
+	wc.writeMu.Lock()
+	defer wc.writeMu.Unlock()
+	return wc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+}
+
+func (wc *wsConn) close(code int) error {
+	wc.writeMu.Lock()
+	defer wc.writeMu.Unlock()
+	return wc.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), time.Now().Add(wsWriteTimeout))
+}
+
+// WSHandler godoc
+// @Summary WebSocket for game traffic
+// @Description Authenticates once with the player token (Authorization header or token query parameter) and upgrades to a WebSocket.
+// @Description The client sends commands of type combination, leave or edit-mode (dto.WSCommand) and receives replies (dto.WSReply) and the same game events as on /events (dto.WSEvent).
+// @Tags events
+// @Param token query string false "Player token"
+// @Success 101 {object} dto.WSEvent
+// @Failure 401 {object} dto.APIError
+// @Router /ws [get]
+func (gs *GameService) WSHandler(w http.ResponseWriter, r *http.Request) {
+	token, tokenExists := t.GetStreamToken(r)
+	if !tokenExists {
+		u.WriteJSON(w, http.StatusUnauthorized, dto.APIError{Error: c.Unauthorized, Code: c.UNAUTHORIZED})
+		return
+	}
+	claims, err := t.VerifyPlayerJWT(token)
+	if err != nil {
+		log.Printf("JWT verification failed: %v", err)
+		u.WriteJSON(w, http.StatusUnauthorized, dto.APIError{Error: c.Unauthorized, Code: c.UNAUTHORIZED})
+		return
+	}
+	upgrader := websocket.Upgrader{CheckOrigin: gs.checkOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("unable to upgrade: %s", err.Error())
+		return
+	}
+	gs.connections.Add(1)
+	defer gs.connections.Done()
+	wc := &wsConn{conn: conn}
+	client := gs.broker.Broker.Subscribe(r)
+	defer client.Close()
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go gs.pushEvents(ctx, wc, client)
+
+	heartbeat := gs.broker.Broker.Config().HeartbeatInterval
+	if heartbeat > 0 {
+		conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+		})
+	}
+	for {
+		cmd := new(dto.WSCommand)
+		if err := conn.ReadJSON(cmd); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("websocket of player %s closed: %s", claims.PlayerName, err.Error())
+			}
+			return
+		}
+		reply, done := gs.handleCommand(ctx, claims, cmd)
+		if err := wc.writeJSON(reply); err != nil {
+			log.Printf("unable to write: %s", err.Error())
+			return
+		}
+		if done {
+			wc.close(websocket.CloseNormalClosure)
+			return
+		}
+	}
+}
+
+// pushEvents forwards the client's game events and keeps the connection alive with pings
+func (gs *GameService) pushEvents(ctx context.Context, wc *wsConn, client *sse.Client) {
+	var heartbeat <-chan time.Time
+	if interval := gs.broker.Broker.Config().HeartbeatInterval; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-client.Evicted:
+			log.Printf("client too slow, closing websocket (ch=%d)", client.Sub.GetChannelID())
+			wc.conn.Close()
+			return
+		case <-client.Closing:
+			for _, frame := range client.Pending() {
+				if err := wc.writeJSON(dto.WSEvent{Type: "event", ID: frame.ID, Event: frame.Event, Data: frame.Data}); err != nil {
+					break
+				}
+			}
+			wc.close(websocket.CloseGoingAway)
+			wc.conn.Close()
+			return
+		case <-heartbeat:
+			err = wc.ping()
+		case frame := <-client.Frames:
+			err = wc.writeJSON(dto.WSEvent{Type: "event", ID: frame.ID, Event: frame.Event, Data: frame.Data})
+		}
+		if err != nil {
+			// Closing makes the read loop return, which removes the client
+			log.Printf("unable to write, closing websocket (ch=%d): %s", client.Sub.GetChannelID(), err.Error())
+			wc.conn.Close()
+			return
+		}
+	}
+}
+
+// handleCommand runs a command as the authenticated player, done is set if the connection should be closed
+func (gs *GameService) handleCommand(ctx context.Context, claims *t.PlayerClaims, cmd *dto.WSCommand) (reply dto.WSReply, done bool) {
+	reply = dto.WSReply{Type: "reply", RequestID: cmd.RequestID}
+	var result interface{}
+	var err error
+	switch cmd.Type {
+	case c.COMBINATION_COMMAND:
+		result, err = gs.Combine(ctx, claims.LobbyCode, claims.PlayerName, &cmd.WordRequest)
+	case c.LEAVE_COMMAND:
+		result, err = gs.LeaveLobby(ctx, claims.LobbyCode, claims.PlayerName)
+		done = err == nil
+	case c.EDIT_MODE_COMMAND:
+		if !claims.IsOwner {
+			err = ae.Forbiddenf(c.Unauthorized)
+			break
+		}
+		result = gs.EditGameMode(ctx, claims.LobbyCode, &cmd.EditGameRequest)
+	default:
+		err = ae.Validationf("Unknown command %s", cmd.Type)
+	}
+	if err != nil {
+		apiErr := ae.From(err)
+		if apiErr.Kind == ae.Internal || apiErr.Kind == ae.Upstream || apiErr.Kind == ae.Timeout {
+			log.Printf("WebSocket command %s: %v", cmd.Type, err)
+		}
+		resp := apiErr.APIError()
+		reply.Error = &resp
+	} else {
+		reply.Result = result
+	}
+	return reply, done
+}
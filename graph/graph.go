@@ -0,0 +1,164 @@
+package graph
+
+// Recipe graph of the combination table, every combination is an edge from both ingredients to the result
+
+import (
+	"container/heap"
+	"strings"
+
+	st "github.com/na50r/wombo-combo-go-be/storage"
+	u "github.com/na50r/wombo-combo-go-be/utility"
+)
+
+// Recipe combines A and B into Result
+type Recipe struct {
+	A      string
+	B      string
+	Result string
+}
+
+type Graph struct {
+	recipes []Recipe
+	uses    map[string][]int   // Recipes a word is an ingredient of
+	pairs   map[[2]string]bool // Sorted ingredients of the known recipes
+}
+
+func New(combinations []*st.Combination) *Graph {
+	g := &Graph{uses: make(map[string][]int), pairs: make(map[[2]string]bool)}
+	for _, combi := range combinations {
+		g.Add(Recipe{A: combi.A, B: combi.B, Result: combi.Result})
+	}
+	return g
+}
+
+// Add inserts a recipe like the combination table does: a pair that is already known keeps its first result.
+// A Graph is not safe for concurrent use, callers that add recipes while others search have to lock.
+func (g *Graph) Add(recipe Recipe) {
+	recipe = Recipe{
+		A:      strings.ToLower(recipe.A),
+		B:      strings.ToLower(recipe.B),
+		Result: strings.ToLower(recipe.Result),
+	}
+	a, b := u.SortAB(recipe.A, recipe.B)
+	if g.pairs[[2]string{a, b}] {
+		return
+	}
+	g.pairs[[2]string{a, b}] = true
+	// Gives back one of its ingredients, never part of a shortest chain
+	if recipe.Result == recipe.A || recipe.Result == recipe.B {
+		return
+	}
+	i := len(g.recipes)
+	g.recipes = append(g.recipes, recipe)
+	g.uses[recipe.A] = append(g.uses[recipe.A], i)
+	if recipe.B != recipe.A {
+		g.uses[recipe.B] = append(g.uses[recipe.B], i)
+	}
+}
+
+// Path returns the recipes that lead from the words in have to target, in an order they can be played in.
+// The cost of a word is the size of its recipe tree. A word that is needed twice is counted twice,
+// so the chain is the shortest one in most cases but not guaranteed to be minimal.
+// Returns false if target can not be reached, an empty path if it is already in have.
+func (g *Graph) Path(have []string, target string) ([]Recipe, bool) {
+	target = strings.ToLower(target)
+	cost, made := g.search(have, target, func(a, b int) int { return a + b + 1 })
+	if _, ok := cost[target]; !ok {
+		return nil, false
+	}
+	return g.chain(target, made), true
+}
+
+// Depths returns the fewest rounds of combinations each word takes to make from base,
+// a word made from words of depth 2 and 3 has depth 4. Words that can not be made from base are missing.
+func (g *Graph) Depths(base []string) map[string]int {
+	depths, _ := g.search(base, "", func(a, b int) int { return max(a, b) + 1 })
+	return depths
+}
+
+// search settles words cheapest first (Knuth's generalization of Dijkstra), starting with have at cost 0.
+// combine gives the cost of a recipe from the costs of its ingredients and must not be lower than either.
+// Stops once stop is settled, the costs of words that were not settled yet are dropped.
+func (g *Graph) search(have []string, stop string, combine func(a, b int) int) (map[string]int, map[string]int) {
+	cost := make(map[string]int)
+	made := make(map[string]int) // Recipe that made a word
+	done := make(map[string]bool)
+	queue := &wordQueue{}
+	for _, word := range have {
+		word = strings.ToLower(word)
+		cost[word] = 0
+		heap.Push(queue, queued{word: word, cost: 0})
+	}
+	for queue.Len() > 0 {
+		next := heap.Pop(queue).(queued)
+		if done[next.word] {
+			continue
+		}
+		done[next.word] = true
+		if next.word == stop {
+			break
+		}
+		for _, i := range g.uses[next.word] {
+			recipe := g.recipes[i]
+			if !done[recipe.A] || !done[recipe.B] || done[recipe.Result] {
+				continue
+			}
+			// An ingredient that is used twice is only made once
+			resultCost := combine(cost[recipe.A], 0)
+			if recipe.A != recipe.B {
+				resultCost = combine(cost[recipe.A], cost[recipe.B])
+			}
+			if old, ok := cost[recipe.Result]; ok && old <= resultCost {
+				continue
+			}
+			cost[recipe.Result] = resultCost
+			made[recipe.Result] = i
+			heap.Push(queue, queued{word: recipe.Result, cost: resultCost})
+		}
+	}
+	for word := range cost {
+		if !done[word] {
+			delete(cost, word)
+		}
+	}
+	return cost, made
+}
+
+// Ingredients come before the recipes that use them, shared intermediate words are made once
+func (g *Graph) chain(target string, made map[string]int) []Recipe {
+	path := []Recipe{}
+	seen := make(map[string]bool)
+	var visit func(word string)
+	visit = func(word string) {
+		i, ok := made[word]
+		if !ok || seen[word] {
+			return
+		}
+		seen[word] = true
+		recipe := g.recipes[i]
+		visit(recipe.A)
+		visit(recipe.B)
+		path = append(path, recipe)
+	}
+	visit(target)
+	return path
+}
+
+type queued struct {
+	word string
+	cost int
+}
+
+// Min-heap of words by cost
+type wordQueue []queued
+
+func (q wordQueue) Len() int           { return len(q) }
+func (q wordQueue) Less(i, j int) bool { return q[i].cost < q[j].cost }
+func (q wordQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *wordQueue) Push(x any)        { *q = append(*q, x.(queued)) }
+func (q *wordQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,97 @@
+package graph
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	st "github.com/na50r/wombo-combo-go-be/storage"
+)
+
+func TestPath(t *testing.T) {
+	g := New([]*st.Combination{
+		{A: "fire", B: "water", Result: "steam"},
+		{A: "earth", B: "water", Result: "mud"},
+		{A: "mud", B: "steam", Result: "geyser"},
+	})
+	path, ok := g.Path([]string{"fire", "water", "earth"}, "Geyser")
+	if !ok {
+		t.Fatal("no path to geyser")
+	}
+	if len(path) != 3 || path[2].Result != "geyser" {
+		t.Fatalf("path %v, want steam and mud before geyser", path)
+	}
+	if path, ok := g.Path([]string{"geyser"}, "geyser"); !ok || len(path) != 0 {
+		t.Fatalf("path to an owned word: %v %v, want an empty path", path, ok)
+	}
+	if _, ok := g.Path([]string{"fire"}, "geyser"); ok {
+		t.Fatal("found a path without water")
+	}
+}
+
+func TestAddKeepsFirstResult(t *testing.T) {
+	g := New([]*st.Combination{{A: "fire", B: "water", Result: "steam"}})
+	g.Add(Recipe{A: "Water", B: "fire", Result: "mist"})
+	if _, ok := g.Path([]string{"fire", "water"}, "mist"); ok {
+		t.Fatal("a known pair was replaced")
+	}
+	g.Add(Recipe{A: "steam", B: "earth", Result: "geyser"})
+	if path, ok := g.Path([]string{"fire", "water", "earth"}, "geyser"); !ok || len(path) != 2 {
+		t.Fatalf("path %v %v after Add, want 2 steps", path, ok)
+	}
+}
+
+// The recipe depths in the order extra/generate_data.py weighs them for importer/testdata/items.json,
+// with the reachability it wrote to importer/testdata/words_python.csv
+func TestReachabilityMatchesScript(t *testing.T) {
+	tests := []struct {
+		word         string
+		recipeDepths []int
+		want         float64
+	}{
+		{"fire", nil, 0},
+		{"steam", []int{1}, 0.375},
+		{"cloud", []int{2, 1, 2, 1}, 0.4091796875},
+		{"geyser", []int{2, 2}, 0.203125},
+		{"rain", []int{2}, 0.1875},
+		{"flood", []int{2, 3, 2, 3}, 0.1748046875},
+	}
+	for _, tt := range tests {
+		if got := Reachability(tt.recipeDepths); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s: Reachability(%v) = %v, want %v", tt.word, tt.recipeDepths, got, tt.want)
+		}
+	}
+}
+
+func TestRecomputeWeighsInIngredientOrder(t *testing.T) {
+	ctx := context.Background()
+	store := st.NewMemoryStore()
+	for _, combi := range []*st.Combination{
+		{A: "water", B: "wind", Result: "cloud"},
+		{A: "fire", B: "water", Result: "steam"},
+		{A: "steam", B: "wind", Result: "cloud"},
+	} {
+		if err := store.AddCombination(ctx, combi); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Recompute(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+	words, err := store.GetWords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// steam + wind (depth 2) comes before water + wind (depth 1), whatever order the store returns
+	want := Reachability([]int{2, 1})
+	for _, word := range words {
+		if word.Word != "cloud" {
+			continue
+		}
+		if word.Reachability != want {
+			t.Fatalf("cloud has reachability %v, want %v", word.Reachability, want)
+		}
+		return
+	}
+	t.Fatal("cloud was not written")
+}
@@ -0,0 +1,115 @@
+package graph
+
+// Recomputes depth and reachability of every word from the combinations in the database.
+// AddNewCombination only approximates both when a combiner finds a new word, this job corrects them.
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	st "github.com/na50r/wombo-combo-go-be/storage"
+)
+
+// Words every player starts with, they have depth 0
+var BaseWords = []string{"fire", "water", "earth", "wind"}
+
+type RecomputeResult struct {
+	Updated     int // Depth or reachability changed, or the word was missing from the word table
+	Unchanged   int
+	Unreachable int // Can not be made from the base words, kept as they are
+}
+
+// Reachability weighs the recipes of a word in the given order like extra/generate_data.py: a recipe of depth d
+// is worth 1/2^d and counts 0.75 if it is shallower than every recipe before it and 0.25 otherwise.
+func Reachability(recipeDepths []int) float64 {
+	reachability := 0.0
+	oldDepth := math.MaxInt
+	for _, depth := range recipeDepths {
+		newWeight, oldWeight := 0.75, 0.25
+		if depth < oldDepth {
+			oldDepth = depth
+		} else {
+			newWeight, oldWeight = 0.25, 0.75
+		}
+		reachability = math.Ldexp(1, -depth)*newWeight + reachability*oldWeight
+	}
+	return reachability
+}
+
+// Recompute calculates depth (by BFS from BaseWords) and reachability of every word and writes the changes back.
+// The table does not keep the order in which combinations were found, the recipes of a word are weighed
+// in the order of their ingredients, so that every backend and every run gives the same result.
+func Recompute(ctx context.Context, store st.Storage) (*RecomputeResult, error) {
+	combinations, err := store.GetCombinations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(combinations, func(x, y *st.Combination) int {
+		return cmp.Or(strings.Compare(x.A, y.A), strings.Compare(x.B, y.B))
+	})
+	words, err := store.GetWords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	depths := New(combinations).Depths(BaseWords)
+
+	recipeDepths := make(map[string][]int)
+	for _, combi := range combinations {
+		aDepth, aOk := depths[strings.ToLower(combi.A)]
+		bDepth, bOk := depths[strings.ToLower(combi.B)]
+		if !aOk || !bOk {
+			continue
+		}
+		result := strings.ToLower(combi.Result)
+		recipeDepths[result] = append(recipeDepths[result], max(aDepth, bDepth)+1)
+	}
+
+	result := &RecomputeResult{}
+	known := make(map[string]*st.Word, len(words))
+	for _, word := range words {
+		known[word.Word] = word
+	}
+	updates := []*st.Word{}
+	for word, depth := range depths {
+		updated := &st.Word{Word: word, Depth: depth, Reachability: Reachability(recipeDepths[word])}
+		if old, ok := known[word]; ok && old.Depth == updated.Depth && math.Abs(old.Reachability-updated.Reachability) < 1e-12 {
+			result.Unchanged++
+			continue
+		}
+		updates = append(updates, updated)
+	}
+	for _, word := range words {
+		if _, ok := depths[word.Word]; !ok {
+			result.Unreachable++
+		}
+	}
+	if err := store.UpdateWords(ctx, updates); err != nil {
+		return nil, err
+	}
+	result.Updated = len(updates)
+	return result, nil
+}
+
+// RecomputeEvery runs Recompute every interval until ctx is done, errors are logged
+func RecomputeEvery(ctx context.Context, store st.Storage, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result, err := Recompute(ctx, store)
+			if err != nil {
+				log.Printf("Error recomputing words: %v", err)
+				continue
+			}
+			log.Printf("Recomputed words: %d updated, %d unchanged, %d unreachable", result.Updated, result.Unchanged, result.Unreachable)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package importer
+
+import (
+	"context"
+
+	st "github.com/na50r/wombo-combo-go-be/storage"
+)
+
+// batch collects rows and writes every size rows in one transaction
+type batch struct {
+	store        st.Storage
+	size         int
+	combinations []*st.Combination
+	words        []*st.Word
+}
+
+func newBatch(store st.Storage, size int) *batch {
+	return &batch{store: store, size: size}
+}
+
+func (b *batch) addCombination(ctx context.Context, combi *st.Combination) error {
+	b.combinations = append(b.combinations, combi)
+	return b.flushIfFull(ctx)
+}
+
+func (b *batch) addWord(ctx context.Context, word *st.Word) error {
+	b.words = append(b.words, word)
+	return b.flushIfFull(ctx)
+}
+
+func (b *batch) flushIfFull(ctx context.Context) error {
+	if len(b.combinations)+len(b.words) < b.size {
+		return nil
+	}
+	return b.flush(ctx)
+}
+
+// Words are upserted, the dataset is the source of their depth and reachability. Combinations are
+// insert or ignore, combinations found by the combiner before the import are kept.
+func (b *batch) flush(ctx context.Context) error {
+	if len(b.combinations) == 0 && len(b.words) == 0 {
+		return nil
+	}
+	err := b.store.WithTx(ctx, func(tx st.Storage) error {
+		for _, combi := range b.combinations {
+			if err := tx.AddCombination(ctx, combi); err != nil {
+				return err
+			}
+		}
+		return tx.UpdateWords(ctx, b.words)
+	})
+	if err != nil {
+		return err
+	}
+	b.combinations = b.combinations[:0]
+	b.words = b.words[:0]
+	return nil
+}
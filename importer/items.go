@@ -0,0 +1,184 @@
+package importer
+
+// Imports the Infinite Craft dataset (items.json of napstaa967/infinite-craft-database) without a Python step.
+// The file maps every item to its depth and the recipes that make it:
+// {"Steam": {"depth": 1, "recipes": [{"item_1": "Fire", "item_2": "Water"}]}, ...}
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/na50r/wombo-combo-go-be/graph"
+	st "github.com/na50r/wombo-combo-go-be/storage"
+	u "github.com/na50r/wombo-combo-go-be/utility"
+)
+
+type item struct {
+	Depth   *int     `json:"depth"`
+	Recipes []recipe `json:"recipes"`
+}
+
+type recipe struct {
+	Item1 string `json:"item_1"`
+	Item2 string `json:"item_2"`
+}
+
+// Two recipes for the same pair of ingredients, the first one is kept like AddCombination does
+type Conflict struct {
+	A       string
+	B       string
+	Kept    string
+	Dropped string
+}
+
+type ItemsReport struct {
+	Words          int
+	Combinations   int
+	SkippedItems   int // Names with a comma, "undefined", without a depth or that only differ in case from an earlier item
+	SkippedRecipes int // An ingredient is not a word of the dataset
+	Duplicates     int // Recipes listed more than once for the same item
+	Conflicts      []Conflict
+}
+
+// ImportItems reads items.json in two passes: the first collects the depth of every item, the second writes
+// words and combinations in transactions of batchSize rows. Words keep the depth of the dataset, a recipe is
+// one level deeper than its deepest ingredient. Reachability is the one of extra/generate_data.py (see
+// testdata/words_python.csv for its output): the usable recipes of an item are weighed in file order, duplicates
+// and conflicting recipes included, and the script weighs that list once per recipe of the item.
+// Only a recipe whose pair already makes an earlier item is not stored, the first result is kept like AddCombination does.
+// Names are lower case, unlike the script an item that only differs in case from an earlier one and items without
+// a depth are skipped.
+func ImportItems(ctx context.Context, store st.Storage, path string, batchSize int) (*ItemsReport, error) {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	report := &ItemsReport{}
+	depths := make(map[string]int) // By the name in the file
+	words := make(map[string]bool) // Lower case, to find names that only differ in case
+	err := eachItem(path, func(name string, it *item) error {
+		word := strings.ToLower(name)
+		if strings.Contains(name, ",") || name == "undefined" || it.Depth == nil || words[word] {
+			report.SkippedItems++
+			return nil
+		}
+		depths[name] = *it.Depth
+		words[word] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	batch := newBatch(store, batchSize)
+	results := make(map[[2]string]string) // Sorted pair of ingredients to result
+	err = eachItem(path, func(name string, it *item) error {
+		depth, ok := depths[name]
+		if !ok {
+			return nil
+		}
+		result := strings.ToLower(name)
+		recipeDepths := []int{}
+		for _, r := range it.Recipes {
+			aDepth, aOk := depths[r.Item1]
+			bDepth, bOk := depths[r.Item2]
+			if !aOk || !bOk {
+				report.SkippedRecipes++
+				continue
+			}
+			combiDepth := max(aDepth, bDepth) + 1
+			recipeDepths = append(recipeDepths, combiDepth)
+			a, b := u.SortAB(strings.ToLower(r.Item1), strings.ToLower(r.Item2))
+			pair := [2]string{a, b}
+			if kept, ok := results[pair]; ok {
+				if kept == result {
+					report.Duplicates++
+				} else {
+					report.Conflicts = append(report.Conflicts, Conflict{A: a, B: b, Kept: kept, Dropped: result})
+				}
+				continue
+			}
+			results[pair] = result
+			if err := batch.addCombination(ctx, &st.Combination{A: a, B: b, Result: result, Depth: combiDepth}); err != nil {
+				return err
+			}
+			report.Combinations++
+		}
+		weighed := make([]int, 0, len(recipeDepths)*len(it.Recipes))
+		for range it.Recipes {
+			weighed = append(weighed, recipeDepths...)
+		}
+		report.Words++
+		return batch.addWord(ctx, &st.Word{Word: result, Depth: depth, Reachability: graph.Reachability(weighed)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := batch.flush(ctx); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Log prints the summary and the first conflicts
+func (r *ItemsReport) Log(maxConflicts int) {
+	log.Printf("Imported %d words and %d combinations", r.Words, r.Combinations)
+	log.Printf("Skipped %d items and %d recipes, ignored %d duplicate recipes", r.SkippedItems, r.SkippedRecipes, r.Duplicates)
+	log.Printf("%d conflicting recipes, the first result was kept", len(r.Conflicts))
+	for i, conflict := range r.Conflicts {
+		if i == maxConflicts {
+			log.Printf("... and %d more", len(r.Conflicts)-maxConflicts)
+			break
+		}
+		log.Printf("  %s + %s = %s, dropped %s", conflict.A, conflict.B, conflict.Kept, conflict.Dropped)
+	}
+}
+
+// eachItem decodes one item at a time, the file is never held in memory as a whole
+func eachItem(path string, fn func(name string, it *item) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	dec := json.NewDecoder(file)
+	if err := expectDelim(dec, '{'); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		name, ok := token.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected an item name, got %v", path, token)
+		}
+		it := new(item)
+		if err := dec.Decode(it); err != nil {
+			return fmt.Errorf("%s: item %s: %w", path, name, err)
+		}
+		if err := fn(name, it); err != nil {
+			return err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil && err != io.EOF {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v, got %v", delim, token)
+	}
+	return nil
+}
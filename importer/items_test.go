@@ -0,0 +1,84 @@
+package importer
+
+import (
+	"context"
+	"encoding/csv"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	st "github.com/na50r/wombo-combo-go-be/storage"
+)
+
+// readPythonWords reads the Words.csv of extra/generate_data.py, keyed by lower case name
+func readPythonWords(t *testing.T, path string) map[string]*st.Word {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := make(map[string]*st.Word)
+	for _, row := range rows[1:] {
+		depth, err := strconv.Atoi(row[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		reachability, err := strconv.ParseFloat(row[2], 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		word := strings.ToLower(row[0])
+		words[word] = &st.Word{Word: word, Depth: depth, Reachability: reachability}
+	}
+	return words
+}
+
+func TestImportItemsAgainstPython(t *testing.T) {
+	ctx := context.Background()
+	store := st.NewMemoryStore()
+	report, err := ImportItems(ctx, store, "testdata/items.json", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantReport := &ItemsReport{
+		Words:          11,
+		Combinations:   8,
+		SkippedItems:   2, // "Lava, Hot" and "undefined"
+		SkippedRecipes: 1, // Fire + "Lava, Hot"
+		Conflicts:      []Conflict{{A: "cloud", B: "water", Kept: "rain", Dropped: "flood"}},
+	}
+	if !reflect.DeepEqual(report, wantReport) {
+		t.Fatalf("report %+v, want %+v", report, wantReport)
+	}
+
+	words, err := store.GetWords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	python := readPythonWords(t, "testdata/words_python.csv")
+	if len(words) != len(python) {
+		t.Fatalf("imported %d words, the script wrote %d", len(words), len(python))
+	}
+	for _, word := range words {
+		want, ok := python[word.Word]
+		if !ok {
+			t.Errorf("%s is not in the output of the script", word.Word)
+			continue
+		}
+		if word.Depth != want.Depth {
+			t.Errorf("%s: depth %d, the script wrote %d", word.Word, word.Depth, want.Depth)
+		}
+		if math.Abs(word.Reachability-want.Reachability) > 1e-12 {
+			t.Errorf("%s: reachability %v, the script wrote %v", word.Word, word.Reachability, want.Reachability)
+		}
+	}
+
+}
@@ -1,125 +1,210 @@
-package main
-
-// @title WomboCombo Go API
-// @version 1.0
-// @description This is the API for Wombo Combo Go
-// @host localhost:3030
-// @BasePath /
-// @securityDefinitions.apikey BearerAuth
-// @in header
-// @name Authorization
-
-import (
-	"flag"
-	"fmt"
-	"log"
-	"os"
-	"syscall"
-
-	"github.com/joho/godotenv"
-	_ "github.com/na50r/wombo-combo-go-be/docs"
-	st "github.com/na50r/wombo-combo-go-be/storage"
-	"os/signal"
-)
-
-var CLIENT string
-var ICONS string
-var COMBINATIONS string
-var WORDS string
-var COHERE_API_KEY string
-var POSTGRES_CONNECTION string
-var DB string
-var ACHIEVEMENTS string
-var ACHIEVEMENT_ICONS string
-
-func init() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Println("No .env file found, continuing... (main.go)")
-	}
-	CLIENT = os.Getenv("CLIENT")
-	ICONS = os.Getenv("ICONS")
-	COMBINATIONS = os.Getenv("COMBINATIONS")
-	WORDS = os.Getenv("WORDS")
-	COHERE_API_KEY = os.Getenv("COHERE_API_KEY")
-	POSTGRES_CONNECTION = os.Getenv("POSTGRES_CONNECTION")
-	DB = os.Getenv("DB")
-	ACHIEVEMENTS = os.Getenv("ACHIEVEMENTS")
-	ACHIEVEMENT_ICONS = os.Getenv("ACHIEVEMENT_ICONS")
-
-	if CLIENT == "" {
-		log.Fatal("CLIENT not set")
-	}
-	if ICONS == "" {
-		log.Fatal("ICONS not set")
-	}
-	if COMBINATIONS == "" {
-		log.Fatal("COMBINATIONS not set")
-	}
-	if WORDS == "" {
-		log.Fatal("WORDS not set")
-	}
-	if COHERE_API_KEY == "" {
-		log.Fatal("COHERE_API_KEY not set")
-	}
-	if POSTGRES_CONNECTION == "" {
-		log.Fatal("POSTGRES_CONNECTION not set")
-	}
-	if DB == "" {
-		log.Fatal("DB not set")
-	}
-	if ACHIEVEMENTS == "" {
-		log.Fatal("ACHIEVEMENTS not set")
-	}
-	if ACHIEVEMENT_ICONS == "" {
-		log.Fatal("ACHIEVEMENT_ICONS not set")
-	}
-}
-
-func NewStore() (st.Storage, error) {
-	log.Printf("Using database [%s]", DB)
-	if DB == "POSTGRES" {
-		return st.NewPostgresStore(POSTGRES_CONNECTION)
-	}
-	if DB == "SQLITE" {
-		return st.NewSQLiteStore("store")
-	}
-	return nil, fmt.Errorf("DB [%s] not found", DB)
-}
-
-func main() {
-	seed := flag.Bool("seed", false, "seed images & elements")
-	flag.Parse()
-
-	store, err := NewStore()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	if err := store.Init(); err != nil {
-		log.Println("Error initializing database")
-		log.Fatal(err)
-	}
-
-	//./bin/wc --seed
-	if *seed {
-		st.SeedDB(store, WORDS, COMBINATIONS, ICONS, ACHIEVEMENT_ICONS, ACHIEVEMENTS)
-		log.Println("Seeding completed, exiting...")
-		os.Exit(0)
-	}
-
-	//Accounts for ports provided by hosting services
-	PORT := os.Getenv("PORT")
-	if PORT == "" {
-		PORT = "3030"
-	}
-
-	server := NewAPIServer(":"+PORT, store)
-	log.Printf("Starting server on port %s", PORT)
-	go server.Run()
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
-
-	<-stop //Wait for stop signal
-	log.Println("Shutting down server...")
-}
+package main
+
+// @title WomboCombo Go API
+// @version 1.0
+// @description This is the API for Wombo Combo Go
+// @host localhost:3030
+// @BasePath /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/na50r/wombo-combo-go-be/archive"
+	cb "github.com/na50r/wombo-combo-go-be/combiner"
+	"github.com/na50r/wombo-combo-go-be/config"
+	_ "github.com/na50r/wombo-combo-go-be/docs"
+	g "github.com/na50r/wombo-combo-go-be/game"
+	"github.com/na50r/wombo-combo-go-be/graph"
+	"github.com/na50r/wombo-combo-go-be/importer"
+	st "github.com/na50r/wombo-combo-go-be/storage"
+	t "github.com/na50r/wombo-combo-go-be/token"
+)
+
+func PrintMigrationStatus(store st.Storage) error {
+	status, err := store.MigrationStatus()
+	if err != nil {
+		return err
+	}
+	for _, migration := range status {
+		applied := "pending"
+		if migration.AppliedAt != nil {
+			applied = "applied " + migration.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%3d  %-40s %s\n", migration.Version, migration.Name, applied)
+	}
+	return nil
+}
+
+func NewBackplane(kind string, store st.Storage) (g.Backplane, error) {
+	if kind == "LOCAL" {
+		return g.NewLocalBackplane(), nil
+	}
+	log.Printf("Using backplane [%s]", kind)
+	if kind == "POSTGRES" {
+		pgStore, ok := store.(*st.PostgresStore)
+		if !ok {
+			return nil, fmt.Errorf("BACKPLANE [%s] requires DB [POSTGRES]", kind)
+		}
+		return g.NewPostgresBackplane(pgStore)
+	}
+	return nil, fmt.Errorf("BACKPLANE [%s] not found", kind)
+}
+
+func main() {
+	loader := config.NewLoader(flag.CommandLine)
+	seed := flag.Bool("seed", false, "seed images & elements")
+	seedOnly := flag.String("seed-only", "", "comma separated categories seeded by --seed: icons, combinations, words, achievements (default all)")
+	migrate := flag.Bool("migrate", false, "apply pending schema migrations")
+	migrateTo := flag.Int("migrate-to", st.LatestVersion, "apply or revert schema migrations until the given version")
+	migrateStatus := flag.Bool("migrate-status", false, "show applied and pending schema migrations")
+	recompute := flag.Bool("recompute", false, "recompute depth and reachability of all words from the combinations")
+	importItems := flag.String("import-items", "", "import words & combinations from an items.json of the Infinite Craft dataset")
+	exportPath := flag.String("export", "", "export accounts, words, images & achievements to an archive (.tar or .tar.gz)")
+	importPath := flag.String("import", "", "import an archive written by --export")
+	batchSize := flag.Int("batch-size", 1000, "rows written per transaction by --seed, --import-items and --import")
+	flag.Parse()
+
+	cfg, err := loader.Load(*seed)
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	t.Configure(cfg.Token)
+
+	store, err := st.Open(cfg.Storage)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	//./bin/wc --migrate-status
+	if *migrateStatus {
+		if err := PrintMigrationStatus(store); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	//./bin/wc --migrate or ./bin/wc --migrate-to=<version>
+	if *migrate || *migrateTo != st.LatestVersion {
+		if err := store.Migrate(*migrateTo); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Migration completed, exiting...")
+		os.Exit(0)
+	}
+
+	if err := store.Init(); err != nil {
+		log.Println("Error initializing database")
+		log.Fatal(err)
+	}
+
+	//./bin/wc --seed or ./bin/wc --seed --seed-only=words,combinations
+	if *seed {
+		categories, err := st.ParseSeedCategories(*seedOnly)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Seeding database...")
+		results, err := st.SeedDB(context.Background(), store, cfg.Seed, categories, *batchSize)
+		st.LogSeedResults(results)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Seeding completed, exiting...")
+		os.Exit(0)
+	}
+
+	//./bin/wc --import-items=items.json
+	if *importItems != "" {
+		report, err := importer.ImportItems(context.Background(), store, *importItems, *batchSize)
+		if err != nil {
+			log.Fatal(err)
+		}
+		report.Log(20)
+		log.Println("Import completed, exiting...")
+		os.Exit(0)
+	}
+
+	//./bin/wc --export=snapshot.tar.gz
+	if *exportPath != "" {
+		counts, err := archive.Export(context.Background(), store, *exportPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		counts.Log("Exported")
+		log.Println("Export completed, exiting...")
+		os.Exit(0)
+	}
+
+	//./bin/wc --import=snapshot.tar.gz
+	if *importPath != "" {
+		counts, err := archive.Import(context.Background(), store, *importPath, *batchSize)
+		counts.Log("Imported")
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Import completed, exiting...")
+		os.Exit(0)
+	}
+
+	//./bin/wc --recompute
+	if *recompute {
+		result, err := graph.Recompute(context.Background(), store)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Recomputed words: %d updated, %d unchanged, %d unreachable", result.Updated, result.Unchanged, result.Unreachable)
+		log.Println("Recompute completed, exiting...")
+		os.Exit(0)
+	}
+
+	combiner, err := cb.New(cfg.Combiner)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	backplane, err := NewBackplane(cfg.Backplane, store)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server := NewAPIServer(cfg, st.WithTimeout(store, cfg.Storage.Timeout), cb.WithTimeout(combiner, cfg.Combiner.Timeout), backplane)
+	log.Printf("Starting server on port %s", cfg.Port)
+	go server.Run()
+	recomputeCtx, stopRecompute := context.WithCancel(context.Background())
+	if cfg.RecomputeInterval > 0 {
+		go graph.RecomputeEvery(recomputeCtx, store, cfg.RecomputeInterval)
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	<-stop //Wait for stop signal
+	log.Println("Shutting down server...")
+	ctx := context.Background()
+	if cfg.HTTP.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.HTTP.ShutdownTimeout)
+		defer cancel()
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+	stopRecompute()
+	// Requests that were still running publish through the backplane, so it is closed after the server
+	if err := backplane.Close(); err != nil {
+		log.Printf("Error closing backplane: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		log.Printf("Error closing storage: %v", err)
+	}
+	log.Println("Server stopped")
+}
@@ -1,162 +1,457 @@
-package sse
-
-// Base SSE template
-
-import (
-	"encoding/json"
-	"fmt"
-	"log"
-	"net/http"
-)
-
-type Message struct {
-	Data interface{} `json:"data"`
-}
-
-type Broker struct {
-	cnt            int
-	newClients     chan Subscription
-	goneClients    chan Subscription
-	ClientChannels map[int]chan []byte
-
-	//Specify further action when new client is detected
-	OnNewClient      func(sub Subscription)
-
-	//Specifiy further action when client is removed
-	OnRemoveClient   func(sub Subscription)
-
-	//Should create the subscription not only based on id and channel but also http request
-	MakeSubscription func(r *http.Request, id int, channel chan []byte) Subscription
-}
-
-type Subscription interface {
-	GetChannelID() int
-	GetChannel() chan []byte
-}
-
-type BaseSubscription struct {
-	ChannelID int         `json:"channelId"`
-	Channel   chan []byte `json:"channel"`
-}
-
-func (s BaseSubscription) GetChannelID() int       { return s.ChannelID }
-func (s BaseSubscription) GetChannel() chan []byte { return s.Channel }
-
-func NewSubscription(id int, channel chan []byte) BaseSubscription {
-	return BaseSubscription{ChannelID: id, Channel: channel}
-}
-
-func NewBroker(
-	onNew func(sub Subscription),
-	onRemove func(sub Subscription),
-	makeSub func(r *http.Request, id int, ch chan []byte) Subscription,
-) *Broker {
-	b := Broker{
-		ClientChannels:   make(map[int]chan []byte),
-		newClients:       make(chan Subscription),
-		goneClients:      make(chan Subscription),
-		cnt:              0,
-		OnNewClient:      onNew,
-		OnRemoveClient:   onRemove,
-		MakeSubscription: makeSub,
-	}
-	//handle new clients and gone clients in separate goroutine
-	go b.listen()
-	return &b
-}
-
-func (b *Broker) createChannel() (int, chan []byte) {
-	b.cnt++
-	b.ClientChannels[b.cnt] = make(chan []byte)
-	return b.cnt, b.ClientChannels[b.cnt]
-}
-
-func (b *Broker) listen() {
-	for {
-		select {
-		case sub := <-b.newClients:
-			log.Printf("client connected (ch=%d), total clients: %d\n", sub.GetChannelID(), len(b.ClientChannels))
-			if b.OnNewClient != nil {
-				b.OnNewClient(sub)
-			}
-		case unsub := <-b.goneClients:
-			channel := b.ClientChannels[unsub.GetChannelID()]
-			delete(b.ClientChannels, unsub.GetChannelID())
-			close(channel)
-			if b.OnRemoveClient != nil {
-				b.OnRemoveClient(unsub)
-			}
-			log.Printf("client disconnected (ch=%d), total clients: %d\n", unsub.GetChannelID(), len(b.ClientChannels))
-		}
-	}
-}
-
-func (b *Broker) SSEHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-
-	channelID, channel := b.createChannel()
-	var sub Subscription
-	if b.MakeSubscription != nil {
-		sub = b.MakeSubscription(r, channelID, channel)
-	} else {
-		sub = NewSubscription(channelID, channel)
-	}
-
-	b.newClients <- sub
-	clientGone := r.Context().Done()
-	rc := http.NewResponseController(w)
-
-	for {
-		select {
-		case <-clientGone:
-			b.goneClients <- sub
-			return
-		case data := <-channel:
-			written, err := fmt.Fprintf(w, "event:msg\ndata:%s\n\n", data)
-			log.Printf("written %d bytes", written)
-			if err != nil {
-				log.Printf("unable to write: %s", err.Error())
-				return
-			}
-			err = rc.Flush()
-			if err != nil {
-				log.Printf("unable to flush: %s", err.Error())
-				return
-			}
-		}
-	}
-}
-
-func (b *Broker) Publish(msg Message) {
-	data, err := json.Marshal(msg.Data)
-	if err != nil {
-		log.Printf("unable to marshal: %s", err.Error())
-		return
-	}
-	for _, channel := range b.ClientChannels {
-		channel <- data
-	}
-}
-
-func (b *Broker) PublishToGroup(group map[int]bool, msg Message) {
-	data, err := json.Marshal(msg.Data)
-	if err != nil {
-		log.Printf("unable to marshal: %s", err.Error())
-		return
-	}
-	for cli := range group {
-		b.ClientChannels[cli] <- data
-	}
-}
-
-func (b *Broker) PublishToClient(client int, msg Message) {
-	data, err := json.Marshal(msg.Data)
-	if err != nil {
-		log.Printf("unable to marshal: %s", err.Error())
-		return
-	}
-	b.ClientChannels[client] <- data
-}
+package sse
+
+// Base SSE template
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultEvent = "msg"
+
+type Message struct {
+	ID    uint64      `json:"id"`    // Assigned on publish if zero
+	Event string      `json:"event"` // Defaults to "msg"
+	Data  interface{} `json:"data"`
+}
+
+// Frame is a message as queued for clients, the data is marshalled once per publish
+type Frame struct {
+	ID    uint64          `json:"id"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+func (m Message) frame() (Frame, error) {
+	data, err := json.Marshal(m.Data)
+	if err != nil {
+		return Frame{}, err
+	}
+	event := m.Event
+	if event == "" {
+		event = DefaultEvent
+	}
+	return Frame{ID: m.ID, Event: event, Data: data}, nil
+}
+
+// SSE formats the frame as a Server-Sent Event
+func (f Frame) SSE() []byte {
+	return []byte(fmt.Sprintf("id:%d\nevent:%s\ndata:%s\n\n", f.ID, f.Event, f.Data))
+}
+
+// LastEventID returns the ID of the last event a reconnecting client received, 0 if unknown.
+// Browsers send the header on reconnect, the query parameter allows setting it on the first connect.
+func LastEventID(r *http.Request) uint64 {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("lastEventId")
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+type SlowConsumerPolicy string
+
+const (
+	// Drop messages for a client whose buffer is full
+	DropMessages SlowConsumerPolicy = "DROP"
+	// Disconnect a client whose buffer is full, it is expected to reconnect
+	DisconnectClient SlowConsumerPolicy = "DISCONNECT"
+)
+
+type HeartbeatStyle string
+
+const (
+	// SSE comment, ignored by EventSource but keeps proxies from closing the connection
+	HeartbeatComment HeartbeatStyle = "COMMENT"
+	// "ping" event, visible to clients that want to detect a stale connection themselves
+	HeartbeatPing HeartbeatStyle = "PING"
+)
+
+type Config struct {
+	BufferSize        int                // Messages buffered per client, on top of room for a full replay of the history
+	Policy            SlowConsumerPolicy // What happens when a client's buffer is full
+	HistorySize       int                // Messages kept per group for Last-Event-ID replay
+	HeartbeatInterval time.Duration      // Time between heartbeats, 0 disables them
+	HeartbeatStyle    HeartbeatStyle     // How heartbeats are sent
+}
+
+func DefaultConfig() Config {
+	return Config{
+		BufferSize:        16,
+		Policy:            DropMessages,
+		HistorySize:       32,
+		HeartbeatInterval: 15 * time.Second,
+		HeartbeatStyle:    HeartbeatComment,
+	}
+}
+
+func (c Config) heartbeat() []byte {
+	if c.HeartbeatStyle == HeartbeatPing {
+		return []byte("event:ping\ndata:{}\n\n")
+	}
+	return []byte(": ping\n\n")
+}
+
+type client struct {
+	channel   chan Frame
+	done      chan struct{} // closed once the client's handler returns
+	evict     chan struct{} // closed to make the handler disconnect a slow client
+	evictOnce sync.Once
+}
+
+type Stats struct {
+	Clients int   `json:"clients"`
+	Dropped int64 `json:"dropped"` // Messages dropped because a client's buffer was full
+	Evicted int64 `json:"evicted"` // Clients disconnected for being too slow
+}
+
+type Broker struct {
+	config      Config
+	mu          sync.RWMutex // guards cnt and clients
+	cnt         int
+	newClients  chan Subscription
+	goneClients chan Subscription
+	clients     map[int]*client
+	lastID      atomic.Uint64
+	dropped     atomic.Int64
+	evicted     atomic.Int64
+	closing     chan struct{} // closed on shutdown, every client stream ends
+	closeOnce   sync.Once
+
+	//Specify further action when new client is detected
+	OnNewClient func(sub Subscription)
+
+	//Specifiy further action when client is removed
+	OnRemoveClient func(sub Subscription)
+
+	//Should create the subscription not only based on id and channel but also http request
+	MakeSubscription func(r *http.Request, id int, channel chan Frame) Subscription
+}
+
+type Subscription interface {
+	GetChannelID() int
+	GetChannel() chan Frame
+}
+
+type BaseSubscription struct {
+	ChannelID int        `json:"channelId"`
+	Channel   chan Frame `json:"channel"`
+}
+
+func (s BaseSubscription) GetChannelID() int      { return s.ChannelID }
+func (s BaseSubscription) GetChannel() chan Frame { return s.Channel }
+
+func NewSubscription(id int, channel chan Frame) BaseSubscription {
+	return BaseSubscription{ChannelID: id, Channel: channel}
+}
+
+func NewBroker(
+	config Config,
+	onNew func(sub Subscription),
+	onRemove func(sub Subscription),
+	makeSub func(r *http.Request, id int, ch chan Frame) Subscription,
+) *Broker {
+	if config.BufferSize < 1 {
+		config.BufferSize = DefaultConfig().BufferSize
+	}
+	if config.Policy == "" {
+		config.Policy = DefaultConfig().Policy
+	}
+	if config.HistorySize < 0 {
+		config.HistorySize = 0
+	}
+	if config.HeartbeatInterval < 0 {
+		config.HeartbeatInterval = 0
+	}
+	if config.HeartbeatStyle == "" {
+		config.HeartbeatStyle = DefaultConfig().HeartbeatStyle
+	}
+	b := Broker{
+		config:           config,
+		clients:          make(map[int]*client),
+		newClients:       make(chan Subscription),
+		goneClients:      make(chan Subscription),
+		closing:          make(chan struct{}),
+		cnt:              0,
+		OnNewClient:      onNew,
+		OnRemoveClient:   onRemove,
+		MakeSubscription: makeSub,
+	}
+	// Start from the current time, so that IDs keep increasing across restarts
+	b.lastID.Store(uint64(time.Now().UnixMilli()))
+	//handle new clients and gone clients in separate goroutine
+	go b.listen()
+	return &b
+}
+
+func (b *Broker) Config() Config {
+	return b.config
+}
+
+// NextID returns a new event ID, IDs are monotonically increasing
+func (b *Broker) NextID() uint64 {
+	return b.lastID.Add(1)
+}
+
+// ObserveID makes sure that IDs handed out later are greater than an ID assigned elsewhere, e.g. by a backplane
+func (b *Broker) ObserveID(id uint64) {
+	for {
+		last := b.lastID.Load()
+		if id <= last || b.lastID.CompareAndSwap(last, id) {
+			return
+		}
+	}
+}
+
+func (b *Broker) createChannel() (int, *client) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cnt++
+	// A reconnecting client gets up to HistorySize replayed messages queued at once,
+	// they must not fill the buffer that is meant for live messages
+	cli := &client{
+		channel: make(chan Frame, b.config.HistorySize+b.config.BufferSize),
+		done:    make(chan struct{}),
+		evict:   make(chan struct{}),
+	}
+	b.clients[b.cnt] = cli
+	return b.cnt, cli
+}
+
+func (b *Broker) ClientCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.clients)
+}
+
+func (b *Broker) Stats() Stats {
+	return Stats{
+		Clients: b.ClientCount(),
+		Dropped: b.dropped.Load(),
+		Evicted: b.evicted.Load(),
+	}
+}
+
+// Sends never block, a full buffer is handled according to the slow consumer policy.
+// Client channels are never closed, so a send can not race with the removal of the client.
+func (b *Broker) send(id int, data Frame) {
+	b.mu.RLock()
+	cli, ok := b.clients[id]
+	b.mu.RUnlock()
+	if !ok {
+		return
+	}
+	select {
+	case <-cli.done:
+		return
+	default:
+	}
+	select {
+	case cli.channel <- data:
+	default:
+		b.dropped.Add(1)
+		if b.config.Policy == DisconnectClient {
+			cli.evictOnce.Do(func() {
+				b.evicted.Add(1)
+				log.Printf("client too slow, disconnecting (ch=%d)", id)
+				close(cli.evict)
+			})
+		}
+	}
+}
+
+func (b *Broker) clientIDs() []int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	ids := make([]int, 0, len(b.clients))
+	for id := range b.clients {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (b *Broker) listen() {
+	for {
+		select {
+		case sub := <-b.newClients:
+			log.Printf("client connected (ch=%d), total clients: %d\n", sub.GetChannelID(), b.ClientCount())
+			if b.OnNewClient != nil {
+				b.OnNewClient(sub)
+			}
+		case unsub := <-b.goneClients:
+			b.mu.Lock()
+			delete(b.clients, unsub.GetChannelID())
+			b.mu.Unlock()
+			if b.OnRemoveClient != nil {
+				b.OnRemoveClient(unsub)
+			}
+			log.Printf("client disconnected (ch=%d), total clients: %d\n", unsub.GetChannelID(), b.ClientCount())
+		}
+	}
+}
+
+// Client is a subscribed connection, independent of the transport that delivers its frames
+type Client struct {
+	Sub     Subscription
+	Frames  <-chan Frame
+	Evicted <-chan struct{} // closed when the client is too slow and has to disconnect
+	Closing <-chan struct{} // closed when the broker shuts down, pending frames should still be sent
+	cli     *client
+	b       *Broker
+	once    sync.Once
+}
+
+// Subscribe registers a new client, transports other than SSEHandler use it directly.
+// The client has to be closed once its connection ends.
+func (b *Broker) Subscribe(r *http.Request) *Client {
+	channelID, cli := b.createChannel()
+	var sub Subscription
+	if b.MakeSubscription != nil {
+		sub = b.MakeSubscription(r, channelID, cli.channel)
+	} else {
+		sub = NewSubscription(channelID, cli.channel)
+	}
+	b.newClients <- sub
+	return &Client{Sub: sub, Frames: cli.channel, Evicted: cli.evict, Closing: b.closing, cli: cli, b: b}
+}
+
+// Pending returns the frames that are queued but not yet sent
+func (c *Client) Pending() []Frame {
+	frames := []Frame{}
+	for {
+		select {
+		case frame := <-c.Frames:
+			frames = append(frames, frame)
+		default:
+			return frames
+		}
+	}
+}
+
+// Close stops publishers from queueing further messages and removes the client
+func (c *Client) Close() {
+	c.once.Do(func() {
+		close(c.cli.done)
+		c.b.goneClients <- c.Sub
+	})
+}
+
+func (b *Broker) SSEHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	c := b.Subscribe(r)
+	channelID := c.Sub.GetChannelID()
+	clientGone := r.Context().Done()
+	rc := http.NewResponseController(w)
+	// Every way out of the handler removes the client, including failed writes
+	defer c.Close()
+	// The stream outlives the timeouts of the server, failed writes and heartbeats detect dead clients instead
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	// Send the headers right away, so that the client knows the stream is open
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Printf("unable to flush: %s", err.Error())
+		return
+	}
+
+	var heartbeat <-chan time.Time
+	if b.config.HeartbeatInterval > 0 {
+		ticker := time.NewTicker(b.config.HeartbeatInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-clientGone:
+			return
+		case <-c.Evicted:
+			return
+		case <-c.Closing:
+			for _, frame := range c.Pending() {
+				if err := write(w, rc, frame.SSE()); err != nil {
+					return
+				}
+			}
+			return
+		case <-heartbeat:
+			if err := write(w, rc, b.config.heartbeat()); err != nil {
+				log.Printf("heartbeat failed, disconnecting (ch=%d): %s", channelID, err.Error())
+				return
+			}
+		case frame := <-c.Frames:
+			if err := write(w, rc, frame.SSE()); err != nil {
+				log.Printf("unable to write, disconnecting (ch=%d): %s", channelID, err.Error())
+				return
+			}
+		}
+	}
+}
+
+func write(w http.ResponseWriter, rc *http.ResponseController, data []byte) error {
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
+
+// Shutdown sends a last message to every client and ends all streams, clients subscribing afterwards are closed right away
+func (b *Broker) Shutdown(msg Message) {
+	b.closeOnce.Do(func() {
+		b.Publish(msg)
+		close(b.closing)
+	})
+}
+
+func (b *Broker) encode(msg Message) (Frame, error) {
+	if msg.ID == 0 {
+		msg.ID = b.NextID()
+	}
+	return msg.frame()
+}
+
+func (b *Broker) Publish(msg Message) {
+	data, err := b.encode(msg)
+	if err != nil {
+		log.Printf("unable to marshal: %s", err.Error())
+		return
+	}
+	for _, cli := range b.clientIDs() {
+		b.send(cli, data)
+	}
+}
+
+func (b *Broker) PublishToGroup(group map[int]bool, msg Message) {
+	data, err := b.encode(msg)
+	if err != nil {
+		log.Printf("unable to marshal: %s", err.Error())
+		return
+	}
+	for cli := range group {
+		b.send(cli, data)
+	}
+}
+
+func (b *Broker) PublishToClient(client int, msg Message) {
+	data, err := b.encode(msg)
+	if err != nil {
+		log.Printf("unable to marshal: %s", err.Error())
+		return
+	}
+	b.send(client, data)
+}
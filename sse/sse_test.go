@@ -0,0 +1,84 @@
+package sse
+
+import (
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run with -race, clients come and go while messages are published to all of them, to groups and to single clients
+func TestBrokerConcurrentClients(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	config := DefaultConfig()
+	config.BufferSize = 4
+	config.Policy = DisconnectClient
+	b := NewBroker(config, nil, nil, nil)
+
+	const clients, rounds = 50, 20
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for round := 0; round < rounds; round++ {
+				c := b.Subscribe(httptest.NewRequest("GET", "/events", nil))
+				// Every other client is slow and gets evicted
+				if i%2 == 0 {
+					for range 3 {
+						select {
+						case <-c.Frames:
+						case <-c.Evicted:
+						case <-time.After(time.Millisecond):
+						}
+					}
+				}
+				c.Pending()
+				c.Close()
+				// Closing twice is harmless
+				c.Close()
+			}
+		}(i)
+	}
+	done := make(chan struct{})
+	var publishers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		publishers.Add(1)
+		go func(i int) {
+			defer publishers.Done()
+			for n := 0; ; n++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				switch n % 3 {
+				case 0:
+					b.Publish(Message{Event: "all", Data: n})
+				case 1:
+					b.PublishToGroup(map[int]bool{n % clients: true, i: true}, Message{Event: "group", Data: n})
+				case 2:
+					b.PublishToClient(n%clients, Message{Event: "client", Data: n})
+				}
+				b.Stats()
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(done)
+	publishers.Wait()
+
+	// Removal happens in the broker's goroutine
+	deadline := time.Now().Add(5 * time.Second)
+	for b.ClientCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d clients left after all of them closed", b.ClientCount())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	b.Shutdown(Message{Event: "bye"})
+}
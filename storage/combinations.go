@@ -0,0 +1,72 @@
+package storage
+
+// Coalesces concurrent lookups of the same pair, so that players combining
+// the same unseen pair at the same time only trigger a single combiner call
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cb "github.com/na50r/wombo-combo-go-be/combiner"
+	u "github.com/na50r/wombo-combo-go-be/utility"
+)
+
+type combinationCall struct {
+	done   chan struct{} // closed once result, isNew and err are set
+	result string
+	isNew  bool
+	err    error
+}
+
+type CombinationGroup struct {
+	mu      sync.Mutex
+	calls   map[string]*combinationCall // sorted "a+b" → in-flight lookup
+	timeout time.Duration               // Deadline of a shared lookup, 0 disables it
+}
+
+func NewCombinationGroup(timeout time.Duration) *CombinationGroup {
+	return &CombinationGroup{calls: make(map[string]*combinationCall), timeout: timeout}
+}
+
+// GetCombination behaves like the package level GetCombination, but callers waiting on
+// an in-flight lookup share its result and never see isNew, only the first discoverer does.
+// Every caller stops waiting once its own ctx ends, the lookup goes on for the others.
+func (g *CombinationGroup) GetCombination(ctx context.Context, store Storage, combiner cb.Combiner, a, b string) (string, bool, error) {
+	sa, sb := u.SortAB(a, b)
+	key := sa + "+" + sb
+	g.mu.Lock()
+	call, inFlight := g.calls[key]
+	if !inFlight {
+		call = &combinationCall{done: make(chan struct{})}
+		g.calls[key] = call
+		go g.run(ctx, key, call, store, combiner, a, b)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return "", false, ctx.Err()
+	case <-call.done:
+	}
+	if inFlight {
+		return call.result, false, call.err
+	}
+	return call.result, call.isNew, call.err
+}
+
+// run looks the pair up detached from the first caller, a client that leaves does not fail everyone else
+func (g *CombinationGroup) run(ctx context.Context, key string, call *combinationCall, store Storage, combiner cb.Combiner, a, b string) {
+	ctx = context.WithoutCancel(ctx)
+	if g.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+	}
+	call.result, call.isNew, call.err = GetCombination(ctx, store, combiner, a, b)
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(call.done)
+}
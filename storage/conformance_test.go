@@ -0,0 +1,845 @@
+package storage
+
+// Backend-agnostic conformance suite, every Storage implementation has to pass it.
+// Run with: go test ./storage -run Conformance
+// Postgres is only checked if CONFORMANCE_POSTGRES holds a connection string,
+// the suite drops and recreates the schema of that database for every case.
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	ae "github.com/na50r/wombo-combo-go-be/apierror"
+	c "github.com/na50r/wombo-combo-go-be/constants"
+)
+
+type conformanceBackend struct {
+	name string
+	// open returns an empty, migrated store that is removed when the test ends
+	open func(t *testing.T) Storage
+}
+
+type conformanceCase struct {
+	name string
+	run  func(ctx context.Context, s Storage) error
+}
+
+func conformanceBackends() []conformanceBackend {
+	return []conformanceBackend{
+		{name: "MEMORY", open: func(t *testing.T) Storage { return NewMemoryStore() }},
+		{name: "SQLITE", open: openConformanceSQLite},
+		{name: "POSTGRES", open: openConformancePostgres},
+	}
+}
+
+func openConformanceSQLite(t *testing.T) Storage {
+	name := fmt.Sprintf("conformance_%d", time.Now().UnixNano())
+	store, err := NewSQLiteStore(name)
+	if err != nil {
+		t.Fatalf("unable to open store: %v", err)
+	}
+	t.Cleanup(func() {
+		store.Close()
+		for _, suffix := range []string{".db", ".db-wal", ".db-shm"} {
+			os.Remove(name + suffix)
+		}
+	})
+	if err := store.Migrate(LatestVersion); err != nil {
+		t.Fatalf("unable to migrate store: %v", err)
+	}
+	return store
+}
+
+func openConformancePostgres(t *testing.T) Storage {
+	connString := os.Getenv("CONFORMANCE_POSTGRES")
+	if connString == "" {
+		t.Skip("CONFORMANCE_POSTGRES not set")
+	}
+	store, err := NewPostgresStore(connString)
+	if err != nil {
+		t.Fatalf("unable to open store: %v", err)
+	}
+	t.Cleanup(func() {
+		store.Close()
+	})
+	if err := store.Migrate(0); err != nil {
+		t.Fatalf("unable to reset schema: %v", err)
+	}
+	if err := store.Migrate(LatestVersion); err != nil {
+		t.Fatalf("unable to migrate store: %v", err)
+	}
+	return store
+}
+
+// TestConformance runs every case against a fresh store of every backend
+func TestConformance(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			for _, cc := range conformanceCases() {
+				t.Run(cc.name, func(t *testing.T) {
+					store := backend.open(t)
+					ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+					defer cancel()
+					if err := cc.run(ctx, store); err != nil {
+						t.Fatal(err)
+					}
+				})
+			}
+		})
+	}
+}
+
+func expectNoError(err error, what string) error {
+	if err != nil {
+		return fmt.Errorf("%s: unexpected error: %w", what, err)
+	}
+	return nil
+}
+
+func expectError(err error, what string) error {
+	if err == nil {
+		return fmt.Errorf("%s: expected an error", what)
+	}
+	return nil
+}
+
+func expectKind(err error, kind ae.Kind, what string) error {
+	if !ae.Is(err, kind) {
+		return fmt.Errorf("%s: got %v, want an error of kind %d", what, err, kind)
+	}
+	return nil
+}
+
+func expectEqual(got, want any, what string) error {
+	if !reflect.DeepEqual(got, want) {
+		return fmt.Errorf("%s: got %v, want %v", what, got, want)
+	}
+	return nil
+}
+
+// firstError returns the first failed expectation, expectations are evaluated in order
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func conformanceCases() []conformanceCase {
+	return []conformanceCase{
+		{"accounts", conformAccounts},
+		{"owners", conformOwners},
+		{"images", conformImages},
+		{"lobbies", conformLobbies},
+		{"players", conformPlayers},
+		{"points and winner", conformPoints},
+		{"combinations", conformCombinations},
+		{"target words", conformTargetWords},
+		{"words", conformWords},
+		{"player words", conformPlayerWords},
+		{"daily challenge", conformDailyChallenge},
+		{"daily history", conformDailyHistory},
+		{"achievements", conformAchievements},
+		{"sessions", conformSessions},
+		{"games", conformGames},
+		{"word uses", conformWordUses},
+		{"transactions", conformTransactions},
+	}
+}
+
+func conformAccounts(ctx context.Context, s Storage) error {
+	acc := &Account{Username: "alice", ImageName: "a.png", Password: "pw", CreatedAt: "2024-01-01 00:00:00", Status: c.OFFLINE}
+	if err := expectNoError(s.CreateAccount(ctx, acc), "CreateAccount"); err != nil {
+		return err
+	}
+	if err := expectKind(s.CreateAccount(ctx, acc), ae.Conflict, "CreateAccount duplicate"); err != nil {
+		return err
+	}
+	_, err := s.GetAccountByUsername(ctx, "nobody")
+	if err := expectError(err, "GetAccountByUsername missing"); err != nil {
+		return err
+	}
+	acc.Wins = 2
+	acc.Status = c.ONLINE
+	if err := expectNoError(s.UpdateAccount(ctx, acc), "UpdateAccount"); err != nil {
+		return err
+	}
+	if err := expectNoError(s.UpdateAccountWordCount(ctx, "alice", 3, 7), "UpdateAccountWordCount"); err != nil {
+		return err
+	}
+	got, err := s.GetAccountByUsername(ctx, "alice")
+	if err := expectNoError(err, "GetAccountByUsername"); err != nil {
+		return err
+	}
+	err = firstError(
+		expectEqual(got.Wins, 2, "wins"),
+		expectEqual(got.Status, c.ONLINE, "status"),
+		expectEqual(got.NewWordCount, 3, "new word count"),
+		expectEqual(got.WordCount, 7, "word count"),
+	)
+	if err != nil {
+		return err
+	}
+	accounts, err := s.GetAccounts(ctx)
+	if err := firstError(expectNoError(err, "GetAccounts"), expectEqual(len(accounts), 1, "accounts"), expectEqual(accounts[0].Password, "pw", "password hash")); err != nil {
+		return err
+	}
+	player, err := s.GetPlayerForAccount(ctx, "alice")
+	if err := firstError(expectNoError(err, "GetPlayerForAccount"), expectEqual(player.HasAccount, true, "has account")); err != nil {
+		return err
+	}
+	if err := expectNoError(s.DeleteAccount(ctx, "alice"), "DeleteAccount"); err != nil {
+		return err
+	}
+	_, err = s.GetAccountByUsername(ctx, "alice")
+	return firstError(
+		expectError(err, "GetAccountByUsername after delete"),
+		expectNoError(s.DeleteAccount(ctx, "alice"), "DeleteAccount missing"),
+	)
+}
+
+func conformOwners(ctx context.Context, s Storage) error {
+	if err := expectNoError(s.CreateAccount(ctx, &Account{Username: "bob"}), "CreateAccount"); err != nil {
+		return err
+	}
+	err := firstError(
+		expectNoError(s.SetIsOwner(ctx, "bob", true), "SetIsOwner"),
+		expectError(s.SetIsOwner(ctx, "bob", true), "SetIsOwner twice"),
+		expectNoError(s.SetIsOwner(ctx, "bob", false), "SetIsOwner false"),
+		expectNoError(s.SetIsOwner(ctx, "bob", true), "SetIsOwner after release"),
+		expectError(s.SetIsOwner(ctx, "nobody", true), "SetIsOwner missing account"),
+	)
+	if err != nil {
+		return err
+	}
+	lobbyCode, err := s.GetLobbyForOwner(ctx, "bob")
+	if err := firstError(expectNoError(err, "GetLobbyForOwner none"), expectEqual(lobbyCode, "", "lobby without players")); err != nil {
+		return err
+	}
+	if err := expectNoError(s.CreatePlayer(ctx, &Player{Name: "bob", LobbyCode: "L1", IsOwner: true}), "CreatePlayer"); err != nil {
+		return err
+	}
+	lobbyCode, err = s.GetLobbyForOwner(ctx, "bob")
+	return firstError(expectNoError(err, "GetLobbyForOwner"), expectEqual(lobbyCode, "L1", "owned lobby"))
+}
+
+func conformImages(ctx context.Context, s Storage) error {
+	if err := expectEqual(s.NewImageForUsername(ctx, "alice"), "", "NewImageForUsername without images"); err != nil {
+		return err
+	}
+	err := firstError(
+		expectNoError(s.AddImage(ctx, []byte{1}, "a.png"), "AddImage"),
+		expectNoError(s.AddImage(ctx, []byte{2}, "b.png"), "AddImage"),
+		expectNoError(s.AddImage(ctx, []byte{3}, "a.png"), "AddImage replace"),
+	)
+	if err != nil {
+		return err
+	}
+	data, err := s.GetImage(ctx, "a.png")
+	if err := firstError(expectNoError(err, "GetImage"), expectEqual(data, []byte{3}, "replaced image")); err != nil {
+		return err
+	}
+	_, err = s.GetImage(ctx, "missing.png")
+	if err := expectError(err, "GetImage missing"); err != nil {
+		return err
+	}
+	images, err := s.GetImages(ctx)
+	if err := firstError(expectNoError(err, "GetImages"), expectEqual(len(images), 2, "image count")); err != nil {
+		return err
+	}
+	name := s.NewImageForUsername(ctx, "alice")
+	if name != "a.png" && name != "b.png" {
+		return fmt.Errorf("NewImageForUsername: got %s", name)
+	}
+	if err := expectNoError(s.CreateAccount(ctx, &Account{Username: "alice", ImageName: "b.png"}), "CreateAccount"); err != nil {
+		return err
+	}
+	data, err = s.GetImageByUsername(ctx, "alice")
+	if err := firstError(expectNoError(err, "GetImageByUsername"), expectEqual(data, []byte{2}, "account image")); err != nil {
+		return err
+	}
+	_, err = s.GetImageByUsername(ctx, "nobody")
+	return expectError(err, "GetImageByUsername missing")
+}
+
+func conformLobbies(ctx context.Context, s Storage) error {
+	lobby := NewLobby("Lobby", "L1", "a.png")
+	if err := expectNoError(s.CreateLobby(ctx, lobby), "CreateLobby"); err != nil {
+		return err
+	}
+	if err := expectError(s.CreateLobby(ctx, lobby), "CreateLobby duplicate"); err != nil {
+		return err
+	}
+	_, err := s.GetLobbyByCode(ctx, "missing")
+	if err := expectError(err, "GetLobbyByCode missing"); err != nil {
+		return err
+	}
+	err = firstError(
+		expectNoError(s.EditGameMode(ctx, "L1", c.FUSION_FRENZY), "EditGameMode"),
+		expectNoError(s.IncrementPlayerCount(ctx, "L1", 2), "IncrementPlayerCount"),
+	)
+	if err != nil {
+		return err
+	}
+	got, err := s.GetLobbyByCode(ctx, "L1")
+	err = firstError(
+		expectNoError(err, "GetLobbyByCode"),
+		expectEqual(got.GameMode, c.FUSION_FRENZY, "game mode"),
+		expectEqual(got.PlayerCount, 3, "player count"),
+	)
+	if err != nil {
+		return err
+	}
+	lobbies, err := s.GetLobbies(ctx)
+	if err := firstError(expectNoError(err, "GetLobbies"), expectEqual(len(lobbies), 1, "lobby count")); err != nil {
+		return err
+	}
+	if err := expectNoError(s.DeleteLobby(ctx, "L1"), "DeleteLobby"); err != nil {
+		return err
+	}
+	lobbies, err = s.GetLobbies(ctx)
+	return firstError(expectNoError(err, "GetLobbies"), expectEqual(len(lobbies), 0, "lobby count after delete"))
+}
+
+func conformPlayers(ctx context.Context, s Storage) error {
+	if err := expectNoError(s.CreateLobby(ctx, NewLobby("Lobby", "L1", "a.png")), "CreateLobby"); err != nil {
+		return err
+	}
+	owner := NewPlayer("owner", "L1", "a.png", true, false, 0, 0)
+	guest := NewPlayer("guest", "", "b.png", false, false, 0, 0)
+	err := firstError(
+		expectNoError(s.CreatePlayer(ctx, owner), "CreatePlayer"),
+		expectError(s.CreatePlayer(ctx, owner), "CreatePlayer duplicate"),
+		expectNoError(s.AddPlayerToLobby(ctx, "L1", guest), "AddPlayerToLobby"),
+		expectError(s.AddPlayerToLobby(ctx, "L1", guest), "AddPlayerToLobby duplicate"),
+	)
+	if err != nil {
+		return err
+	}
+	lobby, err := s.GetLobbyByCode(ctx, "L1")
+	if err := firstError(expectNoError(err, "GetLobbyByCode"), expectEqual(lobby.PlayerCount, 2, "player count")); err != nil {
+		return err
+	}
+	_, err = s.GetPlayerByLobbyCodeAndName(ctx, "guest", "L2")
+	if err := expectError(err, "GetPlayerByLobbyCodeAndName other lobby"); err != nil {
+		return err
+	}
+	err = firstError(
+		expectNoError(s.SetPlayerTargetWord(ctx, "guest", "steam", "L1"), "SetPlayerTargetWord"),
+		expectNoError(s.UpdatePlayerWordCount(ctx, "guest", "L1", 1, 5), "UpdatePlayerWordCount"),
+	)
+	if err != nil {
+		return err
+	}
+	player, err := s.GetPlayerByLobbyCodeAndName(ctx, "guest", "L1")
+	err = firstError(
+		expectNoError(err, "GetPlayerByLobbyCodeAndName"),
+		expectEqual(player.LobbyCode, "L1", "lobby code"),
+		expectEqual(player.TargetWord, "steam", "target word"),
+		expectEqual(player.NewWordCount, 1, "new word count"),
+		expectEqual(player.WordCount, 5, "word count"),
+	)
+	if err != nil {
+		return err
+	}
+	targetWord, err := s.GetPlayerTargetWord(ctx, "guest", "L1")
+	if err := firstError(expectNoError(err, "GetPlayerTargetWord"), expectEqual(targetWord, "steam", "target word")); err != nil {
+		return err
+	}
+	_, err = s.GetPlayerTargetWord(ctx, "nobody", "L1")
+	if err := expectError(err, "GetPlayerTargetWord missing"); err != nil {
+		return err
+	}
+	if err := expectNoError(s.DeletePlayer(ctx, "guest", "L1"), "DeletePlayer"); err != nil {
+		return err
+	}
+	players, err := s.GetPlayersByLobbyCode(ctx, "L1")
+	if err := firstError(expectNoError(err, "GetPlayersByLobbyCode"), expectEqual(len(players), 1, "players after delete")); err != nil {
+		return err
+	}
+	if err := expectNoError(s.DeletePlayersForLobby(ctx, "L1"), "DeletePlayersForLobby"); err != nil {
+		return err
+	}
+	players, err = s.GetPlayersByLobbyCode(ctx, "L1")
+	return firstError(expectNoError(err, "GetPlayersByLobbyCode"), expectEqual(len(players), 0, "players after lobby delete"))
+}
+
+func conformPoints(ctx context.Context, s Storage) error {
+	_, err := s.SelectWinnerByPoints(ctx, "L1")
+	if err := expectError(err, "SelectWinnerByPoints without players"); err != nil {
+		return err
+	}
+	err = firstError(
+		expectNoError(s.CreateAccount(ctx, &Account{Username: "winner"}), "CreateAccount"),
+		expectNoError(s.CreateAccount(ctx, &Account{Username: "loser"}), "CreateAccount"),
+		expectNoError(s.CreatePlayer(ctx, NewPlayer("winner", "L1", "", true, true, 0, 0)), "CreatePlayer"),
+		expectNoError(s.CreatePlayer(ctx, NewPlayer("loser", "L1", "", false, true, 0, 0)), "CreatePlayer"),
+		expectNoError(s.CreatePlayer(ctx, NewPlayer("guest", "L1", "", false, false, 0, 0)), "CreatePlayer"),
+		expectNoError(s.IncrementPlayerPoints(ctx, "winner", "L1", 5), "IncrementPlayerPoints"),
+		expectNoError(s.IncrementPlayerPoints(ctx, "loser", "L1", 2), "IncrementPlayerPoints"),
+		expectNoError(s.IncrementPlayerPoints(ctx, "nobody", "L1", 2), "IncrementPlayerPoints missing"),
+	)
+	if err != nil {
+		return err
+	}
+	winner, err := s.SelectWinnerByPoints(ctx, "L1")
+	if err := firstError(expectNoError(err, "SelectWinnerByPoints"), expectEqual(winner, "winner", "winner")); err != nil {
+		return err
+	}
+	if err := expectNoError(s.UpdateAccountWinsAndLosses(ctx, "L1", winner), "UpdateAccountWinsAndLosses"); err != nil {
+		return err
+	}
+	winnerAcc, err := s.GetAccountByUsername(ctx, "winner")
+	if err != nil {
+		return err
+	}
+	loserAcc, err := s.GetAccountByUsername(ctx, "loser")
+	if err != nil {
+		return err
+	}
+	err = firstError(
+		expectEqual(winnerAcc.Wins, 1, "wins of winner"),
+		expectEqual(winnerAcc.Losses, 0, "losses of winner"),
+		expectEqual(loserAcc.Losses, 1, "losses of loser"),
+		expectNoError(s.ResetPlayerPoints(ctx, "L1"), "ResetPlayerPoints"),
+	)
+	if err != nil {
+		return err
+	}
+	player, err := s.GetPlayerByLobbyCodeAndName(ctx, "winner", "L1")
+	return firstError(expectNoError(err, "GetPlayerByLobbyCodeAndName"), expectEqual(player.Points, 0, "points after reset"))
+}
+
+func conformCombinations(ctx context.Context, s Storage) error {
+	result, ok, err := s.GetCombination(ctx, "fire", "water")
+	if err := firstError(expectNoError(err, "GetCombination missing"), expectEqual(ok, false, "found"), expectEqual(result == nil, true, "nil result")); err != nil {
+		return err
+	}
+	err = firstError(
+		expectNoError(s.AddWord(ctx, &Word{Word: "Fire", Depth: 0, Reachability: 1}), "AddWord"),
+		expectNoError(s.AddWord(ctx, &Word{Word: "water", Depth: 1, Reachability: 1}), "AddWord"),
+		expectNoError(s.AddWord(ctx, &Word{Word: "fire", Depth: 5, Reachability: 1}), "AddWord duplicate"),
+		expectNoError(s.AddCombination(ctx, &Combination{A: "water", B: "fire", Result: "steam", Depth: 1}), "AddCombination"),
+		expectNoError(s.AddCombination(ctx, &Combination{A: "fire", B: "water", Result: "mist", Depth: 1}), "AddCombination duplicate"),
+	)
+	if err != nil {
+		return err
+	}
+	result, ok, err = s.GetCombination(ctx, "water", "fire")
+	if err := firstError(expectNoError(err, "GetCombination"), expectEqual(ok, true, "found"), expectEqual(*result, "steam", "first result wins")); err != nil {
+		return err
+	}
+	combinations, err := s.GetCombinations(ctx)
+	if err := firstError(expectNoError(err, "GetCombinations"), expectEqual(len(combinations), 1, "combinations")); err != nil {
+		return err
+	}
+	if err := firstError(expectEqual(combinations[0].A, "fire", "sorted a"), expectEqual(combinations[0].Result, "steam", "result")); err != nil {
+		return err
+	}
+	err = s.UpdateCombinations(ctx, []*Combination{{A: "water", B: "fire", Result: "mist", Depth: 2}, {A: "fire", B: "fire", Result: "sun", Depth: 1}})
+	if err := expectNoError(err, "UpdateCombinations"); err != nil {
+		return err
+	}
+	result, _, err = s.GetCombination(ctx, "fire", "water")
+	if err := firstError(expectNoError(err, "GetCombination"), expectEqual(*result, "mist", "updated result")); err != nil {
+		return err
+	}
+	combinations, err = s.GetCombinations(ctx)
+	if err := firstError(expectNoError(err, "GetCombinations"), expectEqual(len(combinations), 2, "updated and added combination")); err != nil {
+		return err
+	}
+	err = firstError(
+		expectNoError(s.AddNewCombination(ctx, "water", "water", "lake"), "AddNewCombination"),
+		expectError(s.AddNewCombination(ctx, "water", "unknown", "mud"), "AddNewCombination unknown ingredient"),
+	)
+	if err != nil {
+		return err
+	}
+	// lake is one level deeper than water
+	words, err := s.GetTargetWords(ctx, 0, 1, 2)
+	if err := firstError(expectNoError(err, "GetTargetWords"), expectEqual(len(words), 3, "words up to depth 2")); err != nil {
+		return err
+	}
+	words, err = s.GetTargetWords(ctx, 0, 1, 1)
+	return firstError(expectNoError(err, "GetTargetWords"), expectEqual(len(words), 2, "words up to depth 1"))
+}
+
+func conformTargetWords(ctx context.Context, s Storage) error {
+	_, err := s.GetTargetWord(ctx, 0, 1, 10)
+	if err := expectError(err, "GetTargetWord with an empty word pool"); err != nil {
+		return err
+	}
+	_, err = s.CreateOrGetDailyWord(ctx, 0, 1, 10)
+	if err := expectError(err, "CreateOrGetDailyWord with an empty word pool"); err != nil {
+		return err
+	}
+	err = firstError(
+		expectNoError(s.AddWord(ctx, &Word{Word: "steam", Depth: 1, Reachability: 0.5}), "AddWord"),
+		expectNoError(s.AddWord(ctx, &Word{Word: "lava", Depth: 3, Reachability: 0.5}), "AddWord"),
+		expectNoError(s.AddWord(ctx, &Word{Word: "mud", Depth: 1, Reachability: 0.9}), "AddWord"),
+	)
+	if err != nil {
+		return err
+	}
+	word, err := s.GetTargetWord(ctx, 0.4, 0.6, 2)
+	if err := firstError(expectNoError(err, "GetTargetWord"), expectEqual(word, "steam", "only matching word")); err != nil {
+		return err
+	}
+	daily, err := s.CreateOrGetDailyWord(ctx, 0.4, 0.6, 2)
+	if err := firstError(expectNoError(err, "CreateOrGetDailyWord"), expectEqual(daily, "steam", "daily word")); err != nil {
+		return err
+	}
+	again, err := s.CreateOrGetDailyWord(ctx, 0, 1, 10)
+	return firstError(expectNoError(err, "CreateOrGetDailyWord again"), expectEqual(again, daily, "daily word is kept for the day"))
+}
+
+func conformWords(ctx context.Context, s Storage) error {
+	err := firstError(
+		expectNoError(s.AddWord(ctx, &Word{Word: "steam", Depth: 4, Reachability: 0.1}), "AddWord"),
+		expectNoError(s.UpdateWords(ctx, []*Word{{Word: "Steam", Depth: 1, Reachability: 0.5}, {Word: "mist", Depth: 2, Reachability: 0.25}}), "UpdateWords"),
+	)
+	if err != nil {
+		return err
+	}
+	words, err := s.GetWords(ctx)
+	if err := firstError(expectNoError(err, "GetWords"), expectEqual(len(words), 2, "updated and added word")); err != nil {
+		return err
+	}
+	targets, err := s.GetTargetWords(ctx, 0.4, 0.6, 1)
+	return firstError(expectNoError(err, "GetTargetWords"), expectEqual(len(targets), 1, "steam after the update"))
+}
+
+func conformPlayerWords(ctx context.Context, s Storage) error {
+	err := firstError(
+		expectNoError(s.AddPlayerWord(ctx, "p1", "fire", "L1"), "AddPlayerWord"),
+		expectNoError(s.AddPlayerWord(ctx, "p1", "water", "L1"), "AddPlayerWord"),
+		expectNoError(s.AddPlayerWord(ctx, "p1", "fire", "L1"), "AddPlayerWord duplicate"),
+		expectNoError(s.AddPlayerWord(ctx, "p2", "fire", "L1"), "AddPlayerWord"),
+		expectNoError(s.AddPlayerWord(ctx, "p1", "earth", "L2"), "AddPlayerWord"),
+	)
+	if err != nil {
+		return err
+	}
+	words, err := s.GetPlayerWords(ctx, "p1", "L1")
+	if err := firstError(expectNoError(err, "GetPlayerWords"), expectEqual(len(words), 2, "player words")); err != nil {
+		return err
+	}
+	owned, err := s.IsPlayerWord(ctx, "p1", "water", "L1")
+	if err := firstError(expectNoError(err, "IsPlayerWord"), expectEqual(owned, true, "owned word")); err != nil {
+		return err
+	}
+	owned, err = s.IsPlayerWord(ctx, "p2", "water", "L1")
+	if err := firstError(expectNoError(err, "IsPlayerWord"), expectEqual(owned, false, "word of other player")); err != nil {
+		return err
+	}
+	counts, err := s.GetWordCountByLobbyCode(ctx, "L1")
+	if err := firstError(expectNoError(err, "GetWordCountByLobbyCode"), expectEqual(len(counts), 2, "players with words")); err != nil {
+		return err
+	}
+	// The four starting words are not counted
+	err = firstError(
+		expectEqual(counts[0].PlayerName, "p1", "most words first"),
+		expectEqual(counts[0].WordCount, 2-4, "word count"),
+		expectNoError(s.DeletePlayerWordsByPlayerAndLobbyCode(ctx, "p1", "L1"), "DeletePlayerWordsByPlayerAndLobbyCode"),
+	)
+	if err != nil {
+		return err
+	}
+	words, err = s.GetPlayerWords(ctx, "p1", "L2")
+	if err := firstError(expectNoError(err, "GetPlayerWords"), expectEqual(len(words), 1, "words in other lobby")); err != nil {
+		return err
+	}
+	if err := expectNoError(s.DeletePlayerWordsByLobbyCode(ctx, "L1"), "DeletePlayerWordsByLobbyCode"); err != nil {
+		return err
+	}
+	words, err = s.GetPlayerWords(ctx, "p2", "L1")
+	return firstError(expectNoError(err, "GetPlayerWords"), expectEqual(len(words), 0, "words after lobby delete"))
+}
+
+func conformDailyChallenge(ctx context.Context, s Storage) error {
+	entries, err := s.GetChallengeEntries(ctx)
+	if err := firstError(expectNoError(err, "GetChallengeEntries"), expectEqual(len(entries), 0, "entries")); err != nil {
+		return err
+	}
+	err = firstError(
+		expectNoError(s.AddDailyChallengeEntry(ctx, 10, "alice"), "AddDailyChallengeEntry"),
+		expectNoError(s.AddDailyChallengeEntry(ctx, 7, "alice"), "AddDailyChallengeEntry better"),
+		expectNoError(s.AddDailyChallengeEntry(ctx, 9, "alice"), "AddDailyChallengeEntry worse"),
+		expectNoError(s.AddDailyChallengeEntry(ctx, 12, "bob"), "AddDailyChallengeEntry"),
+	)
+	if err != nil {
+		return err
+	}
+	entries, err = s.GetChallengeEntries(ctx)
+	if err := firstError(expectNoError(err, "GetChallengeEntries"), expectEqual(len(entries), 2, "one entry per user")); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Username == "alice" {
+			return expectEqual(entry.WordCount, 7, "lowest word count is kept")
+		}
+	}
+	return fmt.Errorf("GetChallengeEntries: entry of alice missing")
+}
+
+func conformDailyHistory(ctx context.Context, s Storage) error {
+	yesterday := time.Now().AddDate(0, 0, -1)
+	err := firstError(
+		expectNoError(s.AddChallengeEntry(ctx, &Challenger{Timestamp: yesterday, WordCount: 8, Username: "alice"}), "AddChallengeEntry"),
+		expectNoError(s.AddChallengeEntry(ctx, &Challenger{Timestamp: yesterday, WordCount: 9, Username: "alice"}), "AddChallengeEntry worse"),
+		expectNoError(s.AddDailyChallengeEntry(ctx, 5, "alice"), "AddDailyChallengeEntry"),
+		expectNoError(s.AddDailyWord(ctx, &DailyWord{Timestamp: yesterday, Word: "steam"}), "AddDailyWord"),
+		expectNoError(s.AddDailyWord(ctx, &DailyWord{Timestamp: yesterday, Word: "mud"}), "AddDailyWord taken day"),
+	)
+	if err != nil {
+		return err
+	}
+	today, err := s.GetChallengeEntries(ctx)
+	if err := firstError(expectNoError(err, "GetChallengeEntries"), expectEqual(len(today), 1, "entries of today")); err != nil {
+		return err
+	}
+	entries, err := s.GetAllChallengeEntries(ctx)
+	if err := firstError(expectNoError(err, "GetAllChallengeEntries"), expectEqual(len(entries), 2, "entries of every day")); err != nil {
+		return err
+	}
+	if err := firstError(expectEqual(entries[0].WordCount, 8, "lowest word count of yesterday"), expectEqual(entries[0].Timestamp.Format("2006-01-02"), yesterday.Format("2006-01-02"), "day")); err != nil {
+		return err
+	}
+	dailyWords, err := s.GetDailyWords(ctx)
+	if err := firstError(expectNoError(err, "GetDailyWords"), expectEqual(len(dailyWords), 1, "daily words")); err != nil {
+		return err
+	}
+	return firstError(expectEqual(dailyWords[0].Word, "steam", "first word of the day is kept"), expectEqual(dailyWords[0].Timestamp.Format("2006-01-02"), yesterday.Format("2006-01-02"), "day"))
+}
+
+func conformAchievements(ctx context.Context, s Storage) error {
+	entry := &AchievementEntry{Title: "First", Type: c.WordCount, Value: "10", Description: "Find 10 words", ImageName: "first.png"}
+	if err := expectNoError(s.AddAchievement(ctx, entry), "AddAchievement"); err != nil {
+		return err
+	}
+	achievements, err := s.GetAchievements(ctx)
+	if err := firstError(expectNoError(err, "GetAchievements"), expectEqual(len(achievements), 1, "achievements")); err != nil {
+		return err
+	}
+	got, err := s.GetAchievementByTitle(ctx, "First")
+	if err := firstError(expectNoError(err, "GetAchievementByTitle"), expectEqual(got.Type, c.WordCount, "type")); err != nil {
+		return err
+	}
+	updated := &AchievementEntry{Title: "First", Type: c.NewWordCount, Value: "5", Description: "Find 5 new words", ImageName: "first.png"}
+	added := &AchievementEntry{Title: "Second", Type: c.WordCount, Value: "20", Description: "Find 20 words", ImageName: "second.png"}
+	if err := expectNoError(s.UpdateAchievements(ctx, []*AchievementEntry{updated, added}), "UpdateAchievements"); err != nil {
+		return err
+	}
+	achievements, err = s.GetAchievements(ctx)
+	if err := firstError(expectNoError(err, "GetAchievements"), expectEqual(len(achievements), 2, "updated and added achievement")); err != nil {
+		return err
+	}
+	got, err = s.GetAchievementByTitle(ctx, "First")
+	if err := firstError(expectNoError(err, "GetAchievementByTitle"), expectEqual(got.Type, c.NewWordCount, "updated type"), expectEqual(got.Value, "5", "updated value")); err != nil {
+		return err
+	}
+	_, err = s.GetAchievementByTitle(ctx, "Missing")
+	if err := expectError(err, "GetAchievementByTitle missing"); err != nil {
+		return err
+	}
+	unlocked, err := s.UnlockAchievement(ctx, "alice", "First")
+	if err := firstError(expectNoError(err, "UnlockAchievement"), expectEqual(unlocked, true, "newly unlocked")); err != nil {
+		return err
+	}
+	unlocked, err = s.UnlockAchievement(ctx, "alice", "First")
+	if err := firstError(expectNoError(err, "UnlockAchievement again"), expectEqual(unlocked, false, "already unlocked")); err != nil {
+		return err
+	}
+	titles, err := s.GetAchievementsForUser(ctx, "alice")
+	if err := firstError(expectNoError(err, "GetAchievementsForUser"), expectEqual(titles, []string{"First"}, "unlocked titles")); err != nil {
+		return err
+	}
+	unlocks, err := s.GetUnlocked(ctx)
+	if err := firstError(expectNoError(err, "GetUnlocked"), expectEqual(len(unlocks), 1, "unlocks")); err != nil {
+		return err
+	}
+	if err := expectNoError(s.AddAchievementImage(ctx, []byte{1}, "first.png"), "AddAchievementImage"); err != nil {
+		return err
+	}
+	data, err := s.GetAchievementImage(ctx, "first.png")
+	if err := firstError(expectNoError(err, "GetAchievementImage"), expectEqual(data, []byte{1}, "achievement image")); err != nil {
+		return err
+	}
+	images, err := s.GetAchievementImages(ctx)
+	if err := firstError(expectNoError(err, "GetAchievementImages"), expectEqual(len(images), 1, "achievement images")); err != nil {
+		return err
+	}
+	_, err = s.GetAchievementImage(ctx, "missing.png")
+	return expectError(err, "GetAchievementImage missing")
+}
+
+func conformSessions(ctx context.Context, s Storage) error {
+	now := time.Now().UTC().Truncate(time.Second)
+	session := &Session{ID: "s1", RefreshToken: "hash", Username: "alice", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	err := firstError(
+		expectNoError(s.CreateSession(ctx, session), "CreateSession"),
+		expectError(s.CreateSession(ctx, session), "CreateSession duplicate"),
+		expectNoError(s.CreateSession(ctx, &Session{ID: "s2", Username: "alice", CreatedAt: now, ExpiresAt: now}), "CreateSession"),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = s.GetSession(ctx, "missing")
+	if err := expectError(err, "GetSession missing"); err != nil {
+		return err
+	}
+	got, err := s.GetSession(ctx, "s1")
+	err = firstError(
+		expectNoError(err, "GetSession"),
+		expectEqual(got.RefreshToken, "hash", "refresh token"),
+		expectEqual(got.ExpiresAt.Equal(session.ExpiresAt), true, "expiry"),
+	)
+	if err != nil {
+		return err
+	}
+	revoked, err := s.RevokeSession(ctx, "s1", c.LOGGED_OUT)
+	if err := firstError(expectNoError(err, "RevokeSession"), expectEqual(revoked, true, "revoked active session")); err != nil {
+		return err
+	}
+	revoked, err = s.RevokeSession(ctx, "s1", c.ROTATED)
+	if err := firstError(expectNoError(err, "RevokeSession again"), expectEqual(revoked, false, "revoked revoked session")); err != nil {
+		return err
+	}
+	revoked, err = s.RevokeSession(ctx, "missing", c.ROTATED)
+	if err := firstError(expectNoError(err, "RevokeSession missing"), expectEqual(revoked, false, "revoked missing session")); err != nil {
+		return err
+	}
+	got, err = s.GetSession(ctx, "s1")
+	err = firstError(
+		expectNoError(err, "GetSession"),
+		expectEqual(got.IsRevoked, true, "revoked"),
+		expectEqual(got.RevokedReason, c.LOGGED_OUT, "reason of the first revoke"),
+	)
+	if err != nil {
+		return err
+	}
+	if err := expectNoError(s.RevokeSessionsForUser(ctx, "alice", c.REUSED), "RevokeSessionsForUser"); err != nil {
+		return err
+	}
+	got, err = s.GetSession(ctx, "s2")
+	err = firstError(
+		expectNoError(err, "GetSession"),
+		expectEqual(got.IsRevoked, true, "revoked for user"),
+		expectEqual(got.RevokedReason, c.REUSED, "reason of revoked for user"),
+	)
+	if err != nil {
+		return err
+	}
+	got, err = s.GetSession(ctx, "s1")
+	return firstError(expectNoError(err, "GetSession"), expectEqual(got.RevokedReason, c.LOGGED_OUT, "reason kept by revoked for user"))
+}
+
+func conformGames(ctx context.Context, s Storage) error {
+	deadline := time.Now().UTC().Truncate(time.Second)
+	game := &GameState{LobbyCode: "L1", GameMode: c.WOMBO_COMBO, TargetWords: "steam,lava", WithTimer: true, Duration: 5, Deadline: deadline}
+	err := firstError(
+		expectNoError(s.SaveGame(ctx, game), "SaveGame"),
+		expectNoError(s.SaveGame(ctx, &GameState{LobbyCode: "L1", GameMode: c.WOMBO_COMBO, Winner: "alice", Deadline: deadline}), "SaveGame update"),
+		expectNoError(s.SaveGame(ctx, &GameState{LobbyCode: "L2", GameMode: c.VANILLA, Deadline: deadline}), "SaveGame"),
+	)
+	if err != nil {
+		return err
+	}
+	games, err := s.GetGames(ctx)
+	if err := firstError(expectNoError(err, "GetGames"), expectEqual(len(games), 2, "games")); err != nil {
+		return err
+	}
+	for _, g := range games {
+		if g.LobbyCode == "L1" && g.Winner != "alice" {
+			return fmt.Errorf("SaveGame update: got winner %q", g.Winner)
+		}
+	}
+	err = firstError(
+		expectNoError(s.DeleteGame(ctx, "L1"), "DeleteGame"),
+		expectNoError(s.DeleteGame(ctx, "L1"), "DeleteGame missing"),
+	)
+	if err != nil {
+		return err
+	}
+	games, err = s.GetGames(ctx)
+	return firstError(expectNoError(err, "GetGames"), expectEqual(len(games), 1, "games after delete"))
+}
+
+func conformWordUses(ctx context.Context, s Storage) error {
+	err := firstError(
+		expectNoError(s.SaveWordUses(ctx, []*WordUse{
+			{LobbyCode: "L1", PlayerName: "alice", Word: "fire", UsesLeft: 3},
+			{LobbyCode: "L1", PlayerName: "alice", Word: "water", UsesLeft: 2},
+			{LobbyCode: "L2", PlayerName: "bob", Word: "fire", UsesLeft: 1},
+		}), "SaveWordUses"),
+		expectNoError(s.SaveWordUses(ctx, []*WordUse{{LobbyCode: "L1", PlayerName: "alice", Word: "fire", UsesLeft: 0}}), "SaveWordUses overwrite"),
+	)
+	if err != nil {
+		return err
+	}
+	uses, err := s.GetWordUses(ctx, "L1")
+	if err := firstError(expectNoError(err, "GetWordUses"), expectEqual(len(uses), 2, "word uses of lobby")); err != nil {
+		return err
+	}
+	left := map[string]int{}
+	for _, use := range uses {
+		left[use.Word] = use.UsesLeft
+	}
+	err = firstError(
+		expectEqual(left, map[string]int{"fire": 0, "water": 2}, "uses left"),
+		expectNoError(s.DeleteWordUses(ctx, "L1"), "DeleteWordUses"),
+	)
+	if err != nil {
+		return err
+	}
+	uses, err = s.GetWordUses(ctx, "L1")
+	if err := firstError(expectNoError(err, "GetWordUses"), expectEqual(len(uses), 0, "word uses after delete")); err != nil {
+		return err
+	}
+	uses, err = s.GetWordUses(ctx, "L2")
+	return firstError(expectNoError(err, "GetWordUses"), expectEqual(len(uses), 1, "word uses of other lobby"))
+}
+
+func conformTransactions(ctx context.Context, s Storage) error {
+	errAbort := fmt.Errorf("abort")
+	err := s.WithTx(ctx, func(tx Storage) error {
+		if err := tx.CreateAccount(ctx, &Account{Username: "alice"}); err != nil {
+			return err
+		}
+		if _, err := tx.GetAccountByUsername(ctx, "alice"); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if err := expectEqual(err, errAbort, "WithTx error"); err != nil {
+		return err
+	}
+	_, err = s.GetAccountByUsername(ctx, "alice")
+	if err := expectError(err, "GetAccountByUsername after rollback"); err != nil {
+		return err
+	}
+	err = s.WithTx(ctx, func(tx Storage) error {
+		if err := tx.CreateAccount(ctx, &Account{Username: "alice"}); err != nil {
+			return err
+		}
+		// Nested transactions join the outer one
+		return tx.WithTx(ctx, func(inner Storage) error {
+			return inner.CreateAccount(ctx, &Account{Username: "bob"})
+		})
+	})
+	if err := expectNoError(err, "WithTx"); err != nil {
+		return err
+	}
+	_, err = s.GetAccountByUsername(ctx, "alice")
+	if err := expectNoError(err, "GetAccountByUsername after commit"); err != nil {
+		return err
+	}
+	_, err = s.GetAccountByUsername(ctx, "bob")
+	return expectNoError(err, "GetAccountByUsername after nested commit")
+}
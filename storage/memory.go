@@ -0,0 +1,1198 @@
+package storage
+
+// In-memory implementation of the storage interface, for tests and local experiments
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"maps"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	ae "github.com/na50r/wombo-combo-go-be/apierror"
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	dto "github.com/na50r/wombo-combo-go-be/dto"
+	u "github.com/na50r/wombo-combo-go-be/utility"
+)
+
+type combinationKey struct {
+	a string
+	b string
+}
+
+type wordUseKey struct {
+	lobbyCode  string
+	playerName string
+	word       string
+}
+
+// Rows are kept as values, so that callers can not modify stored data through returned pointers.
+// Slices keep the insertion order, which is the order SQL backends return rows in.
+type MemoryStore struct {
+	mu                sync.RWMutex
+	accounts          map[string]Account
+	players           []Player
+	lobbies           []Lobby
+	images            []Image
+	achievementImages map[string][]byte
+	combinations      map[combinationKey]Combination
+	words             map[string]Word
+	playerWords       []PlayerWord
+	dailyWords        map[string]string
+	dailyChallenges   []Challenger
+	sessions          map[string]Session
+	games             map[string]GameState
+	wordUses          map[wordUseKey]WordUse
+	achievements      []AchievementEntry
+	unlocked          []Unlocked
+	shared            table // Tables of a transaction that still belong to the store it started from
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		accounts:          make(map[string]Account),
+		achievementImages: make(map[string][]byte),
+		combinations:      make(map[combinationKey]Combination),
+		words:             make(map[string]Word),
+		dailyWords:        make(map[string]string),
+		sessions:          make(map[string]Session),
+		games:             make(map[string]GameState),
+		wordUses:          make(map[wordUseKey]WordUse),
+	}
+}
+
+// The in-memory schema is always up to date
+func (s *MemoryStore) Init() error {
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+func (s *MemoryStore) Migrate(version int) error {
+	return nil
+}
+
+func (s *MemoryStore) MigrationStatus() ([]MigrationStatus, error) {
+	return []MigrationStatus{}, nil
+}
+
+// table identifies one of the maps or slices of a MemoryStore
+type table uint
+
+const (
+	tableAccounts table = 1 << iota
+	tablePlayers
+	tableLobbies
+	tableImages
+	tableAchievementImages
+	tableCombinations
+	tableWords
+	tablePlayerWords
+	tableDailyWords
+	tableDailyChallenges
+	tableSessions
+	tableGames
+	tableWordUses
+	tableAchievements
+	tableUnlocked
+	allTables = tableUnlocked<<1 - 1
+)
+
+// tx shares every table with the store, a table is only copied once the transaction modifies it
+func (s *MemoryStore) tx() *MemoryStore {
+	return &MemoryStore{
+		accounts:          s.accounts,
+		players:           s.players,
+		lobbies:           s.lobbies,
+		images:            s.images,
+		achievementImages: s.achievementImages,
+		combinations:      s.combinations,
+		words:             s.words,
+		playerWords:       s.playerWords,
+		dailyWords:        s.dailyWords,
+		dailyChallenges:   s.dailyChallenges,
+		sessions:          s.sessions,
+		games:             s.games,
+		wordUses:          s.wordUses,
+		achievements:      s.achievements,
+		unlocked:          s.unlocked,
+		shared:            allTables,
+	}
+}
+
+// own copies a table that is still shared with the store the transaction started from.
+// Every method must call it before it modifies a table, including in place changes of slice elements.
+func (s *MemoryStore) own(t table) {
+	if s.shared&t == 0 {
+		return
+	}
+	s.shared &^= t
+	switch t {
+	case tableAccounts:
+		s.accounts = maps.Clone(s.accounts)
+	case tablePlayers:
+		s.players = slices.Clone(s.players)
+	case tableLobbies:
+		s.lobbies = slices.Clone(s.lobbies)
+	case tableImages:
+		s.images = slices.Clone(s.images)
+	case tableAchievementImages:
+		s.achievementImages = maps.Clone(s.achievementImages)
+	case tableCombinations:
+		s.combinations = maps.Clone(s.combinations)
+	case tableWords:
+		s.words = maps.Clone(s.words)
+	case tablePlayerWords:
+		s.playerWords = slices.Clone(s.playerWords)
+	case tableDailyWords:
+		s.dailyWords = maps.Clone(s.dailyWords)
+	case tableDailyChallenges:
+		s.dailyChallenges = slices.Clone(s.dailyChallenges)
+	case tableSessions:
+		s.sessions = maps.Clone(s.sessions)
+	case tableGames:
+		s.games = maps.Clone(s.games)
+	case tableWordUses:
+		s.wordUses = maps.Clone(s.wordUses)
+	case tableAchievements:
+		s.achievements = slices.Clone(s.achievements)
+	case tableUnlocked:
+		s.unlocked = slices.Clone(s.unlocked)
+	}
+}
+
+// WithTx runs fn on a copy-on-write view and keeps its changes only if fn succeeds.
+// The store stays locked meanwhile, so fn must only use the store it is given.
+func (s *MemoryStore) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx := s.tx()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	s.accounts = tx.accounts
+	s.players = tx.players
+	s.lobbies = tx.lobbies
+	s.images = tx.images
+	s.achievementImages = tx.achievementImages
+	s.combinations = tx.combinations
+	s.words = tx.words
+	s.playerWords = tx.playerWords
+	s.dailyWords = tx.dailyWords
+	s.dailyChallenges = tx.dailyChallenges
+	s.sessions = tx.sessions
+	s.games = tx.games
+	s.wordUses = tx.wordUses
+	s.achievements = tx.achievements
+	s.unlocked = tx.unlocked
+	// Tables the transaction copied are no longer shared with an outer transaction either
+	s.shared &= tx.shared
+	return nil
+}
+
+func today() string {
+	return time.Now().Format("2006-01-02")
+}
+
+func (s *MemoryStore) playerIndex(name, lobbyCode string) int {
+	for i, player := range s.players {
+		if player.Name == name && player.LobbyCode == lobbyCode {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryStore) lobbyIndex(lobbyCode string) int {
+	for i, lobby := range s.lobbies {
+		if lobby.LobbyCode == lobbyCode {
+			return i
+		}
+	}
+	return -1
+}
+
+// filterPlayers removes the players for which keep returns false
+func (s *MemoryStore) filterPlayers(keep func(player Player) bool) {
+	players := s.players[:0]
+	for _, player := range s.players {
+		if keep(player) {
+			players = append(players, player)
+		}
+	}
+	s.players = players
+}
+
+func (s *MemoryStore) filterPlayerWords(keep func(playerWord PlayerWord) bool) {
+	playerWords := s.playerWords[:0]
+	for _, playerWord := range s.playerWords {
+		if keep(playerWord) {
+			playerWords = append(playerWords, playerWord)
+		}
+	}
+	s.playerWords = playerWords
+}
+
+func (s *MemoryStore) UnlockAchievement(ctx context.Context, username, achievementTitle string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableUnlocked)
+	key := Unlocked{Username: username, AchievmentTitle: achievementTitle}
+	for _, unlocked := range s.unlocked {
+		if unlocked == key {
+			return false, nil
+		}
+	}
+	s.unlocked = append(s.unlocked, key)
+	return true, nil
+}
+
+func (s *MemoryStore) AddAchievement(ctx context.Context, entry *AchievementEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableAchievements)
+	added := *entry
+	added.ID = len(s.achievements) + 1
+	s.achievements = append(s.achievements, added)
+	return nil
+}
+
+func (s *MemoryStore) UpdateAchievements(ctx context.Context, entries []*AchievementEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableAchievements)
+	for _, entry := range entries {
+		found := false
+		for i := range s.achievements {
+			if s.achievements[i].Title == entry.Title {
+				updated := *entry
+				updated.ID = s.achievements[i].ID
+				s.achievements[i] = updated
+				found = true
+			}
+		}
+		if !found {
+			added := *entry
+			added.ID = len(s.achievements) + 1
+			s.achievements = append(s.achievements, added)
+		}
+	}
+	return nil
+}
+
+// Only the lowest word count of the day is kept
+func (s *MemoryStore) AddDailyChallengeEntry(ctx context.Context, wordCount int, username string) error {
+	return s.AddChallengeEntry(ctx, &Challenger{Timestamp: time.Now(), WordCount: wordCount, Username: username})
+}
+
+// Only the lowest word count of a user per day is kept
+func (s *MemoryStore) AddChallengeEntry(ctx context.Context, added *Challenger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableDailyChallenges)
+	date, _ := time.Parse("2006-01-02", added.Timestamp.Format("2006-01-02"))
+	for i, entry := range s.dailyChallenges {
+		if entry.Username == added.Username && entry.Timestamp.Equal(date) {
+			if entry.WordCount > added.WordCount {
+				s.dailyChallenges[i].WordCount = added.WordCount
+			}
+			return nil
+		}
+	}
+	s.dailyChallenges = append(s.dailyChallenges, Challenger{Timestamp: date, WordCount: added.WordCount, Username: added.Username})
+	return nil
+}
+
+func (s *MemoryStore) CreateOrGetDailyWord(ctx context.Context, minReachability, maxReachability float64, maxDepth int) (string, error) {
+	log.Println("Creating or getting daily word")
+	s.mu.RLock()
+	word, ok := s.dailyWords[today()]
+	s.mu.RUnlock()
+	if ok {
+		return word, nil
+	}
+	word, err := s.GetTargetWord(ctx, minReachability, maxReachability, maxDepth)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableDailyWords)
+	// Another request may have picked the word in the meantime
+	if existing, ok := s.dailyWords[today()]; ok {
+		return existing, nil
+	}
+	s.dailyWords[today()] = word
+	return word, nil
+}
+
+func (s *MemoryStore) GetDailyWords(ctx context.Context) ([]*DailyWord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	days := slices.Sorted(maps.Keys(s.dailyWords))
+	dailyWords := []*DailyWord{}
+	for _, day := range days {
+		date, _ := time.Parse("2006-01-02", day)
+		dailyWords = append(dailyWords, &DailyWord{Timestamp: date, Word: s.dailyWords[day]})
+	}
+	return dailyWords, nil
+}
+
+func (s *MemoryStore) AddDailyWord(ctx context.Context, daily *DailyWord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableDailyWords)
+	day := daily.Timestamp.Format("2006-01-02")
+	if _, ok := s.dailyWords[day]; !ok {
+		s.dailyWords[day] = daily.Word
+	}
+	return nil
+}
+
+func (s *MemoryStore) CreateAccount(ctx context.Context, acc *Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableAccounts)
+	if _, ok := s.accounts[acc.Username]; ok {
+		return ae.Conflictf("account %s already exists", acc.Username)
+	}
+	s.accounts[acc.Username] = *acc
+	return nil
+}
+
+func (s *MemoryStore) GetAccounts(ctx context.Context) ([]*Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	accounts := []*Account{}
+	for _, username := range slices.Sorted(maps.Keys(s.accounts)) {
+		acc := s.accounts[username]
+		accounts = append(accounts, &acc)
+	}
+	return accounts, nil
+}
+
+func (s *MemoryStore) CreatePlayer(ctx context.Context, player *Player) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tablePlayers)
+	if s.playerIndex(player.Name, player.LobbyCode) >= 0 {
+		return ae.Conflictf("player %s already exists", player.Name)
+	}
+	s.players = append(s.players, *player)
+	return nil
+}
+
+func (s *MemoryStore) CreateLobby(ctx context.Context, lobby *Lobby) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableLobbies)
+	if s.lobbyIndex(lobby.LobbyCode) >= 0 {
+		return ae.Conflictf("lobby %s already exists", lobby.LobbyCode)
+	}
+	s.lobbies = append(s.lobbies, *lobby)
+	return nil
+}
+
+func (s *MemoryStore) GetPlayerByLobbyCodeAndName(ctx context.Context, name, lobbyCode string) (*Player, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i := s.playerIndex(name, lobbyCode)
+	if i < 0 {
+		return nil, ae.NotFoundf("player %s not found", name)
+	}
+	player := s.players[i]
+	return &player, nil
+}
+
+func (s *MemoryStore) DeletePlayer(ctx context.Context, name, lobbyCode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tablePlayers)
+	s.filterPlayers(func(player Player) bool {
+		return player.Name != name || player.LobbyCode != lobbyCode
+	})
+	return nil
+}
+
+func (s *MemoryStore) GetPlayersByLobbyCode(ctx context.Context, lobbyCode string) ([]*Player, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	players := []*Player{}
+	for _, player := range s.players {
+		if player.LobbyCode == lobbyCode {
+			p := player
+			players = append(players, &p)
+		}
+	}
+	return players, nil
+}
+
+func (s *MemoryStore) GetAccountByUsername(ctx context.Context, username string) (*Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	acc, ok := s.accounts[username]
+	if !ok {
+		return nil, ae.NotFoundf("account %s not found", username)
+	}
+	return &acc, nil
+}
+
+// Like the SQL backends, the creation time and owner flag are not updated
+func (s *MemoryStore) UpdateAccount(ctx context.Context, acc *Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableAccounts)
+	stored, ok := s.accounts[acc.Username]
+	if !ok {
+		return nil
+	}
+	stored.ImageName = acc.ImageName
+	stored.Password = acc.Password
+	stored.Wins = acc.Wins
+	stored.Losses = acc.Losses
+	stored.Status = acc.Status
+	stored.NewWordCount = acc.NewWordCount
+	stored.WordCount = acc.WordCount
+	s.accounts[acc.Username] = stored
+	return nil
+}
+
+func (s *MemoryStore) AddImage(ctx context.Context, data []byte, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableImages)
+	// Insert or replace: a replaced image moves to the end, just like a new row
+	images := s.images[:0]
+	for _, image := range s.images {
+		if image.Name != name {
+			images = append(images, image)
+		}
+	}
+	s.images = append(images, Image{Name: name, Data: data})
+	return nil
+}
+
+func (s *MemoryStore) GetImage(ctx context.Context, name string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, image := range s.images {
+		if image.Name == name {
+			return image.Data, nil
+		}
+	}
+	return nil, ae.NotFoundf("Image for name %s not found", name)
+}
+
+func (s *MemoryStore) GetImages(ctx context.Context) ([]*Image, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	images := []*Image{}
+	for _, image := range s.images {
+		img := image
+		images = append(images, &img)
+	}
+	return images, nil
+}
+
+func (s *MemoryStore) NewImageForUsername(ctx context.Context, username string) string {
+	images, err := s.GetImages(ctx)
+	if err != nil {
+		return err.Error()
+	}
+	if len(images) == 0 {
+		return ""
+	}
+	hash := u.RadixHash(username, len(images))
+	return images[hash].Name
+}
+
+func (s *MemoryStore) GetPlayerForAccount(ctx context.Context, username string) (*Player, error) {
+	acc, err := s.GetAccountByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return NewPlayer(username, "", acc.ImageName, false, true, acc.NewWordCount, acc.WordCount), nil
+}
+
+func (s *MemoryStore) GetLobbyForOwner(ctx context.Context, owner string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lobbyCodes := []string{}
+	for _, player := range s.players {
+		if player.Name == owner && player.IsOwner {
+			lobbyCodes = append(lobbyCodes, player.LobbyCode)
+		}
+	}
+	if len(lobbyCodes) > 1 {
+		return "", fmt.Errorf("Multiple lobbies for owner")
+	}
+	if len(lobbyCodes) == 0 {
+		log.Printf("No lobby found for owner %s", owner)
+		return "", nil
+	}
+	return lobbyCodes[0], nil
+}
+
+func (s *MemoryStore) DeletePlayersForLobby(ctx context.Context, lobbyCode string) error {
+	s.mu.Lock()
+	s.own(tablePlayers)
+	s.filterPlayers(func(player Player) bool {
+		return player.LobbyCode != lobbyCode
+	})
+	s.mu.Unlock()
+	return s.IncrementPlayerCount(ctx, lobbyCode, -1)
+}
+
+func (s *MemoryStore) AddPlayerToLobby(ctx context.Context, lobbyCode string, player *Player) error {
+	added := *player
+	added.LobbyCode = lobbyCode
+	if err := s.CreatePlayer(ctx, &added); err != nil {
+		return err
+	}
+	return s.IncrementPlayerCount(ctx, lobbyCode, 1)
+}
+
+func (s *MemoryStore) IncrementPlayerCount(ctx context.Context, lobbyCode string, increment int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableLobbies)
+	if i := s.lobbyIndex(lobbyCode); i >= 0 {
+		s.lobbies[i].PlayerCount += increment
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetLobbies(ctx context.Context) ([]*Lobby, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lobbies := []*Lobby{}
+	for _, lobby := range s.lobbies {
+		l := lobby
+		lobbies = append(lobbies, &l)
+	}
+	return lobbies, nil
+}
+
+func (s *MemoryStore) DeleteLobby(ctx context.Context, lobbyCode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableLobbies)
+	if i := s.lobbyIndex(lobbyCode); i >= 0 {
+		s.lobbies = append(s.lobbies[:i], s.lobbies[i+1:]...)
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetLobbyByCode(ctx context.Context, lobbyCode string) (*Lobby, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i := s.lobbyIndex(lobbyCode)
+	if i < 0 {
+		return nil, ae.NotFoundf("lobby %s not found", lobbyCode)
+	}
+	lobby := s.lobbies[i]
+	return &lobby, nil
+}
+
+func (s *MemoryStore) EditGameMode(ctx context.Context, lobbyCode string, gameMode c.GameMode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableLobbies)
+	if i := s.lobbyIndex(lobbyCode); i >= 0 {
+		s.lobbies[i].GameMode = gameMode
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetCombination(ctx context.Context, a, b string) (*string, bool, error) {
+	a, b = u.SortAB(a, b)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	combi, ok := s.combinations[combinationKey{a, b}]
+	if !ok {
+		return nil, false, nil
+	}
+	result := combi.Result
+	return &result, true, nil
+}
+
+func (s *MemoryStore) GetCombinations(ctx context.Context) ([]*Combination, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	combinations := []*Combination{}
+	for _, combi := range s.combinations {
+		combination := combi
+		combinations = append(combinations, &combination)
+	}
+	return combinations, nil
+}
+
+// Insert or ignore: the first result of a combination wins
+func (s *MemoryStore) AddCombination(ctx context.Context, combi *Combination) error {
+	a, b := u.SortAB(combi.A, combi.B)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableCombinations)
+	key := combinationKey{a, b}
+	if _, ok := s.combinations[key]; ok {
+		return nil
+	}
+	s.combinations[key] = Combination{A: a, B: b, Result: combi.Result, Depth: combi.Depth}
+	return nil
+}
+
+// The result is one level deeper than its deepest ingredient, both ingredients have to be known words
+func (s *MemoryStore) AddNewCombination(ctx context.Context, a, b, result string) error {
+	a, b = u.SortAB(a, b)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableCombinations)
+	s.own(tableWords)
+	aWord, ok := s.words[a]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	bWord, ok := s.words[b]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	depth := max(aWord.Depth, bWord.Depth) + 1
+	key := combinationKey{a, b}
+	if _, ok := s.combinations[key]; !ok {
+		s.combinations[key] = Combination{A: a, B: b, Result: result, Depth: depth}
+	}
+	if _, ok := s.words[result]; !ok {
+		s.words[result] = Word{Word: result, Depth: depth, Reachability: 1.0 / float64(int(1)<<uint(depth))}
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetWords(ctx context.Context) ([]*Word, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	words := []*Word{}
+	for _, word := range s.words {
+		w := word
+		words = append(words, &w)
+	}
+	return words, nil
+}
+
+func (s *MemoryStore) UpdateCombinations(ctx context.Context, combinations []*Combination) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableCombinations)
+	for _, combi := range combinations {
+		a, b := u.SortAB(combi.A, combi.B)
+		s.combinations[combinationKey{a, b}] = Combination{A: a, B: b, Result: combi.Result, Depth: combi.Depth}
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdateWords(ctx context.Context, words []*Word) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableWords)
+	for _, word := range words {
+		w := strings.ToLower(word.Word)
+		s.words[w] = Word{Word: w, Depth: word.Depth, Reachability: word.Reachability}
+	}
+	return nil
+}
+
+func (s *MemoryStore) AddWord(ctx context.Context, word *Word) error {
+	w := strings.ToLower(word.Word)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableWords)
+	if _, ok := s.words[w]; ok {
+		return nil
+	}
+	s.words[w] = Word{Word: w, Depth: word.Depth, Reachability: word.Reachability}
+	return nil
+}
+
+func (s *MemoryStore) GetTargetWords(ctx context.Context, minReachability, maxReachability float64, maxDepth int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	targetWords := []string{}
+	for _, word := range s.words {
+		if word.Reachability >= minReachability && word.Reachability <= maxReachability && word.Depth <= maxDepth {
+			targetWords = append(targetWords, word.Word)
+		}
+	}
+	// Map order is random, sort to keep results reproducible
+	sort.Strings(targetWords)
+	log.Printf("Number of target words: %d", len(targetWords))
+	return targetWords, nil
+}
+
+func (s *MemoryStore) GetTargetWord(ctx context.Context, minReachability, maxReachability float64, maxDepth int) (string, error) {
+	targetWords, err := s.GetTargetWords(ctx, minReachability, maxReachability, maxDepth)
+	if err != nil {
+		return "", err
+	}
+	if len(targetWords) == 0 {
+		return "", fmt.Errorf("No target words found")
+	}
+	return targetWords[rand.Intn(len(targetWords))], nil
+}
+
+func (s *MemoryStore) AddPlayerWord(ctx context.Context, playerName, word, lobbyCode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tablePlayerWords)
+	for _, playerWord := range s.playerWords {
+		if playerWord.PlayerName == playerName && playerWord.Word == word && playerWord.LobbyCode == lobbyCode {
+			return nil
+		}
+	}
+	s.playerWords = append(s.playerWords, PlayerWord{
+		PlayerName: playerName,
+		Word:       word,
+		LobbyCode:  lobbyCode,
+		Timestamp:  time.Now().Format("2006-01-02 15:04:05"),
+	})
+	return nil
+}
+
+func (s *MemoryStore) IsPlayerWord(ctx context.Context, playerName, word, lobbyCode string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, playerWord := range s.playerWords {
+		if playerWord.PlayerName == playerName && playerWord.Word == word && playerWord.LobbyCode == lobbyCode {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *MemoryStore) SetPlayerTargetWord(ctx context.Context, playerName, targetWord, lobbyCode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tablePlayers)
+	if i := s.playerIndex(playerName, lobbyCode); i >= 0 {
+		s.players[i].TargetWord = targetWord
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetPlayerTargetWord(ctx context.Context, playerName, lobbyCode string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i := s.playerIndex(playerName, lobbyCode)
+	if i < 0 {
+		return "", sql.ErrNoRows
+	}
+	return s.players[i].TargetWord, nil
+}
+
+// Words in the order they were discovered
+func (s *MemoryStore) GetPlayerWords(ctx context.Context, playerName, lobbyCode string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	words := []string{}
+	for _, playerWord := range s.playerWords {
+		if playerWord.PlayerName == playerName && playerWord.LobbyCode == lobbyCode {
+			words = append(words, playerWord.Word)
+		}
+	}
+	return words, nil
+}
+
+func (s *MemoryStore) DeletePlayerWordsByLobbyCode(ctx context.Context, lobbyCode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tablePlayerWords)
+	s.filterPlayerWords(func(playerWord PlayerWord) bool {
+		return playerWord.LobbyCode != lobbyCode
+	})
+	return nil
+}
+
+func (s *MemoryStore) DeletePlayerWordsByPlayerAndLobbyCode(ctx context.Context, playerName, lobbyCode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tablePlayerWords)
+	s.filterPlayerWords(func(playerWord PlayerWord) bool {
+		return playerWord.PlayerName != playerName || playerWord.LobbyCode != lobbyCode
+	})
+	return nil
+}
+
+func (s *MemoryStore) GetWordCountByLobbyCode(ctx context.Context, lobbyCode string) ([]*dto.PlayerWordCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	counts := make(map[string]int)
+	players := []string{}
+	for _, playerWord := range s.playerWords {
+		if playerWord.LobbyCode != lobbyCode {
+			continue
+		}
+		if _, ok := counts[playerWord.PlayerName]; !ok {
+			players = append(players, playerWord.PlayerName)
+		}
+		counts[playerWord.PlayerName]++
+	}
+	wordCounts := []*dto.PlayerWordCount{}
+	for _, player := range players {
+		// Exclude starting words
+		wordCounts = append(wordCounts, &dto.PlayerWordCount{PlayerName: player, WordCount: counts[player] - 4})
+	}
+	sort.SliceStable(wordCounts, func(i, j int) bool {
+		return wordCounts[i].WordCount > wordCounts[j].WordCount
+	})
+	return wordCounts, nil
+}
+
+func (s *MemoryStore) UpdateAccountWinsAndLosses(ctx context.Context, lobbyCode, winner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableAccounts)
+	accounts := []Account{}
+	for _, player := range s.players {
+		if player.LobbyCode != lobbyCode || !player.HasAccount {
+			continue
+		}
+		acc, ok := s.accounts[player.Name]
+		if !ok {
+			return ae.NotFoundf("account %s not found", player.Name)
+		}
+		accounts = append(accounts, acc)
+	}
+	for _, acc := range accounts {
+		if acc.Username == winner {
+			acc.Wins++
+		} else {
+			acc.Losses++
+		}
+		s.accounts[acc.Username] = acc
+	}
+	return nil
+}
+
+func (s *MemoryStore) IncrementPlayerPoints(ctx context.Context, playerName, lobbyCode string, points int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tablePlayers)
+	if i := s.playerIndex(playerName, lobbyCode); i >= 0 {
+		s.players[i].Points += points
+	}
+	return nil
+}
+
+func (s *MemoryStore) ResetPlayerPoints(ctx context.Context, lobbyCode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tablePlayers)
+	for i := range s.players {
+		if s.players[i].LobbyCode == lobbyCode {
+			s.players[i].Points = 0
+		}
+	}
+	return nil
+}
+
+// An account can only own one lobby at a time
+func (s *MemoryStore) SetIsOwner(ctx context.Context, username string, setOwner bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableAccounts)
+	acc, ok := s.accounts[username]
+	if !setOwner {
+		if ok {
+			acc.IsOwner = false
+			s.accounts[username] = acc
+		}
+		return nil
+	}
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if acc.IsOwner {
+		return ae.Conflictf("User is already owner!")
+	}
+	acc.IsOwner = true
+	s.accounts[username] = acc
+	return nil
+}
+
+// The player with the most points wins, ties go to the player that joined first
+func (s *MemoryStore) SelectWinnerByPoints(ctx context.Context, lobbyCode string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	found := false
+	var winner Player
+	for _, player := range s.players {
+		if player.LobbyCode != lobbyCode {
+			continue
+		}
+		if !found || player.Points > winner.Points {
+			winner = player
+			found = true
+		}
+	}
+	if !found {
+		return "", ae.NotFoundf("No players found")
+	}
+	return winner.Name, nil
+}
+
+func (s *MemoryStore) DeleteAccount(ctx context.Context, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableAccounts)
+	delete(s.accounts, username)
+	return nil
+}
+
+func (s *MemoryStore) GetChallengeEntries(ctx context.Context) ([]*Challenger, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	date, _ := time.Parse("2006-01-02", today())
+	entries := []*Challenger{}
+	for _, entry := range s.dailyChallenges {
+		if entry.Timestamp.Equal(date) {
+			e := entry
+			entries = append(entries, &e)
+		}
+	}
+	return entries, nil
+}
+
+func (s *MemoryStore) GetAllChallengeEntries(ctx context.Context) ([]*Challenger, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries := []*Challenger{}
+	for _, entry := range s.dailyChallenges {
+		e := entry
+		entries = append(entries, &e)
+	}
+	return entries, nil
+}
+
+func (s *MemoryStore) GetImageByUsername(ctx context.Context, username string) ([]byte, error) {
+	s.mu.RLock()
+	acc, ok := s.accounts[username]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return s.GetImage(ctx, acc.ImageName)
+}
+
+func (s *MemoryStore) CreateSession(ctx context.Context, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableSessions)
+	if _, ok := s.sessions[session.ID]; ok {
+		return ae.Conflictf("session %s already exists", session.ID)
+	}
+	s.sessions[session.ID] = *session
+	return nil
+}
+
+func (s *MemoryStore) GetSession(ctx context.Context, id string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, ae.NotFoundf("session %s not found", id)
+	}
+	return &session, nil
+}
+
+func (s *MemoryStore) RevokeSession(ctx context.Context, id string, reason c.RevokeReason) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableSessions)
+	session, ok := s.sessions[id]
+	if !ok || session.IsRevoked {
+		return false, nil
+	}
+	session.IsRevoked = true
+	session.RevokedReason = reason
+	s.sessions[id] = session
+	return true, nil
+}
+
+func (s *MemoryStore) RevokeSessionsForUser(ctx context.Context, username string, reason c.RevokeReason) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableSessions)
+	for id, session := range s.sessions {
+		if session.Username == username && !session.IsRevoked {
+			session.IsRevoked = true
+			session.RevokedReason = reason
+			s.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) SaveGame(ctx context.Context, game *GameState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableGames)
+	s.games[game.LobbyCode] = *game
+	return nil
+}
+
+func (s *MemoryStore) GetGames(ctx context.Context) ([]*GameState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	games := []*GameState{}
+	for _, game := range s.games {
+		g := game
+		games = append(games, &g)
+	}
+	return games, nil
+}
+
+func (s *MemoryStore) DeleteGame(ctx context.Context, lobbyCode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableGames)
+	delete(s.games, lobbyCode)
+	return nil
+}
+
+func (s *MemoryStore) SaveWordUses(ctx context.Context, uses []*WordUse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableWordUses)
+	for _, use := range uses {
+		s.wordUses[wordUseKey{use.LobbyCode, use.PlayerName, use.Word}] = *use
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetWordUses(ctx context.Context, lobbyCode string) ([]*WordUse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	uses := []*WordUse{}
+	for _, use := range s.wordUses {
+		if use.LobbyCode == lobbyCode {
+			wu := use
+			uses = append(uses, &wu)
+		}
+	}
+	return uses, nil
+}
+
+func (s *MemoryStore) DeleteWordUses(ctx context.Context, lobbyCode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableWordUses)
+	for key := range s.wordUses {
+		if key.lobbyCode == lobbyCode {
+			delete(s.wordUses, key)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetAchievements(ctx context.Context) ([]*AchievementEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries := []*AchievementEntry{}
+	for _, entry := range s.achievements {
+		e := entry
+		entries = append(entries, &e)
+	}
+	return entries, nil
+}
+
+func (s *MemoryStore) UpdateAccountWordCount(ctx context.Context, username string, newWordCount, wordCount int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableAccounts)
+	if acc, ok := s.accounts[username]; ok {
+		acc.NewWordCount = newWordCount
+		acc.WordCount = wordCount
+		s.accounts[username] = acc
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdatePlayerWordCount(ctx context.Context, playerName, lobbyCode string, newWordCount, wordCount int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tablePlayers)
+	if i := s.playerIndex(playerName, lobbyCode); i >= 0 {
+		s.players[i].NewWordCount = newWordCount
+		s.players[i].WordCount = wordCount
+	}
+	return nil
+}
+
+func (s *MemoryStore) AddAchievementImage(ctx context.Context, data []byte, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.own(tableAchievementImages)
+	s.achievementImages[name] = data
+	return nil
+}
+
+func (s *MemoryStore) GetAchievementImage(ctx context.Context, name string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.achievementImages[name]
+	if !ok {
+		return nil, ae.NotFoundf("Image for name %s not found", name)
+	}
+	return data, nil
+}
+
+func (s *MemoryStore) GetAchievementImages(ctx context.Context) ([]*Image, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	images := []*Image{}
+	for _, name := range slices.Sorted(maps.Keys(s.achievementImages)) {
+		images = append(images, &Image{Name: name, Data: s.achievementImages[name]})
+	}
+	return images, nil
+}
+
+func (s *MemoryStore) GetAchievementByTitle(ctx context.Context, title string) (*AchievementEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, entry := range s.achievements {
+		if entry.Title == title {
+			e := entry
+			return &e, nil
+		}
+	}
+	return nil, ae.NotFoundf("Achievement %s not found", title)
+}
+
+func (s *MemoryStore) GetUnlocked(ctx context.Context) ([]*Unlocked, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	unlocked := []*Unlocked{}
+	for _, entry := range s.unlocked {
+		e := entry
+		unlocked = append(unlocked, &e)
+	}
+	return unlocked, nil
+}
+
+func (s *MemoryStore) GetAchievementsForUser(ctx context.Context, username string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	achievements := []string{}
+	for _, unlocked := range s.unlocked {
+		if unlocked.Username == username {
+			achievements = append(achievements, unlocked.AchievmentTitle)
+		}
+	}
+	return achievements, nil
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMemoryStoreWithTxRollsBack(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	if err := s.CreateLobby(ctx, NewLobby("lobby", "abc", "default.png")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreatePlayer(ctx, NewPlayer("alice", "abc", "default.png", true, false, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	failed := errors.New("failed")
+	err := s.WithTx(ctx, func(tx Storage) error {
+		// In place changes of slice elements must not reach the store either
+		if err := tx.IncrementPlayerPoints(ctx, "alice", "abc", 10); err != nil {
+			return err
+		}
+		if err := tx.DeletePlayersForLobby(ctx, "abc"); err != nil {
+			return err
+		}
+		if err := tx.AddPlayerWord(ctx, "alice", "fire", "abc"); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("WithTx returned %v, want %v", err, failed)
+	}
+	player, err := s.GetPlayerByLobbyCodeAndName(ctx, "alice", "abc")
+	if err != nil {
+		t.Fatalf("player of the rolled back transaction: %v", err)
+	}
+	if player.Points != 0 {
+		t.Fatalf("player has %d points after the rollback, want 0", player.Points)
+	}
+	lobby, err := s.GetLobbyByCode(ctx, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lobby.PlayerCount != 1 {
+		t.Fatalf("lobby has %d players after the rollback, want 1", lobby.PlayerCount)
+	}
+	if owned, _ := s.IsPlayerWord(ctx, "alice", "fire", "abc"); owned {
+		t.Fatal("word of the rolled back transaction was kept")
+	}
+}
+
+func TestMemoryStoreWithTxCopiesOnWrite(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	if err := s.AddWord(ctx, &Word{Word: "fire", Reachability: 1}); err != nil {
+		t.Fatal(err)
+	}
+	err := s.WithTx(ctx, func(tx Storage) error {
+		mem := tx.(*MemoryStore)
+		if mem.shared != allTables {
+			t.Fatalf("transaction starts with shared tables %b, want all", mem.shared)
+		}
+		if err := tx.SaveGame(ctx, &GameState{LobbyCode: "abc"}); err != nil {
+			return err
+		}
+		if mem.shared != allTables&^tableGames {
+			t.Fatalf("shared tables %b after saving a game, only games should be copied", mem.shared)
+		}
+		// A nested transaction joins the changes of the outer one
+		return tx.WithTx(ctx, func(nested Storage) error {
+			return nested.AddWord(ctx, &Word{Word: "water", Reachability: 1})
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	games, err := s.GetGames(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	words, err := s.GetWords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 1 || len(words) != 2 {
+		t.Fatalf("store has %d games and %d words after the commit, want 1 and 2", len(games), len(words))
+	}
+	if s.shared != 0 {
+		t.Fatalf("store has shared tables %b, want none", s.shared)
+	}
+}
@@ -0,0 +1,173 @@
+package storage
+
+// Versioned schema migrations, shared by the SQL backends
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Migrate to the newest version known to the binary
+const LatestVersion = -1
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+	Down    func(tx *sql.Tx) error
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time // nil if the migration is pending
+}
+
+// statements runs the queries in order
+func statements(queries ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, query := range queries {
+			if _, err := tx.Exec(query); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+type migrator struct {
+	db         *sql.DB
+	migrations []Migration // ordered by version, starting at 1
+	bind       func(n int) string
+}
+
+func (m *migrator) latest() int {
+	return len(m.migrations)
+}
+
+func (m *migrator) createVersionTable() error {
+	query := `create table if not exists schema_version (
+		version integer primary key,
+		name text,
+		applied_at timestamp
+		)`
+	_, err := m.db.Exec(query)
+	return err
+}
+
+func (m *migrator) applied() (map[int]time.Time, error) {
+	if err := m.createVersionTable(); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.Query("select version, applied_at from schema_version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func (m *migrator) version() (int, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for v := range applied {
+		version = max(version, v)
+	}
+	return version, nil
+}
+
+func (m *migrator) status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	status := []MigrationStatus{}
+	for _, migration := range m.migrations {
+		entry := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			entry.AppliedAt = &appliedAt
+		}
+		status = append(status, entry)
+	}
+	return status, nil
+}
+
+// check refuses a schema that is behind or ahead of the binary
+func (m *migrator) check() error {
+	version, err := m.version()
+	if err != nil {
+		return err
+	}
+	if version < m.latest() {
+		return fmt.Errorf("Schema is at version %d, expected %d, run with --migrate", version, m.latest())
+	}
+	if version > m.latest() {
+		return fmt.Errorf("Schema is at version %d, which is newer than this build (%d)", version, m.latest())
+	}
+	return nil
+}
+
+// migrate applies up or down migrations, each in its own transaction, until the schema is at target
+func (m *migrator) migrate(target int) error {
+	if target == LatestVersion {
+		target = m.latest()
+	}
+	if target < 0 || target > m.latest() {
+		return fmt.Errorf("Version %d not found, latest is %d", target, m.latest())
+	}
+	version, err := m.version()
+	if err != nil {
+		return err
+	}
+	for version < target {
+		migration := m.migrations[version]
+		log.Printf("Applying migration %d: %s", migration.Version, migration.Name)
+		insert := fmt.Sprintf("insert into schema_version (version, name, applied_at) values (%s, %s, %s)", m.bind(1), m.bind(2), m.bind(3))
+		err := m.inTx(migration.Up, insert, migration.Version, migration.Name, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("Migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+		version++
+	}
+	for version > target {
+		migration := m.migrations[version-1]
+		log.Printf("Reverting migration %d: %s", migration.Version, migration.Name)
+		remove := fmt.Sprintf("delete from schema_version where version = %s", m.bind(1))
+		err := m.inTx(migration.Down, remove, migration.Version)
+		if err != nil {
+			return fmt.Errorf("Reverting migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+		version--
+	}
+	return nil
+}
+
+func (m *migrator) inTx(step func(tx *sql.Tx) error, query string, args ...any) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := step(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,180 @@
+package storage
+
+// Schema migrations of the Postgres backend, append new migrations at the end
+
+var postgresMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		// Tables may exist already, if the database was created before migrations were introduced
+		Up: statements(
+			`create table if not exists account (
+				username varchar(100) primary key,
+				image_name varchar(100),
+				password varchar(100),
+				wins integer,
+				losses integer,
+				created_at timestamp,
+				status text,
+				is_owner boolean
+				)`,
+			`create table if not exists image (
+				name varchar(100) primary key,
+				data bytea
+				)`,
+			`create table if not exists player (
+				name varchar(100),
+				lobby_code varchar(100),
+				image_name varchar(100),
+				is_owner boolean,
+				has_account boolean,
+				target_word varchar(100),
+				points integer,
+				primary key (name, lobby_code)
+				)`,
+			`create table if not exists lobby (
+				name varchar(100),
+				image_name varchar(100),
+				lobby_code varchar(100),
+				game_mode text,
+				player_count integer,
+				primary key (lobby_code)
+				)`,
+			`create table if not exists combination (
+				a varchar(100),
+				b varchar(100),
+				result varchar(100),
+				depth integer,
+				primary key (a, b)
+				)`,
+			`create table if not exists word (
+				word varchar(100) primary key,
+				depth integer,
+				reachability float
+				)`,
+			`create table if not exists player_word (
+				player_name varchar(100),
+				word varchar(100),
+				lobby_code varchar(100),
+				timestamp timestamp default current_timestamp,
+				primary key (player_name, word, lobby_code)
+				)`,
+			`create table if not exists daily_word (
+				timestamp timestamp default current_timestamp,
+				word varchar(100)
+				)`,
+			`create table if not exists daily_challenge (
+				timestamp timestamp default current_timestamp,
+				word_count integer,
+				username varchar(100)
+				)`,
+			`create table if not exists session (
+				id varchar(100) primary key,
+				refresh_token varchar(100),
+				username varchar(100),
+				is_revoked boolean,
+				created_at timestamp,
+				expires_at timestamp
+				)`,
+			`create table if not exists achievement (
+				id serial primary key,
+				title varchar(100),
+				type text,
+				value text,
+				description text,
+				image_name varchar(100)
+				)`,
+			`create table if not exists unlocked (
+				username varchar(100),
+				achievement_title varchar(100),
+				primary key (username, achievement_title)
+				)`,
+			`create table if not exists achievement_image (
+				name varchar(100) primary key,
+				data bytea
+				)`,
+		),
+		Down: statements(
+			"drop table if exists achievement_image",
+			"drop table if exists unlocked",
+			"drop table if exists achievement",
+			"drop table if exists session",
+			"drop table if exists daily_challenge",
+			"drop table if exists daily_word",
+			"drop table if exists player_word",
+			"drop table if exists word",
+			"drop table if exists combination",
+			"drop table if exists lobby",
+			"drop table if exists player",
+			"drop table if exists image",
+			"drop table if exists account",
+		),
+	},
+	{
+		Version: 2,
+		Name:    "word counts of accounts and players",
+		// Queries select *, so the columns are added in the order of the scanIntoX helpers
+		Up: statements(
+			"alter table account add column if not exists new_word_count integer",
+			"alter table account add column if not exists word_count integer",
+			"alter table player add column if not exists word_count integer",
+			"alter table player add column if not exists new_word_count integer",
+		),
+		Down: statements(
+			"alter table account drop column if exists new_word_count",
+			"alter table account drop column if exists word_count",
+			"alter table player drop column if exists new_word_count",
+			"alter table player drop column if exists word_count",
+		),
+	},
+	{
+		Version: 3,
+		Name:    "running games",
+		Up: statements(
+			`create table if not exists game (
+				lobby_code varchar(100) primary key,
+				game_mode varchar(100),
+				target_word varchar(100),
+				target_words text,
+				with_timer boolean,
+				duration integer,
+				deadline timestamp,
+				winner varchar(100),
+				manual_end boolean,
+				word_uses integer
+				)`,
+		),
+		Down: statements("drop table if exists game"),
+	},
+	{
+		Version: 4,
+		Name:    "remaining word uses",
+		Up: statements(
+			`create table if not exists word_use (
+				lobby_code varchar(100),
+				player_name varchar(100),
+				word varchar(100),
+				uses_left integer,
+				primary key (lobby_code, player_name, word)
+				)`,
+		),
+		Down: statements("drop table if exists word_use"),
+	},
+	{
+		Version: 5,
+		Name:    "reasons of revoked sessions",
+		// Sessions revoked by older builds count as rotated, their refresh tokens were treated as reused
+		Up: statements(
+			"alter table session add column if not exists revoked_reason varchar(100) not null default ''",
+			"update session set revoked_reason = 'ROTATED' where is_revoked and revoked_reason = ''",
+		),
+		Down: statements("alter table session drop column if exists revoked_reason"),
+	},
+	{
+		Version: 6,
+		Name:    "event ids",
+		// IDs of the events exchanged by the backplane
+		Up:   statements("create sequence if not exists event_id"),
+		Down: statements("drop sequence if exists event_id"),
+	},
+}
@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	ae "github.com/na50r/wombo-combo-go-be/apierror"
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	u "github.com/na50r/wombo-combo-go-be/utility"
+)
+
+// Files read by --seed
+type SeedConfig struct {
+	Words            string
+	Combinations     string
+	Icons            string
+	Achievements     string
+	AchievementIcons string
+}
+
+type SeedCategory string
+
+const (
+	SeedIcons        SeedCategory = "icons"
+	SeedCombinations SeedCategory = "combinations"
+	SeedWords        SeedCategory = "words"
+	SeedAchievements SeedCategory = "achievements" // Achievements and their icons
+)
+
+var SeedCategories = []SeedCategory{SeedIcons, SeedCombinations, SeedWords, SeedAchievements}
+
+// ParseSeedCategories reads a comma separated list, an empty list is every category
+func ParseSeedCategories(list string) ([]SeedCategory, error) {
+	if strings.TrimSpace(list) == "" {
+		return SeedCategories, nil
+	}
+	categories := []SeedCategory{}
+	for _, name := range strings.Split(list, ",") {
+		category := SeedCategory(strings.ToLower(strings.TrimSpace(name)))
+		found := false
+		for _, known := range SeedCategories {
+			found = found || known == category
+		}
+		if !found {
+			return nil, fmt.Errorf("Unknown seed category %q, expected one of %v", name, SeedCategories)
+		}
+		categories = append(categories, category)
+	}
+	return categories, nil
+}
+
+// Rows of one table: inserted are new, updated differed from the stored row and skipped were already stored
+type SeedResult struct {
+	Table    string
+	Inserted int
+	Updated  int
+	Skipped  int
+}
+
+// SeedDB writes the seed files into the store. Rows are compared with the stored ones and only new or changed rows
+// are written, batchSize rows per transaction. A run that was interrupted keeps the batches it committed, running it
+// again continues with the rest. The results of the finished tables are returned with the error.
+func SeedDB(ctx context.Context, store Storage, config SeedConfig, categories []SeedCategory, batchSize int) ([]*SeedResult, error) {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	results := []*SeedResult{}
+	for _, category := range categories {
+		var seeded []*SeedResult
+		var err error
+		switch category {
+		case SeedIcons:
+			seeded, err = seedImages(ctx, store, "icons", config.Icons, batchSize, Storage.GetImage, Storage.AddImage)
+		case SeedCombinations:
+			seeded, err = seedCombinations(ctx, store, config.Combinations, batchSize)
+		case SeedWords:
+			seeded, err = seedWords(ctx, store, config.Words, batchSize)
+		case SeedAchievements:
+			seeded, err = seedAchievements(ctx, store, config, batchSize)
+		default:
+			err = fmt.Errorf("Unknown seed category %q", category)
+		}
+		results = append(results, seeded...)
+		if err != nil {
+			return results, fmt.Errorf("seeding %s: %w", category, err)
+		}
+		log.Printf("Seeded %s", category)
+	}
+	return results, nil
+}
+
+func LogSeedResults(results []*SeedResult) {
+	for _, result := range results {
+		log.Printf("%-18s %6d inserted %6d updated %6d skipped", result.Table, result.Inserted, result.Updated, result.Skipped)
+	}
+}
+
+// inBatches calls write with consecutive slices of at most size rows
+func inBatches[T any](rows []T, size int, write func(batch []T) error) error {
+	for start := 0; start < len(rows); start += size {
+		end := min(start+size, len(rows))
+		if err := write(rows[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// get and add are method expressions, icons and achievement icons are stored in different tables
+func seedImages(ctx context.Context, store Storage, table, dirPath string, batchSize int, get func(s Storage, ctx context.Context, name string) ([]byte, error), add func(s Storage, ctx context.Context, data []byte, name string) error) ([]*SeedResult, error) {
+	images, err := u.ReadImages(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	result := &SeedResult{Table: table}
+	changed := []*Image{}
+	for name, data := range images {
+		stored, err := get(store, ctx, name)
+		switch {
+		case ae.Is(err, ae.NotFound):
+			result.Inserted++
+		case err != nil:
+			return nil, err
+		case bytes.Equal(stored, data):
+			result.Skipped++
+			continue
+		default:
+			result.Updated++
+		}
+		changed = append(changed, &Image{Name: name, Data: data})
+	}
+	err = inBatches(changed, batchSize, func(batch []*Image) error {
+		return store.WithTx(ctx, func(tx Storage) error {
+			for _, image := range batch {
+				if err := add(tx, ctx, image.Data, image.Name); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	return []*SeedResult{result}, err
+}
+
+// Format: Depth,A,B,C
+func seedCombinations(ctx context.Context, store Storage, combiPath string, batchSize int) ([]*SeedResult, error) {
+	records, err := u.ReadCSV(combiPath)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Number of combinations ", len(records))
+	combinations, err := store.GetCombinations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[[2]string]Combination, len(combinations))
+	for _, combi := range combinations {
+		stored[[2]string{combi.A, combi.B}] = *combi
+	}
+	result := &SeedResult{Table: "combinations"}
+	changed := []*Combination{}
+	for i, record := range records {
+		depth, err := strconv.Atoi(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: depth: %w", combiPath, i+2, err)
+		}
+		a, b := u.SortAB(strings.ToLower(record[1]), strings.ToLower(record[2]))
+		combi := Combination{A: a, B: b, Result: strings.ToLower(record[3]), Depth: depth}
+		key := [2]string{a, b}
+		old, ok := stored[key]
+		switch {
+		case !ok:
+			result.Inserted++
+		case old == combi:
+			result.Skipped++
+			continue
+		default:
+			result.Updated++
+		}
+		// Rows repeated in the file are skipped from now on
+		stored[key] = combi
+		changed = append(changed, &combi)
+	}
+	err = inBatches(changed, batchSize, func(batch []*Combination) error {
+		return store.UpdateCombinations(ctx, batch)
+	})
+	return []*SeedResult{result}, err
+}
+
+// Format: Element,Depth,Reachability
+func seedWords(ctx context.Context, store Storage, wordPath string, batchSize int) ([]*SeedResult, error) {
+	records, err := u.ReadCSV(wordPath)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Number of words ", len(records))
+	words, err := store.GetWords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]Word, len(words))
+	for _, word := range words {
+		stored[word.Word] = *word
+	}
+	result := &SeedResult{Table: "words"}
+	changed := []*Word{}
+	for i, record := range records {
+		word := Word{Word: strings.ToLower(record[0])}
+		if word.Depth, err = strconv.Atoi(record[1]); err != nil {
+			return nil, fmt.Errorf("%s line %d: depth: %w", wordPath, i+2, err)
+		}
+		if word.Reachability, err = strconv.ParseFloat(record[2], 64); err != nil {
+			return nil, fmt.Errorf("%s line %d: reachability: %w", wordPath, i+2, err)
+		}
+		old, ok := stored[word.Word]
+		switch {
+		case !ok:
+			result.Inserted++
+		case old == word:
+			result.Skipped++
+			continue
+		default:
+			result.Updated++
+		}
+		stored[word.Word] = word
+		changed = append(changed, &word)
+	}
+	err = inBatches(changed, batchSize, func(batch []*Word) error {
+		return store.UpdateWords(ctx, batch)
+	})
+	return []*SeedResult{result}, err
+}
+
+// Format: Title,Type,Value,Description,ImageName; the icons are seeded from their own directory
+func seedAchievements(ctx context.Context, store Storage, config SeedConfig, batchSize int) ([]*SeedResult, error) {
+	records, err := u.ReadCSV(config.Achievements)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Number of achievements ", len(records))
+	entries, err := store.GetAchievements(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]AchievementEntry, len(entries))
+	for _, entry := range entries {
+		stored[entry.Title] = *entry
+	}
+	result := &SeedResult{Table: "achievements"}
+	changed := []*AchievementEntry{}
+	for _, record := range records {
+		entry := AchievementEntry{
+			Title:       record[0],
+			Type:        c.Achievement(record[1]),
+			Value:       strings.ToLower(record[2]),
+			Description: record[3],
+			ImageName:   record[4],
+		}
+		old, ok := stored[entry.Title]
+		entry.ID = old.ID
+		switch {
+		case !ok:
+			result.Inserted++
+		case old == entry:
+			result.Skipped++
+			continue
+		default:
+			result.Updated++
+		}
+		stored[entry.Title] = entry
+		changed = append(changed, &entry)
+	}
+	err = inBatches(changed, batchSize, func(batch []*AchievementEntry) error {
+		return store.UpdateAchievements(ctx, batch)
+	})
+	if err != nil {
+		return []*SeedResult{result}, err
+	}
+	icons, err := seedImages(ctx, store, "achievement icons", config.AchievementIcons, batchSize, Storage.GetAchievementImage, Storage.AddAchievementImage)
+	return append([]*SeedResult{result}, icons...), err
+}
@@ -1,1049 +1,1202 @@
-package storage
-
-// SQLite implementation of the storage interface
-
-import (
-	"database/sql"
-	"fmt"
-	"log"
-	"math/rand"
-	"strings"
-	"time"
-
-	_ "github.com/mattn/go-sqlite3"
-	c "github.com/na50r/wombo-combo-go-be/constants"
-	dto "github.com/na50r/wombo-combo-go-be/dto"
-	u "github.com/na50r/wombo-combo-go-be/utility"
-)
-
-type SQLiteStore struct {
-	db *sql.DB
-}
-
-func NewSQLiteStore(name string) (*SQLiteStore, error) {
-	db, err := sql.Open("sqlite3", fmt.Sprintf("./%s.db", name))
-	if err != nil {
-		fmt.Println(err)
-		return nil, err
-	}
-
-	_, err = db.Exec("PRAGMA journal_mode = WAL")
-	if err != nil {
-		return nil, err
-	}
-	// Release lock in 5 seconds
-	// Reference: https://stackoverflow.com/questions/66909180/increase-the-lock-timeout-with-sqlite-and-what-is-the-default-values
-	_, err = db.Exec("PRAGMA busy_timeout = 5000")
-	if err != nil {
-		return nil, err
-	}
-
-	fmt.Println("Connected to the SQLite database successfully.")
-	return &SQLiteStore{db: db}, nil
-}
-
-func (s *SQLiteStore) createUnlockedTable() error {
-	query := `create table if not exists unlocked (
-		username text,
-		achievement_title text,
-		primary key (username, achievement_title)
-		)`
-	_, err := s.db.Exec(query)
-	return err
-}
-
-func (s *SQLiteStore) createAchievementTable() error {
-	query := `create table if not exists achievement (
-		id integer primary key,
-		title text,
-		type text,
-		value text,
-		description text,
-		image_name text
-		)`
-	_, err := s.db.Exec(query)
-	return err
-}
-
-func (s *SQLiteStore) createAccountTable() error {
-	query := `create table if not exists account (
-		username text primary key,
-		image_name text,
-		password text,
-		wins integer,
-		losses integer,
-		created_at datetime,
-		status text,
-		is_owner boolean,
-		new_word_count integer,
-		word_count integer
-		)`
-	_, err := s.db.Exec(query)
-	return err
-}
-
-func (s *SQLiteStore) createImageTable() error {
-	query := `create table if not exists image (
-		name text primary key,
-		data blob
-		)`
-	_, err := s.db.Exec(query)
-	return err
-}
-
-func (s *SQLiteStore) createAchievementImageTable() error {
-	query := `create table if not exists achievement_image (
-		name text primary key,
-		data blob
-		)`
-	_, err := s.db.Exec(query)
-	return err
-}
-
-func (s *SQLiteStore) createPlayerTable() error {
-	query := `create table if not exists player (
-		name text,
-		lobby_code text,
-		image_name,
-		is_owner boolean,
-		has_account boolean,
-		target_word text,
-		points integer,
-		word_count integer,
-		new_word_count integer,
-		primary key (name, lobby_code)
-		)`
-	_, err := s.db.Exec(query)
-	return err
-}
-
-func (s *SQLiteStore) createLobbyTable() error {
-	query := `create table if not exists lobby (
-		name text,
-		image_name text,
-		lobby_code text,
-		game_mode text,
-		player_count integer,
-		primary key (lobby_code)
-		)`
-	_, err := s.db.Exec(query)
-	return err
-}
-func (s *SQLiteStore) createCombinationTable() error {
-	query := `create table if not exists combination (
-		a text,
-		b text,
-		result text,
-		depth integer,
-		primary key (a, b)
-		)`
-	_, err := s.db.Exec(query)
-	return err
-}
-
-func (s *SQLiteStore) createWordTable() error {
-	query := `create table if not exists word (
-		word text primary key,
-		depth integer,
-		reachability float
-		)`
-	_, err := s.db.Exec(query)
-	return err
-}
-
-func (s *SQLiteStore) createPlayerWordTable() error {
-	query := `create table if not exists player_word (
-		player_name text,
-		word text,
-		lobby_code text,
-		timestamp datetime default current_timestamp,
-		primary key (player_name, word, lobby_code)
-		)`
-	_, err := s.db.Exec(query)
-	return err
-}
-
-func (s *SQLiteStore) createDailyWordTable() error {
-	query := `create table if not exists daily_word (
-		timestamp datetime default current_timestamp,
-		word text
-		)`
-	_, err := s.db.Exec(query)
-	return err
-}
-
-func (s *SQLiteStore) createDailyChallengeTable() error {
-	query := `create table if not exists daily_challenge (
-		timestamp datetime default current_timestamp,
-		word_count integer,
-		username text
-		)`
-	_, err := s.db.Exec(query)
-	return err
-}
-
-func (s *SQLiteStore) createSessionTable() error {
-	query := `create table if not exists session (
-		id text primary key,
-		refresh_token text,
-		username text,
-		is_revoked boolean,
-		created_at datetime,
-		expires_at datetime
-		)`
-	_, err := s.db.Exec(query)
-	return err
-}
-
-func (s *SQLiteStore) Init() error {
-	if err := s.createAccountTable(); err != nil {
-		return err
-	}
-	if err := s.createImageTable(); err != nil {
-		return err
-	}
-	if err := s.createPlayerTable(); err != nil {
-		fmt.Println(err)
-		return err
-	}
-	if err := s.createLobbyTable(); err != nil {
-		return err
-	}
-	if err := s.createCombinationTable(); err != nil {
-		return err
-	}
-	if err := s.createWordTable(); err != nil {
-		return err
-	}
-	if err := s.createPlayerWordTable(); err != nil {
-		return err
-	}
-	if err := s.createDailyWordTable(); err != nil {
-		return err
-	}
-	if err := s.createDailyChallengeTable(); err != nil {
-		return err
-	}
-	if err := s.createSessionTable(); err != nil {
-		return err
-	}
-	if err := s.createAchievementTable(); err != nil {
-		return err
-	}
-	if err := s.createUnlockedTable(); err != nil {
-		return err
-	}
-	if err := s.createAchievementImageTable(); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (s *SQLiteStore) UnlockAchievement(username, achievementTitle string) (bool, error) {
-	res, err := s.db.Exec("insert or ignore into unlocked (username, achievement_title) values (?, ?)", username, achievementTitle)
-	if err != nil {
-		return false, err
-	}
-	affected, err := res.RowsAffected()
-	if err != nil {
-		return false, err
-	}
-	return affected > 0, nil
-}
-
-func (s *SQLiteStore) AddAchievement(entry *AchievementEntry) error {
-	_, err := s.db.Exec(
-		"insert into achievement (type, title, value, description, image_name) values (?, ?, ?, ?, ?)",
-		entry.Type,
-		entry.Title,
-		entry.Value,
-		entry.Description,
-		entry.ImageName,
-	)
-	return err
-}
-
-func (s *SQLiteStore) AddDailyChallengeEntry(wordCount int, username string) error {
-	today := time.Now().Format("2006-01-02")
-	var oldCount int
-	err := s.db.QueryRow("select word_count from daily_challenge where username = ? and timestamp = ?", username, today).Scan(&oldCount)
-	if err != nil && err != sql.ErrNoRows {
-		return err
-	}
-	if err == sql.ErrNoRows {
-		_, err = s.db.Exec("insert into daily_challenge (word_count, username, timestamp) values (?, ?, ?)", wordCount, username, today)
-		return err
-	}
-	if oldCount > wordCount {
-		_, err = s.db.Exec("update daily_challenge set word_count = ? where username = ? and timestamp = ?", wordCount, username, today)
-		return err
-	}
-	return nil
-}
-
-func (s *SQLiteStore) CreateOrGetDailyWord(minReachability, maxReachability float64, maxDepth int) (string, error) {
-	log.Println("Creating or getting daily word")
-	today := time.Now().Format("2006-01-02")
-	var word string
-	err := s.db.QueryRow("select word from daily_word where timestamp = ?", today).Scan(&word)
-	if err == sql.ErrNoRows {
-		word, err := s.GetTargetWord(minReachability, maxReachability, maxDepth)
-		if err != nil {
-			return "", err
-		}
-		_, err = s.db.Exec("insert into daily_word (timestamp, word) values (?, ?)", today, word)
-		if err != nil {
-			return "", err
-		}
-		return word, nil
-	}
-	return word, nil
-}
-
-func (s *SQLiteStore) CreateAccount(acc *Account) error {
-	query := `insert into account 
-	(username, image_name, password, wins, losses, created_at, status, is_owner, new_word_count, word_count)
-	values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := s.db.Exec(
-		query,
-		acc.Username,
-		acc.ImageName,
-		acc.Password,
-		acc.Wins,
-		acc.Losses,
-		acc.CreatedAt,
-		acc.Status,
-		acc.IsOwner,
-		acc.NewWordCount,
-		acc.WordCount,
-	)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (s *SQLiteStore) CreatePlayer(player *Player) error {
-	query := `insert into player 
-	(name, lobby_code, image_name, is_owner, has_account, target_word, points, word_count, new_word_count)
-	values (?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := s.db.Exec(
-		query,
-		player.Name,
-		player.LobbyCode,
-		player.ImageName,
-		player.IsOwner,
-		player.HasAccount,
-		player.TargetWord,
-		player.Points,
-		player.WordCount,
-		player.NewWordCount,
-	)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (s *SQLiteStore) CreateLobby(lobby *Lobby) error {
-	query := `insert into lobby 
-	(name, image_name, lobby_code, game_mode, player_count)
-	values (?, ?, ?, ?, ?)`
-	_, err := s.db.Exec(
-		query,
-		lobby.Name,
-		lobby.ImageName,
-		lobby.LobbyCode,
-		lobby.GameMode,
-		lobby.PlayerCount,
-	)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (s *SQLiteStore) GetPlayerByLobbyCodeAndName(name, lobbyCode string) (*Player, error) {
-	rows, err := s.db.Query("select * from player where name = ? and lobby_code = ?", name, lobbyCode)
-	if err != nil {
-		return nil, err
-	}
-	for rows.Next() {
-		defer rows.Close()
-		return scanIntoPlayer(rows)
-	}
-	return nil, fmt.Errorf("player %s not found", name)
-}
-
-func (s *SQLiteStore) DeletePlayer(name, lobbyCode string) error {
-	_, err := s.db.Exec("delete from player where name = ? and lobby_code = ?", name, lobbyCode)
-	return err
-}
-
-func (s *SQLiteStore) GetPlayersByLobbyCode(lobbyCode string) ([]*Player, error) {
-	rows, err := s.db.Query("select * from player where lobby_code = ?", lobbyCode)
-	if err != nil {
-		return nil, err
-	}
-	players := []*Player{}
-	defer rows.Close()
-	for rows.Next() {
-		player, err := scanIntoPlayer(rows)
-		if err != nil {
-			return nil, err
-		}
-		players = append(players, player)
-	}
-	return players, nil
-}
-
-func (s *SQLiteStore) GetAccountByUsername(username string) (*Account, error) {
-	rows, err := s.db.Query("select * from account where username = ?", username)
-	if err != nil {
-		return nil, err
-	}
-
-	for rows.Next() {
-		defer rows.Close()
-		return scanIntoAccount(rows)
-	}
-	return nil, fmt.Errorf("account %s not found", username)
-}
-
-func (s *SQLiteStore) UpdateAccount(acc *Account) error {
-	query := `update account set
-	username = ?,
-	image_name = ?,
-	password = ?,
-	wins = ?,
-	losses = ?,
-	status = ?,
-	new_word_count = ?,
-	word_count = ?
-	where username = ?`
-	_, err := s.db.Exec(
-		query,
-		acc.Username,
-		acc.ImageName,
-		acc.Password,
-		acc.Wins,
-		acc.Losses,
-		acc.Status,
-		acc.NewWordCount,
-		acc.WordCount,
-		acc.Username,
-	)
-	return err
-}
-
-func (s *SQLiteStore) AddImage(data []byte, name string) error {
-	_, err := s.db.Exec(
-		"insert or replace into image (name, data) values (?, ?)",
-		name,
-		data,
-	)
-	return err
-}
-
-func (s *SQLiteStore) GetImage(name string) ([]byte, error) {
-	rows, err := s.db.Query("select * from image where name = ?", name)
-	if err != nil {
-		return nil, err
-	}
-	images := []*Image{}
-	defer rows.Close()
-	for rows.Next() {
-		img, err := scanIntoImage(rows)
-		if err != nil {
-			return nil, err
-		}
-		images = append(images, img)
-	}
-	if len(images) > 1 {
-		return nil, fmt.Errorf("Multiple images for name %s", name)
-	}
-	if len(images) == 0 {
-		return nil, fmt.Errorf("Image for name %s not found", name)
-	}
-	return images[0].Data, nil
-}
-
-func (s *SQLiteStore) GetImages() ([]*Image, error) {
-	rows, err := s.db.Query("select * from image")
-	if err != nil {
-		return nil, err
-	}
-	images := []*Image{}
-	defer rows.Close()
-	for rows.Next() {
-		img, err := scanIntoImage(rows)
-		if err != nil {
-			return nil, err
-		}
-		images = append(images, img)
-	}
-	return images, nil
-}
-
-func (s *SQLiteStore) NewImageForUsername(username string) string {
-	images, err := s.GetImages()
-	if err != nil {
-		return err.Error()
-	}
-	size := len(images)
-	hash := u.RadixHash(username, size)
-	image := images[hash]
-	return image.Name
-}
-
-func (s *SQLiteStore) GetPlayerForAccount(username string) (*Player, error) {
-	acc, err := s.GetAccountByUsername(username)
-	if err != nil {
-		return nil, err
-	}
-	return NewPlayer(username, "", acc.ImageName, false, true, acc.NewWordCount, acc.WordCount), nil
-}
-
-func (s *SQLiteStore) GetOwners() ([]*Player, error) {
-	rows, err := s.db.Query("select * from player where is_owner = ?", true)
-	if err != nil {
-		return nil, err
-	}
-	owners := []*Player{}
-	defer rows.Close()
-	for rows.Next() {
-		owner, err := scanIntoPlayer(rows)
-		if err != nil {
-			return nil, err
-		}
-		owners = append(owners, owner)
-	}
-	return owners, nil
-}
-
-func (s *SQLiteStore) GetLobbyForOwner(owner string) (string, error) {
-	rows, err := s.db.Query("select lobby_code from player where name = ? and is_owner = ?", owner, true)
-	if err != nil {
-		return "", err
-	}
-	lobbyCodes := []string{}
-	var lobbyCode string
-	defer rows.Close()
-	for rows.Next() {
-		err := rows.Scan(&lobbyCode)
-		if err != nil {
-			return "", err
-		}
-		lobbyCodes = append(lobbyCodes, lobbyCode)
-	}
-	if len(lobbyCodes) > 1 {
-		return "", fmt.Errorf("Multiple lobbies for owner")
-	}
-	if len(lobbyCodes) == 0 {
-		log.Printf("No lobby found for owner %s", owner)
-		return "", nil
-	}
-	return lobbyCodes[0], nil
-}
-
-func (s *SQLiteStore) DeletePlayersForLobby(lobbyCode string) error {
-	_, err := s.db.Exec("delete from player where lobby_code = ?", lobbyCode)
-	err = s.IncrementPlayerCount(lobbyCode, -1)
-	return err
-}
-
-func (s *SQLiteStore) AddPlayerToLobby(lobbyCode string, player *Player) error {
-	_, err := s.db.Exec(
-		"insert into player (name, lobby_code, image_name, is_owner, has_account, target_word, points, new_word_count, word_count) values (?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		player.Name,
-		lobbyCode,
-		player.ImageName,
-		player.IsOwner,
-		player.HasAccount,
-		player.TargetWord,
-		player.Points,
-		player.NewWordCount,
-		player.WordCount,
-	)
-	log.Printf("insert error: %v", err)
-	if err != nil {
-		return err
-	}
-	err = s.IncrementPlayerCount(lobbyCode, 1)
-	return err
-}
-
-func (s *SQLiteStore) IncrementPlayerCount(lobbyCode string, increment int) error {
-	_, err := s.db.Exec("update lobby set player_count = player_count + ? where lobby_code = ?", increment, lobbyCode)
-	return err
-}
-
-func (s *SQLiteStore) GetLobbies() ([]*Lobby, error) {
-	rows, err := s.db.Query("select * from lobby")
-	if err != nil {
-		return nil, err
-	}
-	lobbies := []*Lobby{}
-	defer rows.Close()
-	for rows.Next() {
-		lobby, err := scanIntoLobby(rows)
-		if err != nil {
-			return nil, err
-		}
-		lobbies = append(lobbies, lobby)
-	}
-	return lobbies, nil
-}
-
-func (s *SQLiteStore) DeleteLobby(lobbyCode string) error {
-	_, err := s.db.Exec("delete from lobby where lobby_code = ?", lobbyCode)
-	return err
-}
-
-func (s *SQLiteStore) GetLobbyByCode(lobbyCode string) (*Lobby, error) {
-	rows, err := s.db.Query("select * from lobby where lobby_code = ?", lobbyCode)
-	if err != nil {
-		return nil, err
-	}
-	for rows.Next() {
-		defer rows.Close()
-		return scanIntoLobby(rows)
-	}
-	return nil, fmt.Errorf("lobby %s not found", lobbyCode)
-}
-
-func (s *SQLiteStore) EditGameMode(lobbyCode string, gameMode c.GameMode) error {
-	_, err := s.db.Exec("update lobby set game_mode = ? where lobby_code = ?", gameMode, lobbyCode)
-	return err
-}
-
-func (s *SQLiteStore) GetCombination(a, b string) (*string, bool, error) {
-	a, b = u.SortAB(a, b)
-	var result string
-	err := s.db.QueryRow("SELECT result FROM combination WHERE a = ? AND b = ?", a, b).Scan(&result)
-	if err == sql.ErrNoRows {
-		return nil, false, nil
-	} else if err != nil {
-		return nil, false, err
-	}
-	return &result, true, nil
-}
-
-func (s *SQLiteStore) AddCombination(combi *Combination) error {
-	a, b := u.SortAB(combi.A, combi.B)
-	_, err := s.db.Exec(
-		"insert or ignore into combination (a, b, result, depth) values (?, ?, ?, ?)",
-		a,
-		b,
-		combi.Result,
-		combi.Depth,
-	)
-	return err
-}
-
-func (s *SQLiteStore) AddNewCombination(a, b, result string) error {
-	a, b = u.SortAB(a, b)
-	aDepth := 0
-	bDepth := 0
-	err := s.db.QueryRow("select depth from word where word = ?", a).Scan(&aDepth)
-	if err != nil {
-		return err
-	}
-	err = s.db.QueryRow("select depth from word where word = ?", b).Scan(&bDepth)
-	if err != nil {
-		return err
-	}
-	depth := max(aDepth, bDepth) + 1
-	_, err = s.db.Exec(
-		"insert or ignore into combination (a, b, result, depth) values (?, ?, ?, ?)",
-		a,
-		b,
-		result,
-		depth,
-	)
-	oldReachability := 0.0
-	err = s.db.QueryRow("select reachability from word where word = ?", result).Scan(&oldReachability)
-	if err != nil && err != sql.ErrNoRows {
-		log.Printf("No reachability for word %s", result)
-	}
-	reachability := 1.0 / float64(int(1)<<uint(depth))
-	_, err = s.db.Exec(
-		"insert or ignore into word (word, depth, reachability) values (?, ?, ?)",
-		result,
-		depth,
-		reachability+oldReachability,
-	)
-	return err
-}
-
-func (s *SQLiteStore) AddWord(word *Word) error {
-	w := strings.ToLower(word.Word)
-	_, err := s.db.Exec(
-		"insert or ignore into word (word, depth, reachability) values (?, ?, ?)",
-		w,
-		word.Depth,
-		word.Reachability,
-	)
-	return err
-}
-
-func (s *SQLiteStore) GetTargetWords(minReachability, maxReachability float64, maxDepth int) ([]string, error) {
-	rows, err := s.db.Query("select * from word where reachability >= ? and reachability <= ? and depth <= ?", minReachability, maxReachability, maxDepth)
-	if err != nil {
-		return nil, err
-	}
-	targetWords := []string{}
-	defer rows.Close()
-	for rows.Next() {
-		word, err := scanIntoWord(rows)
-		if err != nil {
-			return nil, err
-		}
-		targetWords = append(targetWords, word.Word)
-	}
-	log.Printf("Number of target words: %d", len(targetWords))
-	return targetWords, nil
-}
-
-func (s *SQLiteStore) GetTargetWord(minReachability, maxReachability float64, maxDepth int) (string, error) {
-	targetWords, err := s.GetTargetWords(minReachability, maxReachability, maxDepth)
-	if err != nil {
-		return "", err
-	}
-	return targetWords[rand.Intn(len(targetWords))], nil
-}
-
-func (s *SQLiteStore) AddPlayerWord(playerName, word, lobbyCode string) error {
-	_, err := s.db.Exec(
-		"insert or ignore into player_word (player_name, word, lobby_code) values (?, ?, ?)",
-		playerName,
-		word,
-		lobbyCode,
-	)
-	return err
-}
-
-func (s *SQLiteStore) IsPlayerWord(playerName, word, lobbyCode string) (bool, error) {
-	var count int
-	err := s.db.QueryRow("select count(*) from player_word where player_name = ? and word = ? and lobby_code = ?", playerName, word, lobbyCode).Scan(&count)
-	if err != nil {
-		return false, err
-	}
-	return count > 0, nil
-}
-
-func (s *SQLiteStore) SetPlayerTargetWord(playerName, targetWord, lobbyCode string) error {
-	_, err := s.db.Exec(
-		"update player set target_word = ? where name = ? and lobby_code = ?",
-		targetWord,
-		playerName,
-		lobbyCode,
-	)
-	return err
-}
-
-func (s *SQLiteStore) GetPlayerTargetWord(playerName, lobbyCode string) (string, error) {
-	var targetWord string
-	err := s.db.QueryRow("select target_word from player where name = ? and lobby_code = ?", playerName, lobbyCode).Scan(&targetWord)
-	if err != nil {
-		return "", err
-	}
-	return targetWord, nil
-}
-
-func (s *SQLiteStore) GetPlayerWords(playerName, lobbyCode string) ([]string, error) {
-	rows, err := s.db.Query("select * from player_word where player_name = ? and lobby_code = ? order by timestamp asc", playerName, lobbyCode)
-	if err != nil {
-		return nil, err
-	}
-	words := []string{}
-	defer rows.Close()
-	for rows.Next() {
-		playerWord, err := scanIntoPlayerWord(rows)
-		if err != nil {
-			return nil, err
-		}
-		words = append(words, playerWord.Word)
-	}
-	return words, nil
-}
-
-func (s *SQLiteStore) DeletePlayerWordsByLobbyCode(lobbyCode string) error {
-	_, err := s.db.Exec("delete from player_word where lobby_code = ?", lobbyCode)
-	return err
-}
-
-func (s *SQLiteStore) DeletePlayerWordsByPlayerAndLobbyCode(playerName, lobbyCode string) error {
-	_, err := s.db.Exec("delete from player_word where player_name = ? and lobby_code = ?", playerName, lobbyCode)
-	return err
-}
-
-func (s *SQLiteStore) GetWordCountByLobbyCode(lobbyCode string) ([]*dto.PlayerWordCount, error) {
-	query := `
-	select player_name, COUNT(*) as word_count
-	from player_word
-	where lobby_code = ?
-	group by player_name
-	order by word_count desc
-	`
-	rows, err := s.db.Query(query, lobbyCode)
-	if err != nil {
-		return nil, err
-	}
-	wordCounts := []*dto.PlayerWordCount{}
-	defer rows.Close()
-	for rows.Next() {
-		wordCount, err := scanIntoPlayerWordCount(rows)
-		if err != nil {
-			return nil, err
-		}
-		wordCount.WordCount -= 4 // Exclude starting words
-		wordCounts = append(wordCounts, wordCount)
-	}
-	return wordCounts, nil
-}
-
-func (s *SQLiteStore) GetPlayersWithAccount(lobbyCode string) ([]*Account, error) {
-	rows, err := s.db.Query("select * from player where lobby_code = ? and has_account = ?", lobbyCode, true)
-	if err != nil {
-		return nil, err
-	}
-	accounts := []*Account{}
-	defer rows.Close()
-	for rows.Next() {
-		player, err := scanIntoPlayer(rows)
-		if err != nil {
-			return nil, err
-		}
-		acc, err := s.GetAccountByUsername(player.Name)
-		if err != nil {
-			return nil, err
-		}
-		accounts = append(accounts, acc)
-	}
-	return accounts, nil
-}
-
-func (s *SQLiteStore) UpdateAccountWinsAndLosses(lobbyCode, winner string) error {
-	accounts, err := s.GetPlayersWithAccount(lobbyCode)
-	if err != nil {
-		return err
-	}
-	for _, acc := range accounts {
-		if acc.Username == winner {
-			acc.Wins++
-		} else {
-			acc.Losses++
-		}
-		if err := s.UpdateAccount(acc); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (s *SQLiteStore) IncrementPlayerPoints(playerName, lobbyCode string, points int) error {
-	_, err := s.db.Exec("update player set points = points + ? where name = ? and lobby_code = ?", points, playerName, lobbyCode)
-	return err
-}
-
-func (s *SQLiteStore) ResetPlayerPoints(lobbyCode string) error {
-	_, err := s.db.Exec("update player set points = 0 where lobby_code = ?", lobbyCode)
-	return err
-}
-
-func (s *SQLiteStore) SetIsOwner(username string, setOwner bool) error {
-	if !setOwner {
-		_, err := s.db.Exec("update account set is_owner = ? where username = ?", setOwner, username)
-		return err
-	}
-
-	var isOwner bool
-	err := s.db.QueryRow("select is_owner from account where username = ?", username).Scan(&isOwner)
-	if err != nil {
-		return err
-	}
-	if isOwner {
-		return fmt.Errorf("User is already owner!")
-	}
-	_, err = s.db.Exec("update account set is_owner = ? where username = ?", setOwner, username)
-	return err
-}
-
-func (s *SQLiteStore) SelectWinnerByPoints(lobbyCode string) (string, error) {
-	rows, err := s.db.Query("select name from player where lobby_code = ? order by points desc", lobbyCode)
-	if err != nil {
-		return "", err
-	}
-	winners := []string{}
-	var winner string
-	defer rows.Close()
-	for rows.Next() {
-		err := rows.Scan(&winner)
-		if err != nil {
-			return "", err
-		}
-		winners = append(winners, winner)
-	}
-	if len(winners) == 0 {
-		return "", fmt.Errorf("No players found")
-	}
-	return winners[0], nil
-}
-
-func (s *SQLiteStore) DeleteAccount(username string) error {
-	_, err := s.db.Exec("delete from account where username = ?", username)
-	return err
-}
-
-func (s *SQLiteStore) GetChallengeEntries() ([]*Challenger, error) {
-	today := time.Now().Format("2006-01-02")
-	rows, err := s.db.Query("select * from daily_challenge where timestamp = ?", today)
-	if err != nil {
-		return nil, err
-	}
-	entries := []*Challenger{}
-	defer rows.Close()
-	for rows.Next() {
-		entry, err := scanIntoChallengeEntry(rows)
-		if err != nil {
-			return nil, err
-		}
-		entries = append(entries, entry)
-	}
-	return entries, nil
-}
-
-func (s *SQLiteStore) GetImageByUsername(username string) ([]byte, error) {
-	var imageName string
-	err := s.db.QueryRow("select image_name from account where username = ?", username).Scan(&imageName)
-	if err != nil {
-		return nil, err
-	}
-	return s.GetImage(imageName)
-}
-
-func (s *SQLiteStore) CreateSession(session *Session) error {
-	query := `insert into session 
-	(id, refresh_token, username, is_revoked, created_at, expires_at)
-	values (?, ?, ?, ?, ?, ?)`
-	_, err := s.db.Exec(
-		query,
-		session.ID,
-		session.RefreshToken,
-		session.Username,
-		session.IsRevoked,
-		session.CreatedAt,
-		session.ExpiresAt,
-	)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (s *SQLiteStore) GetSession(id string) (*Session, error) {
-	rows, err := s.db.Query("select * from session where id = ?", id)
-	if err != nil {
-		return nil, err
-	}
-	for rows.Next() {
-		defer rows.Close()
-		return scanIntoSession(rows)
-	}
-	return nil, fmt.Errorf("session %s not found", id)
-}
-
-func (s *SQLiteStore) RevokeSession(id string) error {
-	_, err := s.db.Exec("update session set is_revoked = ? where id = ?", true, id)
-	return err
-}
-
-func (s *SQLiteStore) GetAchievements() ([]*AchievementEntry, error) {
-	rows, err := s.db.Query("select * from achievement")
-	if err != nil {
-		return nil, err
-	}
-	entries := []*AchievementEntry{}
-	defer rows.Close()
-	for rows.Next() {
-		entry, err := scanIntoAchievementEntry(rows)
-		if err != nil {
-			return nil, err
-		}
-		entries = append(entries, entry)
-	}
-	return entries, nil
-}
-
-func (s *SQLiteStore) UpdateAccountWordCount(username string, newWordCount, wordCount int) error {
-	_, err := s.db.Exec("update account set new_word_count = ?, word_count = ? where username = ?", newWordCount, wordCount, username)
-	return err
-}
-
-func (s *SQLiteStore) UpdatePlayerWordCount(playerName, lobbyCode string, newWordCount, wordCount int) error {
-	_, err := s.db.Exec("update player set new_word_count = ?, word_count = ? where name = ? and lobby_code = ?", newWordCount, wordCount, playerName, lobbyCode)
-	return err
-}
-
-func (s *SQLiteStore) AddAchievementImage(data []byte, name string) error {
-	_, err := s.db.Exec(
-		"insert or replace into achievement_image (name, data) values (?, ?)",
-		name,
-		data,
-	)
-	return err
-}
-
-func (s *SQLiteStore) GetAchievementImage(name string) ([]byte, error) {
-	rows, err := s.db.Query("select * from achievement_image where name = ?", name)
-	if err != nil {
-		return nil, err
-	}
-	images := []*Image{}
-	defer rows.Close()
-	for rows.Next() {
-		img, err := scanIntoImage(rows)
-		if err != nil {
-			return nil, err
-		}
-		images = append(images, img)
-	}
-	if len(images) > 1 {
-		return nil, fmt.Errorf("Multiple images for name %s", name)
-	}
-	if len(images) == 0 {
-		return nil, fmt.Errorf("Image for name %s not found", name)
-	}
-	return images[0].Data, nil
-}
-
-func (s *SQLiteStore) GetAchievementByTitle(title string) (*AchievementEntry, error) {
-	rows, err := s.db.Query("select * from achievement where title = ?", title)
-	if err != nil {
-		return nil, err
-	}
-	for rows.Next() {
-		defer rows.Close()
-		return scanIntoAchievementEntry(rows)
-	}
-	return nil, fmt.Errorf("Achievement %s not found", title)
-}
-
-func (s *SQLiteStore) GetAchievementsForUser(username string) ([]string, error) {
-	rows, err := s.db.Query("select achievement_title from unlocked where username = ?", username)
-	if err != nil {
-		return nil, err
-	}
-	achievements := []string{}
-	defer rows.Close()
-	for rows.Next() {
-		var title string
-		err := rows.Scan(&title)
-		if err != nil {
-			return nil, err
-		}
-		achievements = append(achievements, title)
-	}
-	return achievements, nil
-}
+package storage
+
+// SQLite implementation of the storage interface
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	ae "github.com/na50r/wombo-combo-go-be/apierror"
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	dto "github.com/na50r/wombo-combo-go-be/dto"
+	u "github.com/na50r/wombo-combo-go-be/utility"
+)
+
+type SQLiteStore struct {
+	db   dbtx // the pool, or the open transaction inside WithTx
+	pool *sql.DB
+}
+
+func NewSQLiteStore(name string) (*SQLiteStore, error) {
+	// Transactions take the write lock when they begin, two readers upgrading at the same time would fail with SQLITE_BUSY
+	db, err := sql.Open("sqlite3", fmt.Sprintf("./%s.db?_txlock=immediate", name))
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	_, err = db.Exec("PRAGMA journal_mode = WAL")
+	if err != nil {
+		return nil, err
+	}
+	// Release lock in 5 seconds
+	// Reference: https://stackoverflow.com/questions/66909180/increase-the-lock-timeout-with-sqlite-and-what-is-the-default-values
+	_, err = db.Exec("PRAGMA busy_timeout = 5000")
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("Connected to the SQLite database successfully.")
+	return &SQLiteStore{db: db, pool: db}, nil
+}
+
+// transaction runs fn on a store bound to a transaction, nested calls join the open transaction
+func (s *SQLiteStore) transaction(ctx context.Context, fn func(tx *SQLiteStore) error) error {
+	if _, ok := s.db.(*sql.Tx); ok {
+		return fn(s)
+	}
+	return withTx(ctx, s.pool, func(tx *sql.Tx) error {
+		return fn(&SQLiteStore{db: tx, pool: s.pool})
+	})
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.pool.Close()
+}
+
+func (s *SQLiteStore) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	return s.transaction(ctx, func(tx *SQLiteStore) error {
+		return fn(tx)
+	})
+}
+
+func (s *SQLiteStore) migrator() *migrator {
+	return &migrator{db: s.pool, migrations: sqliteMigrations, bind: func(n int) string { return "?" }}
+}
+
+// Init refuses to run against a schema that is not migrated to the latest version
+func (s *SQLiteStore) Init() error {
+	return s.migrator().check()
+}
+
+func (s *SQLiteStore) Migrate(version int) error {
+	return s.migrator().migrate(version)
+}
+
+func (s *SQLiteStore) MigrationStatus() ([]MigrationStatus, error) {
+	return s.migrator().status()
+}
+
+func (s *SQLiteStore) UnlockAchievement(ctx context.Context, username, achievementTitle string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, "insert or ignore into unlocked (username, achievement_title) values (?, ?)", username, achievementTitle)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (s *SQLiteStore) AddAchievement(ctx context.Context, entry *AchievementEntry) error {
+	_, err := s.db.ExecContext(ctx,
+		"insert into achievement (type, title, value, description, image_name) values (?, ?, ?, ?, ?)",
+		entry.Type,
+		entry.Title,
+		entry.Value,
+		entry.Description,
+		entry.ImageName,
+	)
+	return err
+}
+
+// Titles are not unique in the schema, so every achievement with the title is updated
+func (s *SQLiteStore) UpdateAchievements(ctx context.Context, entries []*AchievementEntry) error {
+	return s.transaction(ctx, func(tx *SQLiteStore) error {
+		for _, entry := range entries {
+			res, err := tx.db.ExecContext(ctx,
+				"update achievement set type = ?, value = ?, description = ?, image_name = ? where title = ?",
+				entry.Type,
+				entry.Value,
+				entry.Description,
+				entry.ImageName,
+				entry.Title,
+			)
+			if err != nil {
+				return err
+			}
+			updated, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if updated > 0 {
+				continue
+			}
+			if err := tx.AddAchievement(ctx, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStore) AddDailyChallengeEntry(ctx context.Context, wordCount int, username string) error {
+	return s.AddChallengeEntry(ctx, &Challenger{Timestamp: time.Now(), WordCount: wordCount, Username: username})
+}
+
+// Only the lowest word count of a user per day is kept
+func (s *SQLiteStore) AddChallengeEntry(ctx context.Context, entry *Challenger) error {
+	day := entry.Timestamp.Format("2006-01-02")
+	var oldCount int
+	err := s.db.QueryRowContext(ctx, "select word_count from daily_challenge where username = ? and timestamp = ?", entry.Username, day).Scan(&oldCount)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == sql.ErrNoRows {
+		_, err = s.db.ExecContext(ctx, "insert into daily_challenge (word_count, username, timestamp) values (?, ?, ?)", entry.WordCount, entry.Username, day)
+		return err
+	}
+	if oldCount > entry.WordCount {
+		_, err = s.db.ExecContext(ctx, "update daily_challenge set word_count = ? where username = ? and timestamp = ?", entry.WordCount, entry.Username, day)
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteStore) CreateOrGetDailyWord(ctx context.Context, minReachability, maxReachability float64, maxDepth int) (string, error) {
+	log.Println("Creating or getting daily word")
+	today := time.Now().Format("2006-01-02")
+	var word string
+	err := s.db.QueryRowContext(ctx, "select word from daily_word where timestamp = ?", today).Scan(&word)
+	if err == sql.ErrNoRows {
+		word, err := s.GetTargetWord(ctx, minReachability, maxReachability, maxDepth)
+		if err != nil {
+			return "", err
+		}
+		_, err = s.db.ExecContext(ctx, "insert into daily_word (timestamp, word) values (?, ?)", today, word)
+		if err != nil {
+			return "", err
+		}
+		return word, nil
+	}
+	return word, nil
+}
+
+func (s *SQLiteStore) GetDailyWords(ctx context.Context) ([]*DailyWord, error) {
+	rows, err := s.db.QueryContext(ctx, "select timestamp, word from daily_word order by timestamp")
+	if err != nil {
+		return nil, err
+	}
+	dailyWords := []*DailyWord{}
+	defer rows.Close()
+	for rows.Next() {
+		daily, err := scanIntoDailyWord(rows)
+		if err != nil {
+			return nil, err
+		}
+		dailyWords = append(dailyWords, daily)
+	}
+	return dailyWords, rows.Err()
+}
+
+func (s *SQLiteStore) AddDailyWord(ctx context.Context, daily *DailyWord) error {
+	day := daily.Timestamp.Format("2006-01-02")
+	_, err := s.db.ExecContext(ctx,
+		"insert into daily_word (timestamp, word) select ?, ? where not exists (select 1 from daily_word where timestamp = ?)",
+		day,
+		daily.Word,
+		day,
+	)
+	return err
+}
+
+func (s *SQLiteStore) CreateAccount(ctx context.Context, acc *Account) error {
+	query := `insert into account 
+	(username, image_name, password, wins, losses, created_at, status, is_owner, new_word_count, word_count)
+	values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := s.db.ExecContext(ctx,
+		query,
+		acc.Username,
+		acc.ImageName,
+		acc.Password,
+		acc.Wins,
+		acc.Losses,
+		acc.CreatedAt,
+		acc.Status,
+		acc.IsOwner,
+		acc.NewWordCount,
+		acc.WordCount,
+	)
+	if sqliteUniqueViolation(err) {
+		return ae.Conflictf("account %s already exists", acc.Username)
+	}
+	return err
+}
+
+// sqliteUniqueViolation reports whether err is a violated primary key or unique constraint
+func sqliteUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey || sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+func (s *SQLiteStore) CreatePlayer(ctx context.Context, player *Player) error {
+	query := `insert into player 
+	(name, lobby_code, image_name, is_owner, has_account, target_word, points, word_count, new_word_count)
+	values (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := s.db.ExecContext(ctx,
+		query,
+		player.Name,
+		player.LobbyCode,
+		player.ImageName,
+		player.IsOwner,
+		player.HasAccount,
+		player.TargetWord,
+		player.Points,
+		player.WordCount,
+		player.NewWordCount,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteStore) CreateLobby(ctx context.Context, lobby *Lobby) error {
+	query := `insert into lobby 
+	(name, image_name, lobby_code, game_mode, player_count)
+	values (?, ?, ?, ?, ?)`
+	_, err := s.db.ExecContext(ctx,
+		query,
+		lobby.Name,
+		lobby.ImageName,
+		lobby.LobbyCode,
+		lobby.GameMode,
+		lobby.PlayerCount,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetPlayerByLobbyCodeAndName(ctx context.Context, name, lobbyCode string) (*Player, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from player where name = ? and lobby_code = ?", name, lobbyCode)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		defer rows.Close()
+		return scanIntoPlayer(rows)
+	}
+	return nil, ae.NotFoundf("player %s not found", name)
+}
+
+func (s *SQLiteStore) DeletePlayer(ctx context.Context, name, lobbyCode string) error {
+	_, err := s.db.ExecContext(ctx, "delete from player where name = ? and lobby_code = ?", name, lobbyCode)
+	return err
+}
+
+func (s *SQLiteStore) GetPlayersByLobbyCode(ctx context.Context, lobbyCode string) ([]*Player, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from player where lobby_code = ?", lobbyCode)
+	if err != nil {
+		return nil, err
+	}
+	players := []*Player{}
+	defer rows.Close()
+	for rows.Next() {
+		player, err := scanIntoPlayer(rows)
+		if err != nil {
+			return nil, err
+		}
+		players = append(players, player)
+	}
+	return players, nil
+}
+
+func (s *SQLiteStore) GetAccountByUsername(ctx context.Context, username string) (*Account, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from account where username = ?", username)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		defer rows.Close()
+		return scanIntoAccount(rows)
+	}
+	return nil, ae.NotFoundf("account %s not found", username)
+}
+
+func (s *SQLiteStore) GetAccounts(ctx context.Context) ([]*Account, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from account")
+	if err != nil {
+		return nil, err
+	}
+	accounts := []*Account{}
+	defer rows.Close()
+	for rows.Next() {
+		acc, err := scanIntoAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, acc)
+	}
+	return accounts, rows.Err()
+}
+
+func (s *SQLiteStore) UpdateAccount(ctx context.Context, acc *Account) error {
+	query := `update account set
+	username = ?,
+	image_name = ?,
+	password = ?,
+	wins = ?,
+	losses = ?,
+	status = ?,
+	new_word_count = ?,
+	word_count = ?
+	where username = ?`
+	_, err := s.db.ExecContext(ctx,
+		query,
+		acc.Username,
+		acc.ImageName,
+		acc.Password,
+		acc.Wins,
+		acc.Losses,
+		acc.Status,
+		acc.NewWordCount,
+		acc.WordCount,
+		acc.Username,
+	)
+	return err
+}
+
+func (s *SQLiteStore) AddImage(ctx context.Context, data []byte, name string) error {
+	_, err := s.db.ExecContext(ctx,
+		"insert or replace into image (name, data) values (?, ?)",
+		name,
+		data,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetImage(ctx context.Context, name string) ([]byte, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from image where name = ?", name)
+	if err != nil {
+		return nil, err
+	}
+	images := []*Image{}
+	defer rows.Close()
+	for rows.Next() {
+		img, err := scanIntoImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	if len(images) > 1 {
+		return nil, fmt.Errorf("Multiple images for name %s", name)
+	}
+	if len(images) == 0 {
+		return nil, ae.NotFoundf("Image for name %s not found", name)
+	}
+	return images[0].Data, nil
+}
+
+func (s *SQLiteStore) GetImages(ctx context.Context) ([]*Image, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from image")
+	if err != nil {
+		return nil, err
+	}
+	images := []*Image{}
+	defer rows.Close()
+	for rows.Next() {
+		img, err := scanIntoImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+func (s *SQLiteStore) NewImageForUsername(ctx context.Context, username string) string {
+	images, err := s.GetImages(ctx)
+	if err != nil {
+		return err.Error()
+	}
+	size := len(images)
+	if size == 0 {
+		return ""
+	}
+	hash := u.RadixHash(username, size)
+	image := images[hash]
+	return image.Name
+}
+
+func (s *SQLiteStore) GetPlayerForAccount(ctx context.Context, username string) (*Player, error) {
+	acc, err := s.GetAccountByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return NewPlayer(username, "", acc.ImageName, false, true, acc.NewWordCount, acc.WordCount), nil
+}
+
+func (s *SQLiteStore) GetOwners(ctx context.Context) ([]*Player, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from player where is_owner = ?", true)
+	if err != nil {
+		return nil, err
+	}
+	owners := []*Player{}
+	defer rows.Close()
+	for rows.Next() {
+		owner, err := scanIntoPlayer(rows)
+		if err != nil {
+			return nil, err
+		}
+		owners = append(owners, owner)
+	}
+	return owners, nil
+}
+
+func (s *SQLiteStore) GetLobbyForOwner(ctx context.Context, owner string) (string, error) {
+	rows, err := s.db.QueryContext(ctx, "select lobby_code from player where name = ? and is_owner = ?", owner, true)
+	if err != nil {
+		return "", err
+	}
+	lobbyCodes := []string{}
+	var lobbyCode string
+	defer rows.Close()
+	for rows.Next() {
+		err := rows.Scan(&lobbyCode)
+		if err != nil {
+			return "", err
+		}
+		lobbyCodes = append(lobbyCodes, lobbyCode)
+	}
+	if len(lobbyCodes) > 1 {
+		return "", fmt.Errorf("Multiple lobbies for owner")
+	}
+	if len(lobbyCodes) == 0 {
+		log.Printf("No lobby found for owner %s", owner)
+		return "", nil
+	}
+	return lobbyCodes[0], nil
+}
+
+func (s *SQLiteStore) DeletePlayersForLobby(ctx context.Context, lobbyCode string) error {
+	_, err := s.db.ExecContext(ctx, "delete from player where lobby_code = ?", lobbyCode)
+	err = s.IncrementPlayerCount(ctx, lobbyCode, -1)
+	return err
+}
+
+func (s *SQLiteStore) AddPlayerToLobby(ctx context.Context, lobbyCode string, player *Player) error {
+	_, err := s.db.ExecContext(ctx,
+		"insert into player (name, lobby_code, image_name, is_owner, has_account, target_word, points, new_word_count, word_count) values (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		player.Name,
+		lobbyCode,
+		player.ImageName,
+		player.IsOwner,
+		player.HasAccount,
+		player.TargetWord,
+		player.Points,
+		player.NewWordCount,
+		player.WordCount,
+	)
+	log.Printf("insert error: %v", err)
+	if err != nil {
+		return err
+	}
+	err = s.IncrementPlayerCount(ctx, lobbyCode, 1)
+	return err
+}
+
+func (s *SQLiteStore) IncrementPlayerCount(ctx context.Context, lobbyCode string, increment int) error {
+	_, err := s.db.ExecContext(ctx, "update lobby set player_count = player_count + ? where lobby_code = ?", increment, lobbyCode)
+	return err
+}
+
+func (s *SQLiteStore) GetLobbies(ctx context.Context) ([]*Lobby, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from lobby")
+	if err != nil {
+		return nil, err
+	}
+	lobbies := []*Lobby{}
+	defer rows.Close()
+	for rows.Next() {
+		lobby, err := scanIntoLobby(rows)
+		if err != nil {
+			return nil, err
+		}
+		lobbies = append(lobbies, lobby)
+	}
+	return lobbies, nil
+}
+
+func (s *SQLiteStore) DeleteLobby(ctx context.Context, lobbyCode string) error {
+	_, err := s.db.ExecContext(ctx, "delete from lobby where lobby_code = ?", lobbyCode)
+	return err
+}
+
+func (s *SQLiteStore) GetLobbyByCode(ctx context.Context, lobbyCode string) (*Lobby, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from lobby where lobby_code = ?", lobbyCode)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		defer rows.Close()
+		return scanIntoLobby(rows)
+	}
+	return nil, ae.NotFoundf("lobby %s not found", lobbyCode)
+}
+
+func (s *SQLiteStore) EditGameMode(ctx context.Context, lobbyCode string, gameMode c.GameMode) error {
+	_, err := s.db.ExecContext(ctx, "update lobby set game_mode = ? where lobby_code = ?", gameMode, lobbyCode)
+	return err
+}
+
+func (s *SQLiteStore) GetCombination(ctx context.Context, a, b string) (*string, bool, error) {
+	a, b = u.SortAB(a, b)
+	var result string
+	err := s.db.QueryRowContext(ctx, "SELECT result FROM combination WHERE a = ? AND b = ?", a, b).Scan(&result)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return &result, true, nil
+}
+
+func (s *SQLiteStore) GetCombinations(ctx context.Context) ([]*Combination, error) {
+	rows, err := s.db.QueryContext(ctx, "select a, b, result, depth from combination")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	combinations := []*Combination{}
+	for rows.Next() {
+		combi, err := scanIntoCombination(rows)
+		if err != nil {
+			return nil, err
+		}
+		combinations = append(combinations, combi)
+	}
+	return combinations, rows.Err()
+}
+
+func (s *SQLiteStore) AddCombination(ctx context.Context, combi *Combination) error {
+	a, b := u.SortAB(combi.A, combi.B)
+	_, err := s.db.ExecContext(ctx,
+		"insert or ignore into combination (a, b, result, depth) values (?, ?, ?, ?)",
+		a,
+		b,
+		combi.Result,
+		combi.Depth,
+	)
+	return err
+}
+
+func (s *SQLiteStore) AddNewCombination(ctx context.Context, a, b, result string) error {
+	a, b = u.SortAB(a, b)
+	aDepth := 0
+	bDepth := 0
+	err := s.db.QueryRowContext(ctx, "select depth from word where word = ?", a).Scan(&aDepth)
+	if err != nil {
+		return err
+	}
+	err = s.db.QueryRowContext(ctx, "select depth from word where word = ?", b).Scan(&bDepth)
+	if err != nil {
+		return err
+	}
+	depth := max(aDepth, bDepth) + 1
+	_, err = s.db.ExecContext(ctx,
+		"insert or ignore into combination (a, b, result, depth) values (?, ?, ?, ?)",
+		a,
+		b,
+		result,
+		depth,
+	)
+	oldReachability := 0.0
+	err = s.db.QueryRowContext(ctx, "select reachability from word where word = ?", result).Scan(&oldReachability)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("No reachability for word %s", result)
+	}
+	reachability := 1.0 / float64(int(1)<<uint(depth))
+	_, err = s.db.ExecContext(ctx,
+		"insert or ignore into word (word, depth, reachability) values (?, ?, ?)",
+		result,
+		depth,
+		reachability+oldReachability,
+	)
+	return err
+}
+
+func (s *SQLiteStore) AddWord(ctx context.Context, word *Word) error {
+	w := strings.ToLower(word.Word)
+	_, err := s.db.ExecContext(ctx,
+		"insert or ignore into word (word, depth, reachability) values (?, ?, ?)",
+		w,
+		word.Depth,
+		word.Reachability,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetWords(ctx context.Context) ([]*Word, error) {
+	rows, err := s.db.QueryContext(ctx, "select word, depth, reachability from word")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	words := []*Word{}
+	for rows.Next() {
+		word, err := scanIntoWord(rows)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+	}
+	return words, rows.Err()
+}
+
+func (s *SQLiteStore) UpdateCombinations(ctx context.Context, combinations []*Combination) error {
+	return s.transaction(ctx, func(tx *SQLiteStore) error {
+		for _, combi := range combinations {
+			a, b := u.SortAB(combi.A, combi.B)
+			_, err := tx.db.ExecContext(ctx,
+				"insert into combination (a, b, result, depth) values (?, ?, ?, ?) on conflict (a, b) do update set result = excluded.result, depth = excluded.depth",
+				a,
+				b,
+				combi.Result,
+				combi.Depth,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStore) UpdateWords(ctx context.Context, words []*Word) error {
+	return s.transaction(ctx, func(tx *SQLiteStore) error {
+		for _, word := range words {
+			_, err := tx.db.ExecContext(ctx,
+				"insert into word (word, depth, reachability) values (?, ?, ?) on conflict (word) do update set depth = excluded.depth, reachability = excluded.reachability",
+				strings.ToLower(word.Word),
+				word.Depth,
+				word.Reachability,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStore) GetTargetWords(ctx context.Context, minReachability, maxReachability float64, maxDepth int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from word where reachability >= ? and reachability <= ? and depth <= ?", minReachability, maxReachability, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+	targetWords := []string{}
+	defer rows.Close()
+	for rows.Next() {
+		word, err := scanIntoWord(rows)
+		if err != nil {
+			return nil, err
+		}
+		targetWords = append(targetWords, word.Word)
+	}
+	log.Printf("Number of target words: %d", len(targetWords))
+	return targetWords, nil
+}
+
+func (s *SQLiteStore) GetTargetWord(ctx context.Context, minReachability, maxReachability float64, maxDepth int) (string, error) {
+	targetWords, err := s.GetTargetWords(ctx, minReachability, maxReachability, maxDepth)
+	if err != nil {
+		return "", err
+	}
+	if len(targetWords) == 0 {
+		return "", fmt.Errorf("No target words found")
+	}
+	return targetWords[rand.Intn(len(targetWords))], nil
+}
+
+func (s *SQLiteStore) AddPlayerWord(ctx context.Context, playerName, word, lobbyCode string) error {
+	_, err := s.db.ExecContext(ctx,
+		"insert or ignore into player_word (player_name, word, lobby_code) values (?, ?, ?)",
+		playerName,
+		word,
+		lobbyCode,
+	)
+	return err
+}
+
+func (s *SQLiteStore) IsPlayerWord(ctx context.Context, playerName, word, lobbyCode string) (bool, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, "select count(*) from player_word where player_name = ? and word = ? and lobby_code = ?", playerName, word, lobbyCode).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *SQLiteStore) SetPlayerTargetWord(ctx context.Context, playerName, targetWord, lobbyCode string) error {
+	_, err := s.db.ExecContext(ctx,
+		"update player set target_word = ? where name = ? and lobby_code = ?",
+		targetWord,
+		playerName,
+		lobbyCode,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetPlayerTargetWord(ctx context.Context, playerName, lobbyCode string) (string, error) {
+	var targetWord string
+	err := s.db.QueryRowContext(ctx, "select target_word from player where name = ? and lobby_code = ?", playerName, lobbyCode).Scan(&targetWord)
+	if err != nil {
+		return "", err
+	}
+	return targetWord, nil
+}
+
+func (s *SQLiteStore) GetPlayerWords(ctx context.Context, playerName, lobbyCode string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from player_word where player_name = ? and lobby_code = ? order by timestamp asc", playerName, lobbyCode)
+	if err != nil {
+		return nil, err
+	}
+	words := []string{}
+	defer rows.Close()
+	for rows.Next() {
+		playerWord, err := scanIntoPlayerWord(rows)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, playerWord.Word)
+	}
+	return words, nil
+}
+
+func (s *SQLiteStore) DeletePlayerWordsByLobbyCode(ctx context.Context, lobbyCode string) error {
+	_, err := s.db.ExecContext(ctx, "delete from player_word where lobby_code = ?", lobbyCode)
+	return err
+}
+
+func (s *SQLiteStore) DeletePlayerWordsByPlayerAndLobbyCode(ctx context.Context, playerName, lobbyCode string) error {
+	_, err := s.db.ExecContext(ctx, "delete from player_word where player_name = ? and lobby_code = ?", playerName, lobbyCode)
+	return err
+}
+
+func (s *SQLiteStore) GetWordCountByLobbyCode(ctx context.Context, lobbyCode string) ([]*dto.PlayerWordCount, error) {
+	query := `
+	select player_name, COUNT(*) as word_count
+	from player_word
+	where lobby_code = ?
+	group by player_name
+	order by word_count desc
+	`
+	rows, err := s.db.QueryContext(ctx, query, lobbyCode)
+	if err != nil {
+		return nil, err
+	}
+	wordCounts := []*dto.PlayerWordCount{}
+	defer rows.Close()
+	for rows.Next() {
+		wordCount, err := scanIntoPlayerWordCount(rows)
+		if err != nil {
+			return nil, err
+		}
+		wordCount.WordCount -= 4 // Exclude starting words
+		wordCounts = append(wordCounts, wordCount)
+	}
+	return wordCounts, nil
+}
+
+func (s *SQLiteStore) GetPlayersWithAccount(ctx context.Context, lobbyCode string) ([]*Account, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from player where lobby_code = ? and has_account = ?", lobbyCode, true)
+	if err != nil {
+		return nil, err
+	}
+	// Read all players first, a transaction can not run a second query while rows are open
+	names := []string{}
+	defer rows.Close()
+	for rows.Next() {
+		player, err := scanIntoPlayer(rows)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, player.Name)
+	}
+	rows.Close()
+	accounts := []*Account{}
+	for _, name := range names {
+		acc, err := s.GetAccountByUsername(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, acc)
+	}
+	return accounts, nil
+}
+
+func (s *SQLiteStore) UpdateAccountWinsAndLosses(ctx context.Context, lobbyCode, winner string) error {
+	return s.transaction(ctx, func(tx *SQLiteStore) error {
+		accounts, err := tx.GetPlayersWithAccount(ctx, lobbyCode)
+		if err != nil {
+			return err
+		}
+		for _, acc := range accounts {
+			if acc.Username == winner {
+				acc.Wins++
+			} else {
+				acc.Losses++
+			}
+			if err := tx.UpdateAccount(ctx, acc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStore) IncrementPlayerPoints(ctx context.Context, playerName, lobbyCode string, points int) error {
+	_, err := s.db.ExecContext(ctx, "update player set points = points + ? where name = ? and lobby_code = ?", points, playerName, lobbyCode)
+	return err
+}
+
+func (s *SQLiteStore) ResetPlayerPoints(ctx context.Context, lobbyCode string) error {
+	_, err := s.db.ExecContext(ctx, "update player set points = 0 where lobby_code = ?", lobbyCode)
+	return err
+}
+
+func (s *SQLiteStore) SetIsOwner(ctx context.Context, username string, setOwner bool) error {
+	if !setOwner {
+		_, err := s.db.ExecContext(ctx, "update account set is_owner = ? where username = ?", setOwner, username)
+		return err
+	}
+
+	var isOwner bool
+	err := s.db.QueryRowContext(ctx, "select is_owner from account where username = ?", username).Scan(&isOwner)
+	if err != nil {
+		return err
+	}
+	if isOwner {
+		return ae.Conflictf("User is already owner!")
+	}
+	_, err = s.db.ExecContext(ctx, "update account set is_owner = ? where username = ?", setOwner, username)
+	return err
+}
+
+func (s *SQLiteStore) SelectWinnerByPoints(ctx context.Context, lobbyCode string) (string, error) {
+	rows, err := s.db.QueryContext(ctx, "select name from player where lobby_code = ? order by points desc", lobbyCode)
+	if err != nil {
+		return "", err
+	}
+	winners := []string{}
+	var winner string
+	defer rows.Close()
+	for rows.Next() {
+		err := rows.Scan(&winner)
+		if err != nil {
+			return "", err
+		}
+		winners = append(winners, winner)
+	}
+	if len(winners) == 0 {
+		return "", ae.NotFoundf("No players found")
+	}
+	return winners[0], nil
+}
+
+func (s *SQLiteStore) DeleteAccount(ctx context.Context, username string) error {
+	_, err := s.db.ExecContext(ctx, "delete from account where username = ?", username)
+	return err
+}
+
+func (s *SQLiteStore) GetChallengeEntries(ctx context.Context) ([]*Challenger, error) {
+	today := time.Now().Format("2006-01-02")
+	rows, err := s.db.QueryContext(ctx, "select * from daily_challenge where timestamp = ?", today)
+	if err != nil {
+		return nil, err
+	}
+	entries := []*Challenger{}
+	defer rows.Close()
+	for rows.Next() {
+		entry, err := scanIntoChallengeEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (s *SQLiteStore) GetAllChallengeEntries(ctx context.Context) ([]*Challenger, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from daily_challenge order by timestamp")
+	if err != nil {
+		return nil, err
+	}
+	entries := []*Challenger{}
+	defer rows.Close()
+	for rows.Next() {
+		entry, err := scanIntoChallengeEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (s *SQLiteStore) GetImageByUsername(ctx context.Context, username string) ([]byte, error) {
+	var imageName string
+	err := s.db.QueryRowContext(ctx, "select image_name from account where username = ?", username).Scan(&imageName)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetImage(ctx, imageName)
+}
+
+func (s *SQLiteStore) CreateSession(ctx context.Context, session *Session) error {
+	query := `insert into session 
+	(id, refresh_token, username, is_revoked, created_at, expires_at, revoked_reason)
+	values (?, ?, ?, ?, ?, ?, ?)`
+	_, err := s.db.ExecContext(ctx,
+		query,
+		session.ID,
+		session.RefreshToken,
+		session.Username,
+		session.IsRevoked,
+		session.CreatedAt,
+		session.ExpiresAt,
+		session.RevokedReason,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetSession(ctx context.Context, id string) (*Session, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from session where id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		defer rows.Close()
+		return scanIntoSession(rows)
+	}
+	return nil, ae.NotFoundf("session %s not found", id)
+}
+
+func (s *SQLiteStore) RevokeSession(ctx context.Context, id string, reason c.RevokeReason) (bool, error) {
+	res, err := s.db.ExecContext(ctx, "update session set is_revoked = ?, revoked_reason = ? where id = ? and is_revoked = ?", true, reason, id, false)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *SQLiteStore) RevokeSessionsForUser(ctx context.Context, username string, reason c.RevokeReason) error {
+	_, err := s.db.ExecContext(ctx, "update session set is_revoked = ?, revoked_reason = ? where username = ? and is_revoked = ?", true, reason, username, false)
+	return err
+}
+
+func (s *SQLiteStore) SaveGame(ctx context.Context, game *GameState) error {
+	query := `insert or replace into game
+	(lobby_code, game_mode, target_word, target_words, with_timer, duration, deadline, winner, manual_end, word_uses)
+	values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := s.db.ExecContext(ctx,
+		query,
+		game.LobbyCode,
+		game.GameMode,
+		game.TargetWord,
+		game.TargetWords,
+		game.WithTimer,
+		game.Duration,
+		game.Deadline,
+		game.Winner,
+		game.ManualEnd,
+		game.WordUses,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetGames(ctx context.Context) ([]*GameState, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from game")
+	if err != nil {
+		return nil, err
+	}
+	games := []*GameState{}
+	defer rows.Close()
+	for rows.Next() {
+		game, err := scanIntoGameState(rows)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, game)
+	}
+	return games, nil
+}
+
+func (s *SQLiteStore) DeleteGame(ctx context.Context, lobbyCode string) error {
+	_, err := s.db.ExecContext(ctx, "delete from game where lobby_code = ?", lobbyCode)
+	return err
+}
+
+func (s *SQLiteStore) SaveWordUses(ctx context.Context, uses []*WordUse) error {
+	query := "insert or replace into word_use (lobby_code, player_name, word, uses_left) values (?, ?, ?, ?)"
+	for _, use := range uses {
+		if _, err := s.db.ExecContext(ctx, query, use.LobbyCode, use.PlayerName, use.Word, use.UsesLeft); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetWordUses(ctx context.Context, lobbyCode string) ([]*WordUse, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from word_use where lobby_code = ?", lobbyCode)
+	if err != nil {
+		return nil, err
+	}
+	uses := []*WordUse{}
+	defer rows.Close()
+	for rows.Next() {
+		use, err := scanIntoWordUse(rows)
+		if err != nil {
+			return nil, err
+		}
+		uses = append(uses, use)
+	}
+	return uses, nil
+}
+
+func (s *SQLiteStore) DeleteWordUses(ctx context.Context, lobbyCode string) error {
+	_, err := s.db.ExecContext(ctx, "delete from word_use where lobby_code = ?", lobbyCode)
+	return err
+}
+
+func (s *SQLiteStore) GetAchievements(ctx context.Context) ([]*AchievementEntry, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from achievement")
+	if err != nil {
+		return nil, err
+	}
+	entries := []*AchievementEntry{}
+	defer rows.Close()
+	for rows.Next() {
+		entry, err := scanIntoAchievementEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (s *SQLiteStore) UpdateAccountWordCount(ctx context.Context, username string, newWordCount, wordCount int) error {
+	_, err := s.db.ExecContext(ctx, "update account set new_word_count = ?, word_count = ? where username = ?", newWordCount, wordCount, username)
+	return err
+}
+
+func (s *SQLiteStore) UpdatePlayerWordCount(ctx context.Context, playerName, lobbyCode string, newWordCount, wordCount int) error {
+	_, err := s.db.ExecContext(ctx, "update player set new_word_count = ?, word_count = ? where name = ? and lobby_code = ?", newWordCount, wordCount, playerName, lobbyCode)
+	return err
+}
+
+func (s *SQLiteStore) AddAchievementImage(ctx context.Context, data []byte, name string) error {
+	_, err := s.db.ExecContext(ctx,
+		"insert or replace into achievement_image (name, data) values (?, ?)",
+		name,
+		data,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetAchievementImage(ctx context.Context, name string) ([]byte, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from achievement_image where name = ?", name)
+	if err != nil {
+		return nil, err
+	}
+	images := []*Image{}
+	defer rows.Close()
+	for rows.Next() {
+		img, err := scanIntoImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	if len(images) > 1 {
+		return nil, fmt.Errorf("Multiple images for name %s", name)
+	}
+	if len(images) == 0 {
+		return nil, ae.NotFoundf("Image for name %s not found", name)
+	}
+	return images[0].Data, nil
+}
+
+func (s *SQLiteStore) GetAchievementImages(ctx context.Context) ([]*Image, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from achievement_image")
+	if err != nil {
+		return nil, err
+	}
+	images := []*Image{}
+	defer rows.Close()
+	for rows.Next() {
+		img, err := scanIntoImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	return images, rows.Err()
+}
+
+func (s *SQLiteStore) GetAchievementByTitle(ctx context.Context, title string) (*AchievementEntry, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from achievement where title = ?", title)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		defer rows.Close()
+		return scanIntoAchievementEntry(rows)
+	}
+	return nil, ae.NotFoundf("Achievement %s not found", title)
+}
+
+func (s *SQLiteStore) GetUnlocked(ctx context.Context) ([]*Unlocked, error) {
+	rows, err := s.db.QueryContext(ctx, "select * from unlocked")
+	if err != nil {
+		return nil, err
+	}
+	unlocked := []*Unlocked{}
+	defer rows.Close()
+	for rows.Next() {
+		entry, err := scanIntoUnlocked(rows)
+		if err != nil {
+			return nil, err
+		}
+		unlocked = append(unlocked, entry)
+	}
+	return unlocked, rows.Err()
+}
+
+func (s *SQLiteStore) GetAchievementsForUser(ctx context.Context, username string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "select achievement_title from unlocked where username = ?", username)
+	if err != nil {
+		return nil, err
+	}
+	achievements := []string{}
+	defer rows.Close()
+	for rows.Next() {
+		var title string
+		err := rows.Scan(&title)
+		if err != nil {
+			return nil, err
+		}
+		achievements = append(achievements, title)
+	}
+	return achievements, nil
+}
@@ -0,0 +1,219 @@
+package storage
+
+// Schema migrations of the SQLite backend, append new migrations at the end
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// SQLite has no "add column if not exists", databases created by older builds may already have the column
+func addColumnIfMissing(tx *sql.Tx, table, column, columnType string) error {
+	rows, err := tx.Query(fmt.Sprintf("select name from pragma_table_info('%s')", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf("alter table %s add column %s %s", table, column, columnType))
+	return err
+}
+
+var sqliteMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		// Tables may exist already, if the database was created before migrations were introduced
+		Up: statements(
+			`create table if not exists account (
+				username text primary key,
+				image_name text,
+				password text,
+				wins integer,
+				losses integer,
+				created_at datetime,
+				status text,
+				is_owner boolean
+				)`,
+			`create table if not exists image (
+				name text primary key,
+				data blob
+				)`,
+			`create table if not exists player (
+				name text,
+				lobby_code text,
+				image_name,
+				is_owner boolean,
+				has_account boolean,
+				target_word text,
+				points integer,
+				primary key (name, lobby_code)
+				)`,
+			`create table if not exists lobby (
+				name text,
+				image_name text,
+				lobby_code text,
+				game_mode text,
+				player_count integer,
+				primary key (lobby_code)
+				)`,
+			`create table if not exists combination (
+				a text,
+				b text,
+				result text,
+				depth integer,
+				primary key (a, b)
+				)`,
+			`create table if not exists word (
+				word text primary key,
+				depth integer,
+				reachability float
+				)`,
+			`create table if not exists player_word (
+				player_name text,
+				word text,
+				lobby_code text,
+				timestamp datetime default current_timestamp,
+				primary key (player_name, word, lobby_code)
+				)`,
+			`create table if not exists daily_word (
+				timestamp datetime default current_timestamp,
+				word text
+				)`,
+			`create table if not exists daily_challenge (
+				timestamp datetime default current_timestamp,
+				word_count integer,
+				username text
+				)`,
+			`create table if not exists session (
+				id text primary key,
+				refresh_token text,
+				username text,
+				is_revoked boolean,
+				created_at datetime,
+				expires_at datetime
+				)`,
+			`create table if not exists achievement (
+				id integer primary key,
+				title text,
+				type text,
+				value text,
+				description text,
+				image_name text
+				)`,
+			`create table if not exists unlocked (
+				username text,
+				achievement_title text,
+				primary key (username, achievement_title)
+				)`,
+			`create table if not exists achievement_image (
+				name text primary key,
+				data blob
+				)`,
+		),
+		Down: statements(
+			"drop table if exists achievement_image",
+			"drop table if exists unlocked",
+			"drop table if exists achievement",
+			"drop table if exists session",
+			"drop table if exists daily_challenge",
+			"drop table if exists daily_word",
+			"drop table if exists player_word",
+			"drop table if exists word",
+			"drop table if exists combination",
+			"drop table if exists lobby",
+			"drop table if exists player",
+			"drop table if exists image",
+			"drop table if exists account",
+		),
+	},
+	{
+		Version: 2,
+		Name:    "word counts of accounts and players",
+		// Queries select *, so the columns are added in the order of the scanIntoX helpers
+		Up: func(tx *sql.Tx) error {
+			columns := [][]string{
+				{"account", "new_word_count"},
+				{"account", "word_count"},
+				{"player", "word_count"},
+				{"player", "new_word_count"},
+			}
+			for _, column := range columns {
+				if err := addColumnIfMissing(tx, column[0], column[1], "integer"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: statements(
+			"alter table account drop column new_word_count",
+			"alter table account drop column word_count",
+			"alter table player drop column new_word_count",
+			"alter table player drop column word_count",
+		),
+	},
+	{
+		Version: 3,
+		Name:    "running games",
+		Up: statements(
+			`create table if not exists game (
+				lobby_code text primary key,
+				game_mode text,
+				target_word text,
+				target_words text,
+				with_timer boolean,
+				duration integer,
+				deadline datetime,
+				winner text,
+				manual_end boolean,
+				word_uses integer
+				)`,
+		),
+		Down: statements("drop table if exists game"),
+	},
+	{
+		Version: 4,
+		Name:    "remaining word uses",
+		Up: statements(
+			`create table if not exists word_use (
+				lobby_code text,
+				player_name text,
+				word text,
+				uses_left integer,
+				primary key (lobby_code, player_name, word)
+				)`,
+		),
+		Down: statements("drop table if exists word_use"),
+	},
+	{
+		Version: 5,
+		Name:    "reasons of revoked sessions",
+		// Sessions revoked by older builds count as rotated, their refresh tokens were treated as reused
+		Up: func(tx *sql.Tx) error {
+			if err := addColumnIfMissing(tx, "session", "revoked_reason", "text not null default ''"); err != nil {
+				return err
+			}
+			_, err := tx.Exec("update session set revoked_reason = 'ROTATED' where is_revoked and revoked_reason = ''")
+			return err
+		},
+		Down: statements("alter table session drop column revoked_reason"),
+	},
+	{
+		Version: 6,
+		Name:    "event ids",
+		// Only the Postgres backplane takes event IDs from the database, the version is kept in line with Postgres
+		Up:   statements(),
+		Down: statements(),
+	},
+}
@@ -0,0 +1,503 @@
+package storage
+
+// Deadlines for storage operations, independent of the backend
+
+import (
+	"context"
+	"time"
+
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	dto "github.com/na50r/wombo-combo-go-be/dto"
+)
+
+type timeoutStore struct {
+	store   Storage
+	timeout time.Duration
+}
+
+// WithTimeout bounds every operation on store, a hung database fails the operation instead of blocking forever.
+// Every statement inside WithTx gets its own deadline, the transaction as a whole ends with the ctx passed to it.
+func WithTimeout(store Storage, timeout time.Duration) Storage {
+	if timeout <= 0 {
+		return store
+	}
+	return &timeoutStore{store: store, timeout: timeout}
+}
+
+func (s *timeoutStore) Init() error {
+	return s.store.Init()
+}
+
+func (s *timeoutStore) Close() error {
+	return s.store.Close()
+}
+
+func (s *timeoutStore) Migrate(version int) error {
+	return s.store.Migrate(version)
+}
+
+func (s *timeoutStore) MigrationStatus() ([]MigrationStatus, error) {
+	return s.store.MigrationStatus()
+}
+
+func (s *timeoutStore) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	return s.store.WithTx(ctx, func(tx Storage) error {
+		return fn(&timeoutStore{store: tx, timeout: s.timeout})
+	})
+}
+
+func (s *timeoutStore) CreateAccount(ctx context.Context, acc *Account) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.CreateAccount(ctx, acc)
+}
+
+func (s *timeoutStore) CreatePlayer(ctx context.Context, player *Player) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.CreatePlayer(ctx, player)
+}
+
+func (s *timeoutStore) CreateLobby(ctx context.Context, lobby *Lobby) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.CreateLobby(ctx, lobby)
+}
+
+func (s *timeoutStore) DeleteAccount(ctx context.Context, username string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.DeleteAccount(ctx, username)
+}
+
+func (s *timeoutStore) GetPlayerByLobbyCodeAndName(ctx context.Context, name, lobbyCode string) (*Player, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetPlayerByLobbyCodeAndName(ctx, name, lobbyCode)
+}
+
+func (s *timeoutStore) DeletePlayer(ctx context.Context, name, lobbyCode string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.DeletePlayer(ctx, name, lobbyCode)
+}
+
+func (s *timeoutStore) GetPlayersByLobbyCode(ctx context.Context, lobbyCode string) ([]*Player, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetPlayersByLobbyCode(ctx, lobbyCode)
+}
+
+func (s *timeoutStore) GetAccountByUsername(ctx context.Context, username string) (*Account, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetAccountByUsername(ctx, username)
+}
+
+func (s *timeoutStore) GetAccounts(ctx context.Context) ([]*Account, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetAccounts(ctx)
+}
+
+func (s *timeoutStore) UpdateAccount(ctx context.Context, acc *Account) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.UpdateAccount(ctx, acc)
+}
+
+func (s *timeoutStore) AddImage(ctx context.Context, data []byte, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.AddImage(ctx, data, name)
+}
+
+func (s *timeoutStore) GetImage(ctx context.Context, name string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetImage(ctx, name)
+}
+
+func (s *timeoutStore) GetImages(ctx context.Context) ([]*Image, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetImages(ctx)
+}
+
+func (s *timeoutStore) NewImageForUsername(ctx context.Context, username string) string {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.NewImageForUsername(ctx, username)
+}
+
+func (s *timeoutStore) GetPlayerForAccount(ctx context.Context, username string) (*Player, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetPlayerForAccount(ctx, username)
+}
+
+func (s *timeoutStore) GetLobbyForOwner(ctx context.Context, owner string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetLobbyForOwner(ctx, owner)
+}
+
+func (s *timeoutStore) DeletePlayersForLobby(ctx context.Context, lobbyCode string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.DeletePlayersForLobby(ctx, lobbyCode)
+}
+
+func (s *timeoutStore) AddPlayerToLobby(ctx context.Context, lobbyCode string, player *Player) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.AddPlayerToLobby(ctx, lobbyCode, player)
+}
+
+func (s *timeoutStore) DeleteLobby(ctx context.Context, lobbyCode string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.DeleteLobby(ctx, lobbyCode)
+}
+
+func (s *timeoutStore) GetLobbies(ctx context.Context) ([]*Lobby, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetLobbies(ctx)
+}
+
+func (s *timeoutStore) GetLobbyByCode(ctx context.Context, lobbyCode string) (*Lobby, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetLobbyByCode(ctx, lobbyCode)
+}
+
+func (s *timeoutStore) EditGameMode(ctx context.Context, lobbyCode string, gameMode c.GameMode) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.EditGameMode(ctx, lobbyCode, gameMode)
+}
+
+func (s *timeoutStore) AddCombination(ctx context.Context, element *Combination) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.AddCombination(ctx, element)
+}
+
+func (s *timeoutStore) GetCombination(ctx context.Context, a, b string) (*string, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetCombination(ctx, a, b)
+}
+
+func (s *timeoutStore) GetCombinations(ctx context.Context) ([]*Combination, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetCombinations(ctx)
+}
+
+func (s *timeoutStore) UpdateCombinations(ctx context.Context, combinations []*Combination) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.UpdateCombinations(ctx, combinations)
+}
+
+func (s *timeoutStore) AddWord(ctx context.Context, word *Word) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.AddWord(ctx, word)
+}
+
+func (s *timeoutStore) GetWords(ctx context.Context) ([]*Word, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetWords(ctx)
+}
+
+func (s *timeoutStore) UpdateWords(ctx context.Context, words []*Word) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.UpdateWords(ctx, words)
+}
+
+func (s *timeoutStore) AddPlayerWord(ctx context.Context, playerName, word, lobbyCode string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.AddPlayerWord(ctx, playerName, word, lobbyCode)
+}
+
+func (s *timeoutStore) GetPlayerWords(ctx context.Context, playerName, lobbyCode string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetPlayerWords(ctx, playerName, lobbyCode)
+}
+
+func (s *timeoutStore) DeletePlayerWordsByLobbyCode(ctx context.Context, lobbyCode string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.DeletePlayerWordsByLobbyCode(ctx, lobbyCode)
+}
+
+func (s *timeoutStore) DeletePlayerWordsByPlayerAndLobbyCode(ctx context.Context, playerName, lobbyCode string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.DeletePlayerWordsByPlayerAndLobbyCode(ctx, playerName, lobbyCode)
+}
+
+func (s *timeoutStore) GetWordCountByLobbyCode(ctx context.Context, lobbyCode string) ([]*dto.PlayerWordCount, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetWordCountByLobbyCode(ctx, lobbyCode)
+}
+
+func (s *timeoutStore) UpdateAccountWinsAndLosses(ctx context.Context, lobbyCode, winner string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.UpdateAccountWinsAndLosses(ctx, lobbyCode, winner)
+}
+
+func (s *timeoutStore) SetPlayerTargetWord(ctx context.Context, playerName, targetWord, lobbyCode string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.SetPlayerTargetWord(ctx, playerName, targetWord, lobbyCode)
+}
+
+func (s *timeoutStore) GetPlayerTargetWord(ctx context.Context, playerName, lobbyCode string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetPlayerTargetWord(ctx, playerName, lobbyCode)
+}
+
+func (s *timeoutStore) IsPlayerWord(ctx context.Context, playerName, word, lobbyCode string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.IsPlayerWord(ctx, playerName, word, lobbyCode)
+}
+
+func (s *timeoutStore) IncrementPlayerPoints(ctx context.Context, playerName, lobbyCode string, points int) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.IncrementPlayerPoints(ctx, playerName, lobbyCode, points)
+}
+
+func (s *timeoutStore) SetIsOwner(ctx context.Context, username string, setOwner bool) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.SetIsOwner(ctx, username, setOwner)
+}
+
+func (s *timeoutStore) SelectWinnerByPoints(ctx context.Context, lobbyCode string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.SelectWinnerByPoints(ctx, lobbyCode)
+}
+
+func (s *timeoutStore) ResetPlayerPoints(ctx context.Context, lobbyCode string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.ResetPlayerPoints(ctx, lobbyCode)
+}
+
+func (s *timeoutStore) IncrementPlayerCount(ctx context.Context, lobbyCode string, increment int) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.IncrementPlayerCount(ctx, lobbyCode, increment)
+}
+
+func (s *timeoutStore) AddNewCombination(ctx context.Context, a, b, result string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.AddNewCombination(ctx, a, b, result)
+}
+
+func (s *timeoutStore) CreateOrGetDailyWord(ctx context.Context, minReachability, maxReachability float64, maxDepth int) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.CreateOrGetDailyWord(ctx, minReachability, maxReachability, maxDepth)
+}
+
+func (s *timeoutStore) GetDailyWords(ctx context.Context) ([]*DailyWord, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetDailyWords(ctx)
+}
+
+func (s *timeoutStore) AddDailyWord(ctx context.Context, daily *DailyWord) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.AddDailyWord(ctx, daily)
+}
+
+func (s *timeoutStore) AddDailyChallengeEntry(ctx context.Context, wordCount int, username string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.AddDailyChallengeEntry(ctx, wordCount, username)
+}
+
+func (s *timeoutStore) AddChallengeEntry(ctx context.Context, entry *Challenger) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.AddChallengeEntry(ctx, entry)
+}
+
+func (s *timeoutStore) GetChallengeEntries(ctx context.Context) ([]*Challenger, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetChallengeEntries(ctx)
+}
+
+func (s *timeoutStore) GetAllChallengeEntries(ctx context.Context) ([]*Challenger, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetAllChallengeEntries(ctx)
+}
+
+func (s *timeoutStore) GetImageByUsername(ctx context.Context, username string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetImageByUsername(ctx, username)
+}
+
+func (s *timeoutStore) GetTargetWords(ctx context.Context, minReachability, maxReachability float64, maxDepth int) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetTargetWords(ctx, minReachability, maxReachability, maxDepth)
+}
+
+func (s *timeoutStore) GetTargetWord(ctx context.Context, minReachability, maxReachability float64, maxDepth int) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetTargetWord(ctx, minReachability, maxReachability, maxDepth)
+}
+
+func (s *timeoutStore) GetAchievements(ctx context.Context) ([]*AchievementEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetAchievements(ctx)
+}
+
+func (s *timeoutStore) UpdateAccountWordCount(ctx context.Context, username string, newWordCount, wordCount int) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.UpdateAccountWordCount(ctx, username, newWordCount, wordCount)
+}
+
+func (s *timeoutStore) UpdatePlayerWordCount(ctx context.Context, playerName, lobbyCode string, newWordCount, wordCount int) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.UpdatePlayerWordCount(ctx, playerName, lobbyCode, newWordCount, wordCount)
+}
+
+func (s *timeoutStore) AddAchievement(ctx context.Context, entry *AchievementEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.AddAchievement(ctx, entry)
+}
+
+func (s *timeoutStore) UpdateAchievements(ctx context.Context, entries []*AchievementEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.UpdateAchievements(ctx, entries)
+}
+
+func (s *timeoutStore) UnlockAchievement(ctx context.Context, username, achievementTitle string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.UnlockAchievement(ctx, username, achievementTitle)
+}
+
+func (s *timeoutStore) AddAchievementImage(ctx context.Context, data []byte, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.AddAchievementImage(ctx, data, name)
+}
+
+func (s *timeoutStore) GetAchievementImage(ctx context.Context, name string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetAchievementImage(ctx, name)
+}
+
+func (s *timeoutStore) GetAchievementImages(ctx context.Context) ([]*Image, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetAchievementImages(ctx)
+}
+
+func (s *timeoutStore) GetAchievementsForUser(ctx context.Context, username string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetAchievementsForUser(ctx, username)
+}
+
+func (s *timeoutStore) GetUnlocked(ctx context.Context) ([]*Unlocked, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetUnlocked(ctx)
+}
+
+func (s *timeoutStore) GetAchievementByTitle(ctx context.Context, title string) (*AchievementEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetAchievementByTitle(ctx, title)
+}
+
+func (s *timeoutStore) CreateSession(ctx context.Context, session *Session) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.CreateSession(ctx, session)
+}
+
+func (s *timeoutStore) GetSession(ctx context.Context, id string) (*Session, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetSession(ctx, id)
+}
+
+func (s *timeoutStore) RevokeSession(ctx context.Context, id string, reason c.RevokeReason) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.RevokeSession(ctx, id, reason)
+}
+
+func (s *timeoutStore) RevokeSessionsForUser(ctx context.Context, username string, reason c.RevokeReason) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.RevokeSessionsForUser(ctx, username, reason)
+}
+
+func (s *timeoutStore) SaveGame(ctx context.Context, game *GameState) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.SaveGame(ctx, game)
+}
+
+func (s *timeoutStore) GetGames(ctx context.Context) ([]*GameState, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetGames(ctx)
+}
+
+func (s *timeoutStore) SaveWordUses(ctx context.Context, uses []*WordUse) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.SaveWordUses(ctx, uses)
+}
+
+func (s *timeoutStore) GetWordUses(ctx context.Context, lobbyCode string) ([]*WordUse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.GetWordUses(ctx, lobbyCode)
+}
+
+func (s *timeoutStore) DeleteWordUses(ctx context.Context, lobbyCode string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.DeleteWordUses(ctx, lobbyCode)
+}
+
+func (s *timeoutStore) DeleteGame(ctx context.Context, lobbyCode string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.store.DeleteGame(ctx, lobbyCode)
+}
@@ -0,0 +1,34 @@
+package storage
+
+// Transactions, shared by the SQL backends
+
+import (
+	"context"
+	"database/sql"
+)
+
+// dbtx is implemented by *sql.DB and *sql.Tx, the queries of a store run the same way on both
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// withTx commits if fn succeeds and rolls back if it fails, panics or ctx ends
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
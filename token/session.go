@@ -0,0 +1,141 @@
+package token
+
+// Refresh tokens backed by server-side sessions
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	ae "github.com/na50r/wombo-combo-go-be/apierror"
+	c "github.com/na50r/wombo-combo-go-be/constants"
+	st "github.com/na50r/wombo-combo-go-be/storage"
+)
+
+const AccessTokenDuration = time.Minute * 15
+const RefreshTokenDuration = time.Hour * 24 * 7
+
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// Refresh tokens have the form <session id>.<secret>, only the hash of the secret is stored
+func NewSession(username string) (*st.Session, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", fmt.Errorf("failed to generate refresh token: %v", err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(buf)
+	now := time.Now()
+	session := &st.Session{
+		ID:           uuid.New().String(),
+		RefreshToken: hashSecret(secret),
+		Username:     username,
+		IsRevoked:    false,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(RefreshTokenDuration),
+	}
+	return session, session.ID + "." + secret, nil
+}
+
+// CreateSession stores a new session and returns an access and a refresh token for it
+func CreateSession(ctx context.Context, store st.Storage, username string) (string, string, error) {
+	session, refreshToken, err := NewSession(username)
+	if err != nil {
+		return "", "", err
+	}
+	if err := store.CreateSession(ctx, session); err != nil {
+		return "", "", err
+	}
+	accessToken, err := CreateJWT(username, session.ID)
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+// RefreshSession rotates the session, the old refresh token can not be used again.
+// Check and rotation run in one transaction and the revoke only succeeds on an active session,
+// so of two requests with the same refresh token only one gets a new session.
+// Reusing the token of a rotated session revokes all sessions of the account, the token of a logged out session is only rejected.
+func RefreshSession(ctx context.Context, store st.Storage, refreshToken string) (string, string, error) {
+	id, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok {
+		return "", "", ae.Unauthorizedf(c.Unauthorized)
+	}
+	var accessToken, newRefreshToken, reusedBy string
+	err := store.WithTx(ctx, func(tx st.Storage) error {
+		session, err := tx.GetSession(ctx, id)
+		if ae.Is(err, ae.NotFound) {
+			return ae.Unauthorizedf(c.Unauthorized)
+		}
+		if err != nil {
+			return err
+		}
+		if session.RefreshToken != hashSecret(secret) {
+			return ae.Unauthorizedf(c.Unauthorized)
+		}
+		if !session.IsRevoked && time.Now().After(session.ExpiresAt) {
+			return ae.Unauthorizedf("Session expired, please log in again").WithCode(c.SESSION_EXPIRED)
+		}
+		rotated := false
+		if !session.IsRevoked {
+			rotated, err = tx.RevokeSession(ctx, session.ID, c.ROTATED)
+			if err != nil {
+				return err
+			}
+			// Revoked in the meantime, by a logout or by a request with the same refresh token
+			if !rotated {
+				if session, err = tx.GetSession(ctx, id); err != nil {
+					return err
+				}
+			}
+		}
+		if !rotated {
+			// A client may still hold the refresh token of a logged out session, that alone does not hint at a theft
+			if session.RevokedReason != c.ROTATED {
+				return ae.Unauthorizedf(c.Unauthorized)
+			}
+			// A rotated refresh token was used again, assume it was stolen and end all sessions
+			reusedBy = session.Username
+			return tx.RevokeSessionsForUser(ctx, session.Username, c.REUSED)
+		}
+		accessToken, newRefreshToken, err = CreateSession(ctx, tx, session.Username)
+		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if reusedBy != "" {
+		log.Printf("Refresh token reuse detected for %s, revoked all sessions", reusedBy)
+		return "", "", ae.Unauthorizedf(c.Unauthorized)
+	}
+	return accessToken, newRefreshToken, nil
+}
+
+// VerifyAccountSession verifies the access token and checks that its session has not been revoked
+func VerifyAccountSession(ctx context.Context, store st.Storage, tokenString string) (*AccountClaims, error) {
+	claims, err := VerifyAccountJWT(tokenString)
+	if err != nil {
+		return nil, ae.Wrap(ae.Unauthorized, err, c.Unauthorized)
+	}
+	session, err := store.GetSession(ctx, claims.SessionID)
+	if ae.Is(err, ae.NotFound) {
+		return nil, ae.Unauthorizedf(c.Unauthorized)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.IsRevoked {
+		return nil, ae.Unauthorizedf("Session revoked, please log in again")
+	}
+	return claims, nil
+}